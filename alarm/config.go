@@ -3,13 +3,22 @@ package alarm
 import alarm "github.com/goodahn/alarm-for-programmer"
 
 func NewAlarmer(configPath string) Alarmer {
-	cm := alarm.NewConfigMonitor(configPath)
+	return NewAlarmerWithConfigMonitor(alarm.NewConfigMonitor(configPath))
+}
+
+func NewAlarmerWithConfigMonitor(cm *alarm.ConfigMonitor) Alarmer {
+	am := NewUnstartedAlarmerWithConfigMonitor(cm)
+	am.Start()
+	return am
+}
 
+// NewUnstartedAlarmerWithConfigMonitor returns alarmer which doesn't monitor processes yet,
+// so that stores can be set before the first period
+func NewUnstartedAlarmerWithConfigMonitor(cm *alarm.ConfigMonitor) Alarmer {
 	alarmConfig := cm.GetAlarmConfig()
-	if alarmConfig["type"] == "slack-webhook" {
+	if alarmConfig.Type == alarm.SlackWebHookAlarmType {
 		am := SlackWebHookAlarmer{}
 		am.Init(cm)
-		am.Start()
 		return &am
 	} else {
 		panic("not implemented type of alarmer")
@@ -0,0 +1,457 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+const (
+	ControlSocketFileName = "control.sock"
+	// ControlServiceName is the prefix of methods, e.g. "Control.AddPattern"
+	ControlServiceName = "Control"
+
+	controlDialTimeout = time.Second
+)
+
+// ControlSocketPath is the socket of running daemon, $XDG_RUNTIME_DIR/alarm-for-programmer/control.sock
+func ControlSocketPath() string {
+	return filepath.Join(alarm.RuntimeDirectory(), ControlSocketFileName)
+}
+
+type NoArgs struct{}
+
+type PatternArgs struct {
+	Pattern string `json:"pattern"`
+}
+
+type TestAlarmArgs struct {
+	// Destination is a name of destinations in config, alarmConfig is used if it is empty
+	Destination string `json:"destination"`
+}
+
+type PatternListReply struct {
+	// MonitoringCommandList is monitoringCommandList of config with Added and Removed applied
+	MonitoringCommandList []string `json:"monitoringCommandList"`
+	Added                 []string `json:"added"`
+	Removed               []string `json:"removed"`
+	// Tracked contains namePatterns found in project configs too
+	Tracked []string `json:"tracked"`
+}
+
+type PidArgs struct {
+	Pid int `json:"pid"`
+}
+
+// MuteArgs is a mute rule, every alarm is muted if both Pattern and Destination are empty
+type MuteArgs struct {
+	Pattern     string `json:"pattern"`
+	Destination string `json:"destination"`
+	// ExpiresAt is zero for rule which doesn't expire
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// UnmuteArgs removes the rule of Id, every rule if All is set,
+// or rules of exactly Pattern and Destination otherwise, which are global rules when both are empty.
+// only one of them can be given, so Pattern alone removes only rules of the pattern without destination
+type UnmuteArgs struct {
+	Id          int    `json:"id"`
+	All         bool   `json:"all"`
+	Pattern     string `json:"pattern"`
+	Destination string `json:"destination"`
+}
+
+type MuteReply struct {
+	// IsMuted is whether every alarm is muted by a global rule
+	IsMuted      bool             `json:"isMuted"`
+	MuteRuleList []alarm.MuteRule `json:"muteRuleList"`
+}
+
+// ControlServer serves JSON-RPC 1.0 on a unix domain socket so that running alarmer can be changed without editing config.
+// only processes of the same user can use it, which is checked by SO_PEERCRED
+type ControlServer struct {
+	socketPath string
+	rpcServer  *rpc.Server
+	listener   net.Listener
+	isStarted  bool
+
+	mutexForSynchronousMethodCall sync.Mutex
+}
+
+func NewControlServer(alarmer Alarmer, socketPath string) (*ControlServer, error) {
+	cs := &ControlServer{}
+	cs.Init(alarmer, socketPath)
+	return cs, cs.Start()
+}
+
+func (cs *ControlServer) Init(alarmer Alarmer, socketPath string) {
+	cs.socketPath = socketPath
+	cs.rpcServer = rpc.NewServer()
+	cs.rpcServer.RegisterName(ControlServiceName, &controlService{
+		alarmer: alarmer,
+	})
+}
+
+// Listen takes the socket without serving, so that only one daemon prepares its state.
+// connections are accepted after Start
+func (cs *ControlServer) Listen() error {
+	cs.mutexForSynchronousMethodCall.Lock()
+	defer cs.mutexForSynchronousMethodCall.Unlock()
+	return cs.listen()
+}
+
+func (cs *ControlServer) listen() error {
+	if cs.listener != nil {
+		return nil
+	}
+	if err := alarm.EnsurePrivateDirectory(filepath.Dir(cs.socketPath)); err != nil {
+		return err
+	}
+	if err := removeStaleSocket(cs.socketPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", cs.socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(cs.socketPath, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+	cs.listener = listener
+	return nil
+}
+
+func (cs *ControlServer) Start() error {
+	cs.mutexForSynchronousMethodCall.Lock()
+	defer cs.mutexForSynchronousMethodCall.Unlock()
+
+	if cs.isStarted {
+		return nil
+	}
+	if err := cs.listen(); err != nil {
+		return err
+	}
+	cs.isStarted = true
+
+	go cs.serve(cs.listener)
+	return nil
+}
+
+// removeStaleSocket removes socket which is left by a daemon which is not running any more
+func removeStaleSocket(socketPath string) error {
+	if _, err := os.Lstat(socketPath); os.IsNotExist(err) {
+		return nil
+	}
+	conn, err := net.DialTimeout("unix", socketPath, controlDialTimeout)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("another daemon is listening on %s", socketPath)
+	}
+	return os.Remove(socketPath)
+}
+
+func (cs *ControlServer) serve(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !cs.IsStarted() {
+				return
+			}
+			errMsg := fmt.Sprintf("error occured during accepting control connection: %v", err)
+			fmt.Println(errMsg)
+			continue
+		}
+		if err := checkPeerCredential(conn); err != nil {
+			log.Printf("control connection is refused: %v\n", err)
+			conn.Close()
+			continue
+		}
+		go cs.rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// checkPeerCredential allows only processes of the user who runs the daemon
+func checkPeerCredential(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("connection is not of unix domain socket")
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ucred *syscall.Ucred
+	var ucredErr error
+	err = rawConn.Control(func(fd uintptr) {
+		ucred, ucredErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return err
+	}
+	if ucredErr != nil {
+		return ucredErr
+	}
+	if int(ucred.Uid) != os.Getuid() {
+		return fmt.Errorf("uid %d of pid %d is not the owner of daemon", ucred.Uid, ucred.Pid)
+	}
+	return nil
+}
+
+func (cs *ControlServer) GetSocketPath() string {
+	return cs.socketPath
+}
+
+func (cs *ControlServer) IsStarted() bool {
+	cs.mutexForSynchronousMethodCall.Lock()
+	defer cs.mutexForSynchronousMethodCall.Unlock()
+	return cs.isStarted
+}
+
+func (cs *ControlServer) Stop() {
+	cs.mutexForSynchronousMethodCall.Lock()
+	defer cs.mutexForSynchronousMethodCall.Unlock()
+
+	if cs.listener == nil {
+		return
+	}
+	cs.isStarted = false
+	cs.listener.Close()
+	cs.listener = nil
+	os.Remove(cs.socketPath)
+}
+
+// controlService is methods of JSON-RPC, they are called like {"method": "Control.AddPattern", "params": [{"pattern": "make"}], "id": 1}
+type controlService struct {
+	alarmer Alarmer
+}
+
+func (s *controlService) ListPatterns(args *NoArgs, reply *PatternListReply) error {
+	*reply = s.patternList()
+	return nil
+}
+
+func (s *controlService) ListProcesses(args *NoArgs, reply *[]TrackedProcess) error {
+	*reply = s.alarmer.GetTrackedProcessList()
+	return nil
+}
+
+func (s *controlService) AddPattern(args *PatternArgs, reply *PatternListReply) error {
+	if args.Pattern == "" {
+		return errors.New("pattern should not be empty")
+	}
+	if err := alarm.ValidateNamePattern(args.Pattern); err != nil {
+		return err
+	}
+	s.alarmer.AddMonitoringCommand(args.Pattern)
+	*reply = s.patternList()
+	return nil
+}
+
+func (s *controlService) RemovePattern(args *PatternArgs, reply *PatternListReply) error {
+	if args.Pattern == "" {
+		return errors.New("pattern should not be empty")
+	}
+	s.alarmer.RemoveMonitoringCommand(args.Pattern)
+	*reply = s.patternList()
+	return nil
+}
+
+// Mute adds a mute rule, a global one is not added again while it is active
+func (s *controlService) Mute(args *MuteArgs, reply *MuteReply) error {
+	muteRule := alarm.MuteRule{
+		Pattern:     args.Pattern,
+		Destination: args.Destination,
+		ExpiresAt:   args.ExpiresAt,
+	}
+	if !muteRule.IsGlobal() || !muteRule.ExpiresAt.IsZero() || !s.alarmer.IsMuted() {
+		if _, err := s.alarmer.AddMuteRule(muteRule); err != nil {
+			return err
+		}
+	}
+	*reply = s.muteState()
+	return nil
+}
+
+func (s *controlService) Unmute(args *UnmuteArgs, reply *MuteReply) error {
+	isRuleChosen := args.Pattern != "" || args.Destination != ""
+	if (args.Id != 0 && (args.All || isRuleChosen)) || (args.All && isRuleChosen) {
+		return errors.New("only one of id, all, and pattern with destination can be given")
+	}
+	var err error
+	switch {
+	case args.Id != 0:
+		_, err = s.alarmer.RemoveMuteRule(args.Id)
+	case args.All:
+		for _, muteRule := range s.alarmer.GetMuteRuleList() {
+			if _, err = s.alarmer.RemoveMuteRule(muteRule.Id); err != nil {
+				break
+			}
+		}
+	default:
+		_, err = s.alarmer.RemoveMuteRuleListOf(args.Pattern, args.Destination)
+	}
+	if err != nil {
+		return err
+	}
+	*reply = s.muteState()
+	return nil
+}
+
+func (s *controlService) GetMuteState(args *NoArgs, reply *MuteReply) error {
+	*reply = s.muteState()
+	return nil
+}
+
+func (s *controlService) TestAlarm(args *TestAlarmArgs, reply *NoArgs) error {
+	return s.alarmer.SendTestAlarm(args.Destination)
+}
+
+// StartShellCommand and FinishShellCommand are called by shell hooks of "alarm shell-init"
+func (s *controlService) StartShellCommand(args *ShellCommand, reply *NoArgs) error {
+	if args.ShellPid <= 0 || args.StartedAt.IsZero() {
+		return errors.New("shellPid and startedAt are required")
+	}
+	s.alarmer.StartShellCommand(*args)
+	return nil
+}
+
+func (s *controlService) FinishShellCommand(args *ShellCommand, reply *NoArgs) error {
+	if args.ShellPid <= 0 || args.StartedAt.IsZero() || args.FinishedAt.IsZero() {
+		return errors.New("shellPid, startedAt and finishedAt are required")
+	}
+	s.alarmer.FinishShellCommand(*args)
+	return nil
+}
+
+func (s *controlService) GetAlarmCounts(args *NoArgs, reply *map[string]int) error {
+	*reply = s.alarmer.GetAlarmCountMap()
+	return nil
+}
+
+func (s *controlService) ExplainProcess(args *PidArgs, reply *alarm.ProcessExplanation) error {
+	if args.Pid <= 0 {
+		return errors.New("pid should be positive")
+	}
+	processExplanation, err := s.alarmer.ExplainProcess(args.Pid)
+	if err != nil {
+		return err
+	}
+	*reply = processExplanation
+	return nil
+}
+
+func (s *controlService) muteState() MuteReply {
+	return MuteReply{
+		IsMuted:      s.alarmer.IsMuted(),
+		MuteRuleList: s.alarmer.GetMuteRuleList(),
+	}
+}
+
+func (s *controlService) patternList() PatternListReply {
+	return PatternListReply{
+		MonitoringCommandList: s.alarmer.GetMonitoringCommandList(),
+		Added:                 s.alarmer.GetAddedMonitoringCommandList(),
+		Removed:               s.alarmer.GetRemovedMonitoringCommandList(),
+		Tracked:               s.alarmer.GetTrackedMonitoringCommandList(),
+	}
+}
+
+// ControlClient calls methods of ControlServer of running daemon
+type ControlClient struct {
+	client *rpc.Client
+}
+
+func DialControlServer(socketPath string) (*ControlClient, error) {
+	conn, err := net.DialTimeout("unix", socketPath, controlDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &ControlClient{
+		client: jsonrpc.NewClient(conn),
+	}, nil
+}
+
+func (cc *ControlClient) call(method string, args interface{}, reply interface{}) error {
+	return cc.client.Call(ControlServiceName+"."+method, args, reply)
+}
+
+func (cc *ControlClient) ListPatterns() (PatternListReply, error) {
+	reply := PatternListReply{}
+	err := cc.call("ListPatterns", NoArgs{}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) ListProcesses() ([]TrackedProcess, error) {
+	reply := []TrackedProcess{}
+	err := cc.call("ListProcesses", NoArgs{}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) AddPattern(pattern string) (PatternListReply, error) {
+	reply := PatternListReply{}
+	err := cc.call("AddPattern", PatternArgs{Pattern: pattern}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) RemovePattern(pattern string) (PatternListReply, error) {
+	reply := PatternListReply{}
+	err := cc.call("RemovePattern", PatternArgs{Pattern: pattern}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) Mute(muteArgs MuteArgs) (MuteReply, error) {
+	reply := MuteReply{}
+	err := cc.call("Mute", muteArgs, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) Unmute(unmuteArgs UnmuteArgs) (MuteReply, error) {
+	reply := MuteReply{}
+	err := cc.call("Unmute", unmuteArgs, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) GetMuteState() (MuteReply, error) {
+	reply := MuteReply{}
+	err := cc.call("GetMuteState", NoArgs{}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) TestAlarm(destination string) error {
+	return cc.call("TestAlarm", TestAlarmArgs{Destination: destination}, &NoArgs{})
+}
+
+func (cc *ControlClient) StartShellCommand(shellCommand ShellCommand) error {
+	return cc.call("StartShellCommand", shellCommand, &NoArgs{})
+}
+
+func (cc *ControlClient) FinishShellCommand(shellCommand ShellCommand) error {
+	return cc.call("FinishShellCommand", shellCommand, &NoArgs{})
+}
+
+func (cc *ControlClient) GetAlarmCounts() (map[string]int, error) {
+	reply := map[string]int{}
+	err := cc.call("GetAlarmCounts", NoArgs{}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) ExplainProcess(pid int) (alarm.ProcessExplanation, error) {
+	reply := alarm.ProcessExplanation{}
+	err := cc.call("ExplainProcess", PidArgs{Pid: pid}, &reply)
+	return reply, err
+}
+
+func (cc *ControlClient) Close() error {
+	return cc.client.Close()
+}
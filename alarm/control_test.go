@@ -0,0 +1,287 @@
+package alarm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControlServer(t *testing.T) {
+	t.Run("Patterns", CheckControlPatterns("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("Mute", CheckControlMute("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("AnotherDaemon", CheckControlAnotherDaemon("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("ShellCommand", CheckControlShellCommand("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("ExpectedDuration", CheckControlExpectedDuration("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("ExplainProcess", CheckControlExplainProcess("test_config_for_slack_webhook_alarmer.json"))
+}
+
+func startControlServer(t *testing.T, configPath string) (Alarmer, *ControlServer, *ControlClient, func()) {
+	dir, err := ioutil.TempDir("", "control")
+	require.NoError(t, err)
+
+	alarmer := NewAlarmer(configPath)
+	controlServer, err := NewControlServer(alarmer, filepath.Join(dir, "runtime", ControlSocketFileName))
+	require.NoError(t, err)
+	controlClient, err := DialControlServer(controlServer.GetSocketPath())
+	require.NoError(t, err)
+
+	return alarmer, controlServer, controlClient, func() {
+		controlClient.Close()
+		controlServer.Stop()
+		alarmer.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+func CheckControlPatterns(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, _, controlClient, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+
+		patternList, err := controlClient.AddPattern("make e2e")
+		require.NoError(t, err)
+		require.Equal(t, []string{"bash test", "THERE WILL BE NO PROCESS LIKE THIS", "make e2e"}, patternList.MonitoringCommandList)
+		require.Equal(t, []string{"make e2e"}, patternList.Added)
+		require.Contains(t, patternList.Tracked, "make e2e")
+
+		patternList, err = controlClient.RemovePattern("bash test")
+		require.NoError(t, err)
+		require.Equal(t, []string{"THERE WILL BE NO PROCESS LIKE THIS", "make e2e"}, patternList.MonitoringCommandList)
+		require.Equal(t, []string{"bash test"}, patternList.Removed)
+		require.Equal(t, patternList.MonitoringCommandList, alarmer.GetMonitoringCommandList())
+
+		patternList, err = controlClient.ListPatterns()
+		require.NoError(t, err)
+		require.NotContains(t, patternList.Tracked, "bash test")
+
+		_, err = controlClient.AddPattern("")
+		require.Error(t, err)
+		// keys of watched processes are not patterns
+		_, err = controlClient.AddPattern(alarm.WatchedPidMonitoringCommand(1))
+		require.Error(t, err)
+
+		processList, err := controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Empty(t, processList)
+	}
+}
+
+func CheckControlMute(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, _, controlClient, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+
+		muteState, err := controlClient.Mute(MuteArgs{})
+		require.NoError(t, err)
+		require.True(t, muteState.IsMuted)
+		require.True(t, alarmer.IsMuted())
+		require.Equal(t, 1, len(muteState.MuteRuleList))
+
+		// muted alarms are counted
+		count := 2
+		executeBashScriptManyTime(count)
+		time.Sleep(time.Second)
+		alarmCountMap, err := controlClient.GetAlarmCounts()
+		require.NoError(t, err)
+		require.Equal(t, count, alarmCountMap["bash test"])
+
+		muteState, err = controlClient.Unmute(UnmuteArgs{})
+		require.NoError(t, err)
+		require.False(t, muteState.IsMuted)
+		require.Empty(t, muteState.MuteRuleList)
+
+		// rules of a pattern or a destination don't mute every alarm, and they expire
+		muteState, err = controlClient.Mute(MuteArgs{Pattern: "bash test", ExpiresAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		require.False(t, muteState.IsMuted)
+		muteState, err = controlClient.Mute(MuteArgs{Destination: DefaultDestinationName})
+		require.NoError(t, err)
+		require.Equal(t, 2, len(muteState.MuteRuleList))
+		_, err = controlClient.Mute(MuteArgs{Destination: "nowhere"})
+		require.EqualError(t, err, `destination "nowhere" is not found in config`)
+		_, err = controlClient.Mute(MuteArgs{ExpiresAt: time.Now().Add(-time.Hour)})
+		require.EqualError(t, err, "mute rule should expire in the future")
+
+		muteState, err = controlClient.Unmute(UnmuteArgs{Id: muteState.MuteRuleList[0].Id})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(muteState.MuteRuleList))
+		require.Equal(t, DefaultDestinationName, muteState.MuteRuleList[0].Destination)
+		_, err = controlClient.Unmute(UnmuteArgs{All: true, Pattern: "make"})
+		require.Error(t, err)
+		muteState, err = controlClient.Unmute(UnmuteArgs{All: true})
+		require.NoError(t, err)
+		require.Empty(t, muteState.MuteRuleList)
+
+		// web hook of test config is not reachable
+		require.Error(t, controlClient.TestAlarm(""))
+		require.EqualError(t, controlClient.TestAlarm("nowhere"), `destination "nowhere" is not found in config`)
+	}
+}
+
+func CheckControlAnotherDaemon(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, controlServer, _, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+
+		_, err := NewControlServer(alarmer, controlServer.GetSocketPath())
+		require.Error(t, err)
+
+		// socket left by stopped daemon is replaced
+		socketPath := controlServer.GetSocketPath()
+		controlServer.Stop()
+		require.NoError(t, ioutil.WriteFile(socketPath, []byte{}, 0600))
+		controlServer, err = NewControlServer(alarmer, socketPath)
+		require.NoError(t, err)
+		controlServer.Stop()
+
+		// socket is taken before requests are served, so the daemon prepares stores alone
+		controlServer = &ControlServer{}
+		controlServer.Init(alarmer, socketPath)
+		require.NoError(t, controlServer.Listen())
+		_, err = NewControlServer(alarmer, socketPath)
+		require.Error(t, err)
+		require.NoError(t, controlServer.Start())
+		listeningControlClient, err := DialControlServer(socketPath)
+		require.NoError(t, err)
+		_, err = listeningControlClient.ListProcesses()
+		require.NoError(t, err)
+		listeningControlClient.Close()
+		controlServer.Stop()
+	}
+}
+
+func CheckControlShellCommand(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, _, controlClient, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+		alarmer.SetMuted(true)
+
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		runHistoryStore, err := alarm.OpenRunHistoryStore(filepath.Join(dir, alarm.RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		alarmer.SetRunHistoryStore(runHistoryStore)
+
+		// pid of this test is used as shell because shell commands of exited shell are released
+		startedAt := time.Now().Add(-time.Minute)
+		shellCommand := ShellCommand{
+			ShellPid:  os.Getpid(),
+			Command:   "npm run build",
+			Directory: os.TempDir(),
+			StartedAt: startedAt,
+		}
+		require.NoError(t, controlClient.StartShellCommand(shellCommand))
+		processList, err := controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Equal(t, 1, len(processList))
+		require.Equal(t, ShellMonitoringCommand, processList[0].MonitoringCommand)
+		require.Equal(t, "npm run build", processList[0].Cmd)
+
+		// command longer than shellCommandThreshold is alarmed
+		shellCommand.FinishedAt = time.Now()
+		shellCommand.ExitStatus = 2
+		require.NoError(t, controlClient.FinishShellCommand(shellCommand))
+		require.Equal(t, 1, alarmer.GetTotalAlarmCountOfMonitoringCommand(ShellMonitoringCommand))
+		processList, err = controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Empty(t, processList)
+
+		// start which arrives after finish is ignored
+		require.NoError(t, controlClient.StartShellCommand(shellCommand))
+		processList, err = controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Empty(t, processList)
+
+		// command shorter than shellCommandThreshold is recorded without alarm
+		shellCommand.StartedAt = time.Now()
+		shellCommand.FinishedAt = shellCommand.StartedAt.Add(time.Second)
+		require.NoError(t, controlClient.FinishShellCommand(shellCommand))
+		require.Equal(t, 1, alarmer.GetTotalAlarmCountOfMonitoringCommand(ShellMonitoringCommand))
+		finishedRunList := runHistoryStore.GetFinishedRunList()
+		require.Equal(t, 2, len(finishedRunList))
+		require.Equal(t, time.Second, finishedRunList[1].Duration())
+
+		require.Error(t, controlClient.FinishShellCommand(ShellCommand{ShellPid: os.Getpid()}))
+	}
+}
+
+func CheckControlExpectedDuration(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, _, controlClient, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+		alarmer.SetMuted(true)
+
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		runHistoryStore, err := alarm.OpenRunHistoryStore(filepath.Join(dir, alarm.RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		alarmer.SetRunHistoryStore(runHistoryStore)
+
+		shellCommand := ShellCommand{
+			ShellPid:  os.Getpid(),
+			Directory: os.TempDir(),
+		}
+		// random ports are normalized, so the runs are the same job
+		for i, duration := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+			shellCommand.Command = fmt.Sprintf("npm run build --port=%d", 3000+i)
+			shellCommand.StartedAt = time.Now().Add(-duration)
+			shellCommand.FinishedAt = time.Now()
+			require.NoError(t, controlClient.FinishShellCommand(shellCommand))
+		}
+
+		shellCommand.Command = "npm run build --port=4000"
+		shellCommand.StartedAt = time.Now()
+		shellCommand.FinishedAt = time.Time{}
+		require.NoError(t, controlClient.StartShellCommand(shellCommand))
+		processList, err := controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Equal(t, 1, len(processList))
+		require.Equal(t, "npm run build --port=*", processList[0].JobKey)
+		require.Equal(t, 2*time.Minute, processList[0].ExpectedDuration.Round(time.Second))
+
+		// other commands have no expected duration yet
+		shellCommand.Command = "npm test"
+		shellCommand.StartedAt = time.Now()
+		require.NoError(t, controlClient.StartShellCommand(shellCommand))
+		processList, err = controlClient.ListProcesses()
+		require.NoError(t, err)
+		require.Equal(t, 1, len(processList))
+		require.Zero(t, processList[0].ExpectedDuration)
+	}
+}
+
+func CheckControlExplainProcess(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		alarmer, _, controlClient, cleanup := startControlServer(t, configPath)
+		defer cleanup()
+		alarmer.SetDaemonPid(os.Getpid())
+
+		processExplanation, err := controlClient.ExplainProcess(os.Getpid())
+		require.NoError(t, err)
+		require.Equal(t, os.Getpid(), processExplanation.Pid)
+		require.Equal(t, "it is the daemon", processExplanation.IgnoredReason)
+		// patterns of config and the environment marker are explained
+		require.Equal(t, 3, len(processExplanation.PatternExplanationList))
+		for _, patternExplanation := range processExplanation.PatternExplanationList {
+			require.False(t, patternExplanation.IsTracked)
+			if patternExplanation.MonitoringCommand == alarm.EnvironmentMarkerMonitoringCommand(alarm.DefaultConfig().EnvironmentMarker) {
+				require.Equal(t, "it is the daemon", patternExplanation.Reason)
+			} else {
+				require.Equal(t, "its command doesn't contain the pattern", patternExplanation.Reason)
+			}
+		}
+
+		_, err = controlClient.ExplainProcess(0)
+		require.EqualError(t, err, "pid should be positive")
+	}
+}
@@ -0,0 +1,237 @@
+package alarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// maxHeldAlarmCount bounds alarms held for a destination, older ones are dropped so that digest fits in a message
+const maxHeldAlarmCount = 100
+
+// DigestStatus is the status in the message of alarms which are held outside of delivery window
+const DigestStatus = "DIGEST"
+
+// HeldAlarmFileName is the file which keeps held alarms, so that their digest is sent after restart
+const HeldAlarmFileName = "held.json"
+
+// heldAlarms is alarms which are held until the delivery window of destination opens
+type heldAlarms struct {
+	destination   NamedDestination
+	heldSince     time.Time
+	heldAlarmList []HeldAlarm
+	droppedCount  int
+}
+
+// HeldAlarm is an alarm held outside of delivery window, alarms of the same job key are batched in digest
+type HeldAlarm struct {
+	JobKey string `json:"jobKey"`
+	Msg    string `json:"msg"`
+}
+
+// heldAlarmsOfFile is held alarms of a destination in the held alarm file
+type heldAlarmsOfFile struct {
+	Destination   string      `json:"destination"`
+	HeldSince     time.Time   `json:"heldSince"`
+	HeldAlarmList []HeldAlarm `json:"heldAlarmList"`
+	DroppedCount  int         `json:"droppedCount,omitempty"`
+}
+
+// HeldAlarmPath is held.json in $XDG_STATE_HOME/alarm-for-programmer
+func HeldAlarmPath() string {
+	return filepath.Join(alarm.StateDirectory(), HeldAlarmFileName)
+}
+
+// SetHeldAlarmPath keeps held alarms in the file, and holds alarms of the file again.
+// alarms held in memory are replaced, so it is set before the alarmer is started.
+// alarms of destinations which are not in config any more are lost
+func (a *SlackWebHookAlarmer) SetHeldAlarmPath(path string) error {
+	a.mutexForHeldAlarmsMap.Lock()
+	defer a.mutexForHeldAlarmsMap.Unlock()
+	a.heldAlarmPath = path
+	a.heldAlarmsByDestination = map[string]*heldAlarms{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heldAlarmsOfFileList := []heldAlarmsOfFile{}
+	if err := json.Unmarshal(data, &heldAlarmsOfFileList); err != nil {
+		return fmt.Errorf("%s is broken: %v", path, err)
+	}
+	config := a.configMonitor.GetConfig()
+	for _, heldAlarmsOfFile := range heldAlarmsOfFileList {
+		alarmConfig, err := FindDestination(config, heldAlarmsOfFile.Destination)
+		if err != nil {
+			errMsg := fmt.Sprintf(
+				"error occured during loading held alarms, %d alarms are lost: %v",
+				len(heldAlarmsOfFile.HeldAlarmList)+heldAlarmsOfFile.DroppedCount, err,
+			)
+			fmt.Println(errMsg)
+			continue
+		}
+		a.heldAlarmsByDestination[heldAlarmsOfFile.Destination] = &heldAlarms{
+			destination:   NamedDestination{Name: heldAlarmsOfFile.Destination, AlarmConfig: alarmConfig},
+			heldSince:     heldAlarmsOfFile.HeldSince,
+			heldAlarmList: heldAlarmsOfFile.HeldAlarmList,
+			droppedCount:  heldAlarmsOfFile.DroppedCount,
+		}
+	}
+	return nil
+}
+
+// deliver sends msg about the run to destinations which are not muted.
+// alarms outside of delivery window of destination are held for digest or dropped by its outsidePolicy.
+// muted and dropped alarms are counted and the run is recorded before, so only sending is skipped
+func (a *SlackWebHookAlarmer) deliver(run alarm.Run, destinationList []NamedDestination, msg string) {
+	muteRuleStore := a.getMuteRuleStore()
+	jobKey := a.jobKeyOf(run.Command)
+	now := time.Now()
+	for _, destination := range destinationList {
+		if _, ok := muteRuleStore.FindMatchedRule(run.MonitoringCommand, jobKey, destination.Name, now); ok {
+			continue
+		}
+		if !isDeliveredNow(destination, run, jobKey, now) {
+			if destination.DeliveryWindow.OutsidePolicy != alarm.DropOutsidePolicy {
+				a.holdAlarm(destination, HeldAlarm{JobKey: jobKey, Msg: msg}, now)
+			}
+			continue
+		}
+		go a.sendMessage(destination.AlarmConfig, msg)
+	}
+}
+
+// holdAlarm keeps the alarm until the delivery window of destination opens
+func (a *SlackWebHookAlarmer) holdAlarm(destination NamedDestination, heldAlarm HeldAlarm, now time.Time) {
+	a.mutexForHeldAlarmsMap.Lock()
+	defer a.mutexForHeldAlarmsMap.Unlock()
+	held, ok := a.heldAlarmsByDestination[destination.Name]
+	if !ok {
+		held = &heldAlarms{
+			heldSince: now,
+		}
+		a.heldAlarmsByDestination[destination.Name] = held
+	}
+	held.destination = destination
+	held.heldAlarmList = append(held.heldAlarmList, heldAlarm)
+	if len(held.heldAlarmList) > maxHeldAlarmCount {
+		held.droppedCount += len(held.heldAlarmList) - maxHeldAlarmCount
+		held.heldAlarmList = held.heldAlarmList[len(held.heldAlarmList)-maxHeldAlarmCount:]
+	}
+	a.saveHeldAlarms()
+}
+
+// sendDigestIfWindowOpen sends held alarms of each destination as one message when its delivery window opens.
+// destination is looked up again because config could be changed while alarms are held
+func (a *SlackWebHookAlarmer) sendDigestIfWindowOpen() {
+	config := a.configMonitor.GetConfig()
+	now := time.Now()
+
+	a.mutexForHeldAlarmsMap.Lock()
+	defer a.mutexForHeldAlarmsMap.Unlock()
+	isSent := false
+	for name, held := range a.heldAlarmsByDestination {
+		destination := held.destination
+		if alarmConfig, err := FindDestination(config, name); err == nil {
+			destination.AlarmConfig = alarmConfig
+		}
+		if !destination.DeliveryWindow.IsOpen(now) {
+			continue
+		}
+		delete(a.heldAlarmsByDestination, name)
+		isSent = true
+		go a.sendMessage(destination.AlarmConfig, FormatDigest(held.heldAlarmList, held.droppedCount, held.heldSince))
+	}
+	if isSent {
+		a.saveHeldAlarms()
+	}
+}
+
+// saveHeldAlarms writes held alarms into the held alarm file, it is called with mutexForHeldAlarmsMap locked
+func (a *SlackWebHookAlarmer) saveHeldAlarms() {
+	if a.heldAlarmPath == "" {
+		return
+	}
+	heldAlarmsOfFileList := []heldAlarmsOfFile{}
+	for name, held := range a.heldAlarmsByDestination {
+		heldAlarmsOfFileList = append(heldAlarmsOfFileList, heldAlarmsOfFile{
+			Destination:   name,
+			HeldSince:     held.heldSince,
+			HeldAlarmList: held.heldAlarmList,
+			DroppedCount:  held.droppedCount,
+		})
+	}
+	sort.Slice(heldAlarmsOfFileList, func(i, j int) bool {
+		return heldAlarmsOfFileList[i].Destination < heldAlarmsOfFileList[j].Destination
+	})
+	if err := writeFileAtomically(a.heldAlarmPath, heldAlarmsOfFileList); err != nil {
+		errMsg := fmt.Sprintf("error occured during saving held alarms into %s: %v", a.heldAlarmPath, err)
+		fmt.Println(errMsg)
+	}
+}
+
+// writeFileAtomically writes json of v into a temporary file and renames it, so that the file is never half written
+func writeFileAtomically(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// FormatDigest puts alarms held since heldSince into one message, an alarm per line.
+// alarms are batched by job key under a line of the job, in the order their first alarms are held
+func FormatDigest(heldAlarmList []HeldAlarm, droppedCount int, heldSince time.Time) string {
+	msg := fmt.Sprintf("STATUS=%s | ALARMS=%d | SINCE=%s", DigestStatus, len(heldAlarmList)+droppedCount, heldSince.Format("2006-01-02 15:04"))
+	if droppedCount != 0 {
+		msg += fmt.Sprintf(" | DROPPED=%d", droppedCount)
+	}
+	jobKeyList := []string{}
+	msgListByJobKey := map[string][]string{}
+	for _, heldAlarm := range heldAlarmList {
+		if _, ok := msgListByJobKey[heldAlarm.JobKey]; !ok {
+			jobKeyList = append(jobKeyList, heldAlarm.JobKey)
+		}
+		msgListByJobKey[heldAlarm.JobKey] = append(msgListByJobKey[heldAlarm.JobKey], heldAlarm.Msg)
+	}
+	for _, jobKey := range jobKeyList {
+		msg += fmt.Sprintf("\nJOB=%s | ALARMS=%d\n", jobKey, len(msgListByJobKey[jobKey]))
+		msg += strings.Join(msgListByJobKey[jobKey], "\n")
+	}
+	return msg
+}
+
+// isDeliveredNow reports whether the alarm of run is sent to destination now,
+// it is sent outside of delivery window only if it is urgent
+func isDeliveredNow(destination NamedDestination, run alarm.Run, jobKey string, now time.Time) bool {
+	deliveryWindow := destination.DeliveryWindow
+	return deliveryWindow.IsOpen(now) || deliveryWindow.IsUrgent(run.MonitoringCommand, jobKey, run.Status() == alarm.RunFailed)
+}
@@ -0,0 +1,35 @@
+package alarm
+
+import (
+	"fmt"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+const (
+	// DefaultDestinationName is the name of alarmConfig when destinations are chosen by name
+	DefaultDestinationName = "alarmConfig"
+
+	// LabelEnvironmentVariable of a process replaces its monitoringCommand in alarm, e.g. ALARM_LABEL=nightly-build
+	LabelEnvironmentVariable = "ALARM_LABEL"
+	// DestinationEnvironmentVariable of a process chooses destinations by name, e.g. ALARM_DEST=team,me
+	DestinationEnvironmentVariable = "ALARM_DEST"
+)
+
+// NamedDestination is a destination of config with its name, which mute rules choose
+type NamedDestination struct {
+	Name string
+	alarm.AlarmConfig
+}
+
+// FindDestination finds named destination of config, alarmConfig is returned for empty name
+func FindDestination(config alarm.Config, name string) (alarm.AlarmConfig, error) {
+	if name == "" || name == DefaultDestinationName {
+		return config.AlarmConfig, nil
+	}
+	destination, ok := config.Destinations[name]
+	if !ok {
+		return alarm.AlarmConfig{}, fmt.Errorf("destination %q is not found in config", name)
+	}
+	return destination, nil
+}
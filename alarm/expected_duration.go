@@ -0,0 +1,107 @@
+package alarm
+
+import (
+	"fmt"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// AlmostDoneStatus is the status in alarm of running process which ran almostDonePercent of its expected duration
+const AlmostDoneStatus = "ALMOST_DONE"
+
+// almostDoneKey identifies a run which is alarmed as almost done
+type almostDoneKey struct {
+	monitoringCommand string
+	pid               int
+	startedAt         int64
+}
+
+// getDurationStatistics returns statistics of former runs of the command, they are empty without run history
+func (a *SlackWebHookAlarmer) getDurationStatistics(run alarm.Run) alarm.DurationStatistics {
+	runHistoryStore := a.getRunHistoryStore()
+	if runHistoryStore == nil {
+		return alarm.NewDurationStatistics(nil)
+	}
+	return runHistoryStore.GetDurationStatistics(run)
+}
+
+// alarmIfExpectedToBeLong alarms the start of the process with its expected finish,
+// when former runs took at least minimumDuration, so that its finish is going to be alarmed too
+func (a *SlackWebHookAlarmer) alarmIfExpectedToBeLong(namePattern string, processStatusHistory []alarm.ProcessStatus) {
+	config := a.configMonitor.GetConfig()
+	processStatus := processStatusHistory[len(processStatusHistory)-1]
+	processInfo := processStatus.ProcessInfo()
+	if a.isAlarmedByAnotherPattern(namePattern, processInfo) {
+		return
+	}
+	run := alarm.NewRunOfProcessStatus(namePattern, processStatusHistory)
+	expectedDuration, ok := a.getDurationStatistics(run).ExpectedDuration()
+	if !ok || expectedDuration < a.minimumDurationOf(config, processInfo) {
+		return
+	}
+	msg := fmt.Sprintf("MonitoringCommand=%s | PID=%d | STATUS=%s", a.labelOf(namePattern, processInfo), run.Pid, alarm.ProcessStarted)
+	msg += FormatExpectedFinish(run.StartedAt, expectedDuration, time.Now())
+	msg += FormatProject(a.projectFinder.Find(processInfo.BinaryLocation()))
+	a.deliver(run, a.findDestinationListOfProcess(config, run.Pid, processInfo), msg)
+}
+
+// alarmIfAlmostDone alarms running processes and shell commands once when they ran almostDonePercent of their expected duration.
+// it is called only by the monitoring go routine, so isAlmostDoneAlarmedByKey has no mutex
+func (a *SlackWebHookAlarmer) alarmIfAlmostDone() {
+	config := a.configMonitor.GetConfig()
+	// runs which are not running any more are forgotten
+	isAlarmedByKey := map[almostDoneKey]bool{}
+	defer func() {
+		a.isAlmostDoneAlarmedByKey = isAlarmedByKey
+	}()
+	if config.AlmostDonePercent == 0 {
+		return
+	}
+
+	for _, namePattern := range a.processInfoMonitor.GetTrackedMonitoringCommandList() {
+		for pid, processStatusHistory := range a.processInfoMonitor.GetProcessStatusLogByMonitoringCommand(namePattern) {
+			processStatus := processStatusHistory[len(processStatusHistory)-1]
+			processInfo := processStatus.ProcessInfo()
+			if processStatus.Status() != alarm.ProcessStarted || a.isAlarmedByAnotherPattern(namePattern, processInfo) {
+				continue
+			}
+			msg := fmt.Sprintf("MonitoringCommand=%s | PID=%d | STATUS=%s", a.labelOf(namePattern, processInfo), pid, AlmostDoneStatus)
+			a.alarmIfRunAlmostDone(
+				config, alarm.NewRunOfProcessStatus(namePattern, processStatusHistory), a.minimumDurationOf(config, processInfo), msg,
+				a.findDestinationListOfProcess(config, pid, processInfo), isAlarmedByKey,
+			)
+		}
+	}
+	for _, shellCommand := range a.getRunningShellCommandList() {
+		msg := fmt.Sprintf("Command=%s | SHELL=%d | STATUS=%s", a.jobKeyOf(shellCommand.Command), shellCommand.ShellPid, AlmostDoneStatus)
+		a.alarmIfRunAlmostDone(
+			config, shellCommand.run(), config.ShellCommandThreshold, msg,
+			a.findDestinationListOfDirectory(config, shellCommand.Directory), isAlarmedByKey,
+		)
+	}
+}
+
+// alarmIfRunAlmostDone alarms the run to destinationList, minimumDuration is the one which its finish alarm is sent over
+func (a *SlackWebHookAlarmer) alarmIfRunAlmostDone(config alarm.Config, run alarm.Run, minimumDuration time.Duration, msg string, destinationList []NamedDestination, isAlarmedByKey map[almostDoneKey]bool) {
+	key := almostDoneKey{
+		monitoringCommand: run.MonitoringCommand,
+		pid:               run.Pid,
+		startedAt:         run.StartedAt.UnixNano(),
+	}
+	if a.isAlmostDoneAlarmedByKey[key] {
+		isAlarmedByKey[key] = true
+		return
+	}
+	expectedDuration, ok := a.getDurationStatistics(run).ExpectedDuration()
+	// runs which are too short to be alarmed are not almost done either
+	if !ok || expectedDuration < minimumDuration {
+		return
+	}
+	if time.Since(run.StartedAt) < expectedDuration*time.Duration(config.AlmostDonePercent)/100 {
+		return
+	}
+	isAlarmedByKey[key] = true
+	msg += FormatExpectedFinish(run.StartedAt, expectedDuration, time.Now())
+	a.deliver(run, destinationList, msg)
+}
@@ -8,8 +8,12 @@ import (
 
 type Alarmer interface {
 	GetMonitoringCommandList() []string
+	GetAddedMonitoringCommandList() []string
+	GetRemovedMonitoringCommandList() []string
+	GetTrackedMonitoringCommandList() []string
+	GetTrackedProcessList() []TrackedProcess
 	GetMonitoringPeriod() time.Duration
-	GetAlarmConfig() map[string]string
+	GetAlarmConfig() alarm.AlarmConfig
 	GetAlarmCountMap() map[string]int
 	GetTotalAlarmCountOfMonitoringCommand(monitoringCommand string) int
 
@@ -18,5 +22,37 @@ type Alarmer interface {
 	Start()
 	IsStarted() bool
 
+	AddMonitoringCommand(namePattern string)
+	RemoveMonitoringCommand(namePattern string)
+	SetMuted(isMuted bool)
+	IsMuted() bool
+	AddMuteRule(muteRule alarm.MuteRule) (alarm.MuteRule, error)
+	RemoveMuteRule(id int) (alarm.MuteRule, error)
+	RemoveMuteRuleListOf(pattern string, destination string) ([]alarm.MuteRule, error)
+	GetMuteRuleList() []alarm.MuteRule
+	SendTestAlarm(destinationName string) error
+	StartShellCommand(shellCommand ShellCommand)
+	FinishShellCommand(shellCommand ShellCommand)
+	SetRunHistoryStore(runHistoryStore *alarm.RunHistoryStore)
+	SetMuteRuleStore(muteRuleStore *alarm.MuteRuleStore)
+	SetHeldAlarmPath(path string) error
+	SetDaemonPid(daemonPid int)
+	ExplainProcess(pid int) (alarm.ProcessExplanation, error)
+
 	Stop()
 }
+
+// TrackedProcess is a running process which is matched by a tracked namePattern,
+// or a running shell command whose Pid is the pid of the shell
+type TrackedProcess struct {
+	MonitoringCommand string `json:"monitoringCommand"`
+	Pid               int    `json:"pid"`
+	Cmd               string `json:"cmd"`
+	// JobKey is Cmd normalized by commandNormalization of config
+	JobKey string `json:"jobKey"`
+	// MatchedPatternList is monitoringCommands which matched the process, the first one has the highest priority
+	MatchedPatternList []string  `json:"matchedPatternList,omitempty"`
+	StartedAt          time.Time `json:"startedAt"`
+	// ExpectedDuration is the median duration of former runs of the command, 0 if it is not known
+	ExpectedDuration time.Duration `json:"expectedDuration,omitempty"`
+}
@@ -0,0 +1,191 @@
+package alarm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// FormatProcessTreeSummary describes the job of finished process, which is appended to alarm message
+func FormatProcessTreeSummary(processTreeSummary alarm.ProcessTreeSummary) string {
+	if processTreeSummary.ProcessCount == 0 {
+		return ""
+	}
+	msg := ""
+	if processTreeSummary.ExitCode != nil {
+		msg += fmt.Sprintf(" | EXIT=%d", *processTreeSummary.ExitCode)
+	}
+	msg += fmt.Sprintf(
+		" | PROCESSES=%d | CPU=%s | PEAK_MEMORY=%s",
+		processTreeSummary.ProcessCount,
+		processTreeSummary.CpuTime.Round(10*time.Millisecond),
+		formatBytes(processTreeSummary.PeakMemory),
+	)
+	failedProcess := processTreeSummary.FailedProcess
+	if failedProcess.Pid != 0 {
+		// command of process which finished before it is found is not known
+		cmd := failedProcess.Cmd
+		if cmd == "" {
+			cmd = "unknown command"
+		}
+		msg += fmt.Sprintf(" | FAILED=%s (PID=%d, EXIT=%d)", cmd, failedProcess.Pid, failedProcess.ExitCode)
+	}
+	return msg
+}
+
+// FormatMatchedPatternList describes monitoringCommands which matched the process when there are several ones,
+// which is appended to alarm message
+func FormatMatchedPatternList(matchedPatternList []string) string {
+	if len(matchedPatternList) < 2 {
+		return ""
+	}
+	return fmt.Sprintf(" | PATTERNS=%s", strings.Join(matchedPatternList, ","))
+}
+
+// FormatProject describes the project which command runs in, which is appended to alarm message
+func FormatProject(project alarm.Project, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" | PROJECT=%s", project)
+}
+
+// FormatGitContext describes repository, branch and commit of working directory, which is appended to alarm message
+func FormatGitContext(gitContext alarm.GitContext, ok bool) string {
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" | GIT=%s", gitContext)
+}
+
+// FormatDurationStatistics describes how long former runs of the command took, which is appended to alarm message
+func FormatDurationStatistics(durationStatistics alarm.DurationStatistics) string {
+	expectedDuration, ok := durationStatistics.ExpectedDuration()
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf(" | EXPECTED=%s | P90=%s", expectedDuration.Round(time.Second), durationStatistics.P90.Round(time.Second))
+}
+
+// FormatExpectedFinish describes when the running command is expected to finish, e.g. " | ETA=15:04:05 (in 1m30s)"
+func FormatExpectedFinish(startedAt time.Time, expectedDuration time.Duration, now time.Time) string {
+	return " | ETA=" + DescribeExpectedFinish(startedAt, expectedDuration, now)
+}
+
+// DescribeExpectedFinish is the time when the command is expected to finish and how long it remains,
+// e.g. "15:04:05 (in 1m30s)" or "15:04:05 (overdue 5s)"
+func DescribeExpectedFinish(startedAt time.Time, expectedDuration time.Duration, now time.Time) string {
+	expectedFinishAt := startedAt.Add(expectedDuration)
+	remaining := expectedFinishAt.Sub(now).Round(time.Second)
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (overdue %s)", expectedFinishAt.Format("15:04:05"), -remaining)
+	}
+	return fmt.Sprintf("%s (in %s)", expectedFinishAt.Format("15:04:05"), remaining)
+}
+
+// FormatRegressionList describes how much longer or more memory the run took than the median of former runs
+func FormatRegressionList(regressionList []alarm.Regression) string {
+	msg := ""
+	for _, regression := range regressionList {
+		value, median := formatBytes(regression.Value), formatBytes(regression.Median)
+		if regression.Kind == alarm.DurationRegressionKind {
+			value = time.Duration(regression.Value).Round(time.Second).String()
+			median = time.Duration(regression.Median).Round(time.Second).String()
+		}
+		msg += fmt.Sprintf(
+			" | %s=%s (%.1fx of median %s over %d runs)",
+			regression.Kind, value, regression.Ratio(), median, regression.SampleCount,
+		)
+	}
+	return msg
+}
+
+func formatBytes(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGT"[exp])
+}
+
+const (
+	// slowestGolangTestCount is the number of slowest tests in alarm of go test
+	slowestGolangTestCount = 3
+	// golangBuildErrorLineCount is the number of lines of package which is not built
+	golangBuildErrorLineCount = 5
+)
+
+// FormatGolangTestReport describes results of go test per package, failed tests and the slowest tests.
+// each part is written in its own lines after the first line of alarm
+func FormatGolangTestReport(report *alarm.GolangTestReport) string {
+	msg := ""
+	if report.ModulePath != "" {
+		msg += fmt.Sprintf("\nMODULE=%s", report.ModulePath)
+	}
+	for _, packageResult := range report.GetPackageResultList() {
+		countByAction := packageResult.CountByAction()
+		name := report.RelativeImportPath(packageResult.ImportPath)
+		if packageResult.Name != "" {
+			name += fmt.Sprintf(" (%s)", packageResult.Name)
+		}
+		msg += fmt.Sprintf(
+			"\n%s %s | PASSED=%d | FAILED=%d | SKIPPED=%d | ELAPSED=%s",
+			formatGolangTestAction(packageResult.Action), name,
+			countByAction[alarm.GolangTestPassed], countByAction[alarm.GolangTestFailed], countByAction[alarm.GolangTestSkipped],
+			packageResult.Elapsed.Round(10*time.Millisecond),
+		)
+		// package fails without failed test when it is not built
+		if packageResult.Action == alarm.GolangTestFailed && countByAction[alarm.GolangTestFailed] == 0 {
+			for _, line := range firstLines(packageResult.OutputLineList, golangBuildErrorLineCount) {
+				msg += "\n    " + line
+			}
+		}
+	}
+
+	failedTestResultList := report.GetFailedTestResultList()
+	if len(failedTestResultList) != 0 {
+		msg += "\nFAILED TESTS"
+		for _, testResult := range failedTestResultList {
+			msg += fmt.Sprintf("\n%s %s", report.RelativeImportPath(testResult.Package), testResult.Name)
+			for _, line := range testResult.FailureLineList() {
+				msg += "\n    " + line
+			}
+		}
+	}
+
+	slowestTestResultList := report.GetSlowestTestResultList(slowestGolangTestCount)
+	if len(slowestTestResultList) != 0 {
+		msg += "\nSLOWEST TESTS"
+		for _, testResult := range slowestTestResultList {
+			msg += fmt.Sprintf("\n%s %s %s", testResult.Elapsed.Round(10*time.Millisecond), report.RelativeImportPath(testResult.Package), testResult.Name)
+		}
+	}
+	return msg
+}
+
+func formatGolangTestAction(action string) string {
+	switch action {
+	case alarm.GolangTestPassed:
+		return "ok"
+	case alarm.GolangTestFailed:
+		return "FAIL"
+	case alarm.GolangTestSkipped:
+		return "?"
+	}
+	// package which is not finished, e.g. killed by interrupt
+	return "-"
+}
+
+func firstLines(lineList []string, count int) []string {
+	if len(lineList) > count {
+		return lineList[:count]
+	}
+	return lineList
+}
@@ -0,0 +1,74 @@
+package alarm
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// SetMuteRuleStore keeps mute rules in muteRuleStore, rules of the former store are dropped
+func (a *SlackWebHookAlarmer) SetMuteRuleStore(muteRuleStore *alarm.MuteRuleStore) {
+	a.mutexForMuteRuleStore.Lock()
+	defer a.mutexForMuteRuleStore.Unlock()
+	a.muteRuleStore = muteRuleStore
+}
+
+func (a *SlackWebHookAlarmer) getMuteRuleStore() *alarm.MuteRuleStore {
+	a.mutexForMuteRuleStore.Lock()
+	defer a.mutexForMuteRuleStore.Unlock()
+	return a.muteRuleStore
+}
+
+// AddMuteRule mutes alarms which the rule matches until it expires
+func (a *SlackWebHookAlarmer) AddMuteRule(muteRule alarm.MuteRule) (alarm.MuteRule, error) {
+	now := time.Now()
+	if muteRule.IsExpired(now) {
+		return alarm.MuteRule{}, errors.New("mute rule should expire in the future")
+	}
+	if muteRule.Destination != "" {
+		if _, err := FindDestination(a.configMonitor.GetConfig(), muteRule.Destination); err != nil {
+			return alarm.MuteRule{}, err
+		}
+	}
+	muteRule.CreatedAt = now
+	return a.getMuteRuleStore().AddRule(muteRule)
+}
+
+func (a *SlackWebHookAlarmer) RemoveMuteRule(id int) (alarm.MuteRule, error) {
+	return a.getMuteRuleStore().RemoveRule(id)
+}
+
+// RemoveMuteRuleListOf removes rules of exactly the pattern and the destination, they are global rules for empty ones
+func (a *SlackWebHookAlarmer) RemoveMuteRuleListOf(pattern string, destination string) ([]alarm.MuteRule, error) {
+	return a.getMuteRuleStore().RemoveRuleListOf(pattern, destination)
+}
+
+func (a *SlackWebHookAlarmer) GetMuteRuleList() []alarm.MuteRule {
+	return a.getMuteRuleStore().GetRuleList(time.Now())
+}
+
+// SetMuted adds a global mute rule which doesn't expire, or removes every global rule
+func (a *SlackWebHookAlarmer) SetMuted(isMuted bool) {
+	var err error
+	if !isMuted {
+		_, err = a.RemoveMuteRuleListOf("", "")
+	} else if !a.IsMuted() {
+		_, err = a.AddMuteRule(alarm.MuteRule{})
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("error occured during saving mute rules: %v", err)
+		fmt.Println(errMsg)
+	}
+}
+
+// IsMuted reports whether every alarm is muted by a global rule
+func (a *SlackWebHookAlarmer) IsMuted() bool {
+	for _, muteRule := range a.GetMuteRuleList() {
+		if muteRule.IsGlobal() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package alarm
+
+import (
+	"fmt"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// RegressionStatus is the status in alarm of finished run which took much longer or much more memory than former runs
+const RegressionStatus = "REGRESSION"
+
+// alarmIfRegression sends a separate alarm of the finished run when it is a regression,
+// msg identifies the run, e.g. "MonitoringCommand=make | PID=4242"
+func (a *SlackWebHookAlarmer) alarmIfRegression(config alarm.Config, run alarm.Run, msg string, destinationList []NamedDestination) {
+	runHistoryStore := a.getRunHistoryStore()
+	if runHistoryStore == nil {
+		return
+	}
+	regressionDetector := alarm.RegressionDetector{
+		Percent:            config.RegressionPercent,
+		MinimumSampleCount: config.RegressionSampleCount,
+	}
+	regressionList := regressionDetector.FindRegressionList(
+		run,
+		runHistoryStore.GetDurationStatistics(run),
+		runHistoryStore.GetPeakMemoryStatistics(run),
+	)
+	if len(regressionList) == 0 {
+		return
+	}
+	msg += fmt.Sprintf(" | STATUS=%s", RegressionStatus) + FormatRegressionList(regressionList)
+	a.deliver(run, destinationList, msg)
+}
@@ -0,0 +1,111 @@
+package alarm
+
+import (
+	"fmt"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer"
+)
+
+// ShellMonitoringCommand is the monitoringCommand of commands reported by shell hooks
+const ShellMonitoringCommand = "shell"
+
+// ShellCommand is an interactive command reported by hooks of "alarm shell-init".
+// shells know exact command line and exit status, which can only be approximated from /proc
+type ShellCommand struct {
+	ShellPid  int       `json:"shellPid"`
+	Command   string    `json:"command"`
+	Directory string    `json:"directory"`
+	StartedAt time.Time `json:"startedAt"`
+	// FinishedAt and ExitStatus are set only when the command is finished
+	FinishedAt time.Time `json:"finishedAt"`
+	ExitStatus int       `json:"exitStatus"`
+}
+
+func (sc ShellCommand) Duration() time.Duration {
+	return sc.FinishedAt.Sub(sc.StartedAt)
+}
+
+// run is the run of command in history, command is identified by its shell and start time
+func (sc ShellCommand) run() alarm.Run {
+	exitStatus := sc.ExitStatus
+	return alarm.Run{
+		MonitoringCommand: ShellMonitoringCommand,
+		Pid:               sc.ShellPid,
+		ProcessStartTime:  sc.StartedAt,
+		Command:           sc.Command,
+		Directory:         sc.Directory,
+		StartedAt:         sc.StartedAt,
+		FinishedAt:        sc.FinishedAt,
+		ExitCode:          &exitStatus,
+	}
+}
+
+// StartShellCommand keeps the command as running until it is finished
+func (a *SlackWebHookAlarmer) StartShellCommand(shellCommand ShellCommand) {
+	a.mutexForShellCommandMap.Lock()
+	defer a.mutexForShellCommandMap.Unlock()
+	// hooks report in background, so start of short command can arrive after its finish
+	if finishedStartTime, ok := a.finishedStartTimeByShellPid[shellCommand.ShellPid]; ok && !shellCommand.StartedAt.After(finishedStartTime) {
+		return
+	}
+	a.runningShellCommandByShellPid[shellCommand.ShellPid] = shellCommand
+}
+
+// FinishShellCommand records every command in run history, and alarms it if it ran longer than shellCommandThreshold of config
+func (a *SlackWebHookAlarmer) FinishShellCommand(shellCommand ShellCommand) {
+	a.mutexForShellCommandMap.Lock()
+	delete(a.runningShellCommandByShellPid, shellCommand.ShellPid)
+	a.finishedStartTimeByShellPid[shellCommand.ShellPid] = shellCommand.StartedAt
+	a.mutexForShellCommandMap.Unlock()
+
+	config := a.configMonitor.GetConfig()
+	duration := shellCommand.Duration()
+	if runHistoryStore := a.getRunHistoryStore(); runHistoryStore != nil {
+		isNewlyFinished, err := runHistoryStore.FinishRun(shellCommand.run())
+		if err != nil {
+			errMsg := fmt.Sprintf("error occured during recording command of shell %d: %v", shellCommand.ShellPid, err)
+			fmt.Println(errMsg)
+		}
+		if !isNewlyFinished {
+			return
+		}
+	}
+	if duration < config.ShellCommandThreshold {
+		return
+	}
+
+	a.mutexForAlarmCountMap.Lock()
+	a.alarmCountMap[ShellMonitoringCommand] += 1
+	a.mutexForAlarmCountMap.Unlock()
+
+	msg := fmt.Sprintf(
+		"Command=%s | SHELL=%d | STATUS=%s | EXIT=%d | DURATION=%s",
+		a.jobKeyOf(shellCommand.Command), shellCommand.ShellPid, alarm.ProcessFinished, shellCommand.ExitStatus, duration.Round(time.Second),
+	) + FormatDurationStatistics(a.getDurationStatistics(shellCommand.run()))
+	msg += FormatProject(a.projectFinder.Find(shellCommand.Directory))
+	msg += FormatGitContext(alarm.FindGitContext(shellCommand.Directory))
+	destinationList := a.findDestinationListOfDirectory(config, shellCommand.Directory)
+	a.deliver(shellCommand.run(), destinationList, msg)
+	a.alarmIfRegression(config, shellCommand.run(), fmt.Sprintf("Command=%s | SHELL=%d", a.jobKeyOf(shellCommand.Command), shellCommand.ShellPid), destinationList)
+}
+
+// getRunningShellCommandList releases commands of shells which are exited, e.g. by "exit" which is never finished
+func (a *SlackWebHookAlarmer) getRunningShellCommandList() []ShellCommand {
+	a.mutexForShellCommandMap.Lock()
+	defer a.mutexForShellCommandMap.Unlock()
+	for shellPid := range a.finishedStartTimeByShellPid {
+		if _, err := alarm.GetStartTimeOfProcessByPid(shellPid); err != nil {
+			delete(a.finishedStartTimeByShellPid, shellPid)
+		}
+	}
+	shellCommandList := []ShellCommand{}
+	for shellPid, shellCommand := range a.runningShellCommandByShellPid {
+		if _, err := alarm.GetStartTimeOfProcessByPid(shellPid); err != nil {
+			delete(a.runningShellCommandByShellPid, shellPid)
+			continue
+		}
+		shellCommandList = append(shellCommandList, shellCommand)
+	}
+	return shellCommandList
+}
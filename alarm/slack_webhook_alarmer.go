@@ -3,11 +3,17 @@ package alarm
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,22 +25,115 @@ type SlackWebHookAlarmer struct {
 
 	mutexForSynchronousMethodCall sync.Mutex
 
-	alreadyFinishedMonitoringCommandPidListMap map[string]([]int)
-
 	alarmCountMap         map[string]int
 	mutexForAlarmCountMap sync.Mutex
 
-	configMonitor      *alarm.ConfigMonitor
-	processInfoMonitor *alarm.ProcessInfoMonitor
+	// namePatterns added or removed at runtime, e.g. by control socket.
+	// they are applied on top of monitoringCommandList of config until restart
+	addedMonitoringCommandList    []string
+	removedMonitoringCommandList  []string
+	mutexForMonitoringCommandList sync.Mutex
+
+	// muteRuleStore keeps rules which stop sending alarms, it is in memory until a persistent one is set
+	muteRuleStore         *alarm.MuteRuleStore
+	mutexForMuteRuleStore sync.Mutex
+
+	// alarms held outside of delivery window by name of destination, they are kept after restart when heldAlarmPath is set
+	heldAlarmsByDestination map[string]*heldAlarms
+	heldAlarmPath           string
+	mutexForHeldAlarmsMap   sync.Mutex
+
+	// shell commands reported by shell hooks, finishedStartTimeByShellPid drops start which arrives after finish
+	runningShellCommandByShellPid map[int]ShellCommand
+	finishedStartTimeByShellPid   map[int]time.Time
+	mutexForShellCommandMap       sync.Mutex
+
+	// runHistoryStore records started and finished runs, finished runs are not alarmed again after restart
+	runHistoryStore         *alarm.RunHistoryStore
+	mutexForRunHistoryStore sync.Mutex
+
+	// commandNormalizer makes job keys of commands by commandNormalization of config
+	commandNormalizer         *alarm.CommandNormalizer
+	mutexForCommandNormalizer sync.Mutex
+
+	// runs which are alarmed as almost done, they are forgotten when they are finished
+	isAlmostDoneAlarmedByKey map[almostDoneKey]bool
+
+	configMonitor       *alarm.ConfigMonitor
+	processInfoMonitor  *alarm.ProcessInfoMonitor
+	projectConfigFinder *alarm.ProjectConfigFinder
+	projectFinder       *alarm.ProjectFinder
 }
 
 func (a *SlackWebHookAlarmer) Init(configMonitor *alarm.ConfigMonitor) {
 	a.configMonitor = configMonitor
 
-	a.alreadyFinishedMonitoringCommandPidListMap = map[string]([]int){}
 	a.alarmCountMap = map[string]int{}
+	a.runningShellCommandByShellPid = map[int]ShellCommand{}
+	a.finishedStartTimeByShellPid = map[int]time.Time{}
+	a.isAlmostDoneAlarmedByKey = map[almostDoneKey]bool{}
+	a.muteRuleStore = alarm.NewMuteRuleStore()
+	a.heldAlarmsByDestination = map[string]*heldAlarms{}
 
+	a.projectConfigFinder = alarm.NewProjectConfigFinder()
+	a.projectFinder = alarm.NewProjectFinder()
 	a.processInfoMonitor = alarm.NewProcessInfoMonitor(a.GetMonitoringCommandList())
+	a.processInfoMonitor.SetProjectConfigFinder(a.projectConfigFinder)
+	a.configMonitor.OnChange(a.applyConfigChange)
+	// config could be changed before the callback is registered
+	a.processInfoMonitor.SetMonitoringCommandList(a.GetMonitoringCommandList())
+	a.processInfoMonitor.SetEnvironmentMarker(a.configMonitor.GetConfig().EnvironmentMarker)
+	a.processInfoMonitor.SetPatternMatchPolicy(a.configMonitor.GetConfig().PatternMatchPolicy)
+	a.processInfoMonitor.SetIgnoreCommandList(a.configMonitor.GetConfig().IgnoreCommandList)
+	a.setCommandNormalization(a.configMonitor.GetConfig().CommandNormalization)
+}
+
+func (a *SlackWebHookAlarmer) applyConfigChange(oldConfig, newConfig alarm.Config) {
+	a.processInfoMonitor.SetEnvironmentMarker(newConfig.EnvironmentMarker)
+	a.processInfoMonitor.SetPatternMatchPolicy(newConfig.PatternMatchPolicy)
+	a.processInfoMonitor.SetIgnoreCommandList(newConfig.IgnoreCommandList)
+	if runHistoryStore := a.getRunHistoryStore(); runHistoryStore != nil {
+		runHistoryStore.SetRetention(newConfig.HistoryRetention, newConfig.HistoryMaxRunCount)
+	}
+	if !reflect.DeepEqual(oldConfig.CommandNormalization, newConfig.CommandNormalization) {
+		a.setCommandNormalization(newConfig.CommandNormalization)
+	}
+	if reflect.DeepEqual(oldConfig.MonitoringCommandList, newConfig.MonitoringCommandList) {
+		return
+	}
+	a.processInfoMonitor.SetMonitoringCommandList(a.GetMonitoringCommandList())
+}
+
+// AddMonitoringCommand monitors namePattern until restart without changing config
+func (a *SlackWebHookAlarmer) AddMonitoringCommand(namePattern string) {
+	a.mutexForMonitoringCommandList.Lock()
+	a.removedMonitoringCommandList = removeNamePattern(namePattern, a.removedMonitoringCommandList)
+	if !findNamePatternInMonitoringCommandList(namePattern, a.addedMonitoringCommandList) {
+		a.addedMonitoringCommandList = append(a.addedMonitoringCommandList, namePattern)
+	}
+	a.mutexForMonitoringCommandList.Unlock()
+	a.processInfoMonitor.SetMonitoringCommandList(a.GetMonitoringCommandList())
+}
+
+// RemoveMonitoringCommand stops monitoring namePattern until restart without changing config
+func (a *SlackWebHookAlarmer) RemoveMonitoringCommand(namePattern string) {
+	a.mutexForMonitoringCommandList.Lock()
+	a.addedMonitoringCommandList = removeNamePattern(namePattern, a.addedMonitoringCommandList)
+	if !findNamePatternInMonitoringCommandList(namePattern, a.removedMonitoringCommandList) {
+		a.removedMonitoringCommandList = append(a.removedMonitoringCommandList, namePattern)
+	}
+	a.mutexForMonitoringCommandList.Unlock()
+	a.processInfoMonitor.SetMonitoringCommandList(a.GetMonitoringCommandList())
+}
+
+func removeNamePattern(namePattern string, monitoringCommandList []string) []string {
+	remainingMonitoringCommandList := []string{}
+	for _, _namePattern := range monitoringCommandList {
+		if namePattern != _namePattern {
+			remainingMonitoringCommandList = append(remainingMonitoringCommandList, _namePattern)
+		}
+	}
+	return remainingMonitoringCommandList
 }
 
 // there will be only one go routine for monitoring ProcessInfo
@@ -57,83 +156,299 @@ func (a *SlackWebHookAlarmer) Start() {
 			}
 
 			a.alarmIfProcessFinished()
+			a.alarmIfAlmostDone()
+			a.sendDigestIfWindowOpen()
 			time.Sleep(a.GetMonitoringPeriod())
 		}
 	}()
 }
 
 func (a *SlackWebHookAlarmer) alarmIfProcessFinished() {
-	for _, namePattern := range a.GetMonitoringCommandList() {
-		processStatusList := a.findNewlyFinishedProcessesWithMonitoringCommand(namePattern)
-		a.alarm(namePattern, processStatusList)
+	for _, namePattern := range a.processInfoMonitor.GetTrackedMonitoringCommandList() {
+		processStatusHistoryMap := a.findNewlyFinishedProcessesWithMonitoringCommand(namePattern)
+		a.alarm(namePattern, processStatusHistoryMap)
 	}
 }
 
-func (a *SlackWebHookAlarmer) findNewlyFinishedProcessesWithMonitoringCommand(namePattern string) map[int]alarm.ProcessStatus {
-	newlyFinishedProcessStatusMap := map[int]alarm.ProcessStatus{}
+func findNamePatternInMonitoringCommandList(namePattern string, monitoringCommandList []string) bool {
+	for _, _namePattern := range monitoringCommandList {
+		if namePattern == _namePattern {
+			return true
+		}
+	}
+	return false
+}
+
+// findNewlyFinishedProcessesWithMonitoringCommand returns history of processes which are finished after last check.
+// finished processes are released from history of monitor, and started ones are recorded in run history
+func (a *SlackWebHookAlarmer) findNewlyFinishedProcessesWithMonitoringCommand(namePattern string) map[int]([]alarm.ProcessStatus) {
+	newlyFinishedProcessStatusHistoryMap := map[int]([]alarm.ProcessStatus){}
 
-	finishedProcessStatusMap := a.processInfoMonitor.GetProcessStatusLogByMonitoringCommand(namePattern)
-	alreadyFinishedPidList := a.alreadyFinishedMonitoringCommandPidListMap[namePattern]
-	for pid, processStatusHistory := range finishedProcessStatusMap {
+	runHistoryStore := a.getRunHistoryStore()
+	processStatusHistoryMap := a.processInfoMonitor.GetProcessStatusLogByMonitoringCommand(namePattern)
+	finishedPidList := []int{}
+	for pid, processStatusHistory := range processStatusHistoryMap {
 		processStatus := processStatusHistory[len(processStatusHistory)-1]
+		// a process tracked by several patterns by "all" patternMatchPolicy is recorded once, by the pattern which alarms it
+		isRecorded := runHistoryStore != nil && !a.isAlarmedByAnotherPattern(namePattern, processStatus.ProcessInfo())
+		if processStatus.Status() == alarm.ProcessStarted && isRecorded {
+			run := alarm.NewRunOfProcessStatus(namePattern, processStatusHistory)
+			// runs restored after restart are alarmed as started already
+			isNewlyStarted := !runHistoryStore.IsRecorded(run)
+			if err := runHistoryStore.StartRun(run); err != nil {
+				errMsg := fmt.Sprintf("error occured during recording run of %d: %v", pid, err)
+				fmt.Println(errMsg)
+			}
+			if isNewlyStarted {
+				a.alarmIfExpectedToBeLong(namePattern, processStatusHistory)
+			}
+		}
 		if processStatus.Status() != alarm.ProcessFinished {
 			continue
 		}
-		if !findPidInPidList(pid, alreadyFinishedPidList) {
-			newlyFinishedProcessStatusMap[pid] = processStatus
-			a.alreadyFinishedMonitoringCommandPidListMap[namePattern] = append(a.alreadyFinishedMonitoringCommandPidListMap[namePattern], pid)
+		finishedPidList = append(finishedPidList, pid)
+		if isRecorded {
+			// run which is restored after restart can be finished already
+			isNewlyFinished, err := runHistoryStore.FinishRun(alarm.NewRunOfProcessStatus(namePattern, processStatusHistory))
+			if err != nil {
+				errMsg := fmt.Sprintf("error occured during recording run of %d: %v", pid, err)
+				fmt.Println(errMsg)
+			}
+			if !isNewlyFinished {
+				continue
+			}
 		}
+		newlyFinishedProcessStatusHistoryMap[pid] = append([]alarm.ProcessStatus{}, processStatusHistory...)
 	}
-	return newlyFinishedProcessStatusMap
+	for _, pid := range finishedPidList {
+		a.processInfoMonitor.ReleaseProcessStatusHistory(namePattern, pid)
+	}
+	return newlyFinishedProcessStatusHistoryMap
 }
 
-func findPidInPidList(pid int, pidList []int) bool {
-	for _, _pid := range pidList {
-		if pid == _pid {
-			return true
+// durationOfLastRun returns how long the process ran until the last status of processStatusHistory
+func durationOfLastRun(processStatusHistory []alarm.ProcessStatus) time.Duration {
+	if len(processStatusHistory) < 2 {
+		return 0
+	}
+	finishedProcessStatus := processStatusHistory[len(processStatusHistory)-1]
+	startedProcessStatus := processStatusHistory[len(processStatusHistory)-2]
+	return finishedProcessStatus.TimeStamp().Sub(startedProcessStatus.TimeStamp())
+}
+
+func (a *SlackWebHookAlarmer) alarm(namePattern string, processStatusHistoryMap map[int]([]alarm.ProcessStatus)) {
+	config := a.configMonitor.GetConfig()
+
+	for pid, processStatusHistory := range processStatusHistoryMap {
+		processStatus := processStatusHistory[len(processStatusHistory)-1]
+		processInfo := processStatus.ProcessInfo()
+		destinationList := a.findDestinationListOfProcess(config, pid, processInfo)
+		if durationOfLastRun(processStatusHistory) < a.minimumDurationOf(config, processInfo) {
+			continue
+		}
+
+		a.mutexForAlarmCountMap.Lock()
+		a.alarmCountMap[namePattern] += 1
+		a.mutexForAlarmCountMap.Unlock()
+		if a.isAlarmedByAnotherPattern(namePattern, processInfo) {
+			continue
+		}
+
+		header := fmt.Sprintf("MonitoringCommand=%s | PID=%d", a.labelOf(namePattern, processInfo), pid)
+		msg := header + fmt.Sprintf(" | STATUS=%s", processStatus.Status())
+		msg += FormatMatchedPatternList(processInfo.MatchedPatternList())
+		msg += FormatProject(a.projectFinder.Find(processInfo.BinaryLocation()))
+		run := alarm.NewRunOfProcessStatus(namePattern, processStatusHistory)
+		if processStatus.Status() == alarm.ProcessFinished {
+			msg += fmt.Sprintf(" | DURATION=%s", durationOfLastRun(processStatusHistory).Round(time.Second))
+			// the run is recorded already, so it is excluded from its own statistics
+			msg += FormatDurationStatistics(a.getDurationStatistics(run))
+			msg += FormatGitContext(alarm.FindGitContext(processInfo.BinaryLocation()))
+			msg += FormatProcessTreeSummary(processStatus.ProcessTreeSummary())
+		}
+		a.deliver(run, destinationList, msg)
+		if processStatus.Status() == alarm.ProcessFinished {
+			a.alarmIfRegression(config, run, header, destinationList)
 		}
 	}
-	return false
 }
 
-func (a *SlackWebHookAlarmer) alarm(namePattern string, processStatusHistory map[int]alarm.ProcessStatus) {
-	a.mutexForSynchronousMethodCall.Lock()
-	defer a.mutexForSynchronousMethodCall.Unlock()
+// minimumDurationOf returns minimumDuration of project config of the process, or of config
+func (a *SlackWebHookAlarmer) minimumDurationOf(config alarm.Config, processInfo alarm.ProcessInfo) time.Duration {
+	projectConfig, ok := a.projectConfigFinder.Find(processInfo.BinaryLocation())
+	if ok && projectConfig.MinimumDuration != 0 {
+		return projectConfig.MinimumDuration
+	}
+	return config.MinimumDuration
+}
 
-	a.alarmCountMap[namePattern] += len(processStatusHistory)
-	alarmConfig := a.GetAlarmConfig()
+// findDestinationListOfProcess finds destinations of the directory of the process,
+// destinations chosen by the user who launched the process win over project config
+func (a *SlackWebHookAlarmer) findDestinationListOfProcess(config alarm.Config, pid int, processInfo alarm.ProcessInfo) []NamedDestination {
+	if destinationNames, ok := processInfo.AlarmEnvironmentVariable(DestinationEnvironmentVariable); ok && destinationNames != "" {
+		return a.findDestinationList(config, strings.Split(destinationNames, ","), fmt.Sprintf("process %d", pid))
+	}
+	return a.findDestinationListOfDirectory(config, processInfo.BinaryLocation())
+}
 
-	for pid, processStatus := range processStatusHistory {
-		go func() {
-			webHookUrl := alarmConfig["webHookUrl"]
-			msg := fmt.Sprintf("MonitoringCommand=%s | PID=%d | STATUS=%s", namePattern, pid, processStatus.Status())
+// isAlarmedByAnotherPattern reports whether the process is alarmed by another monitoringCommand which matched it first,
+// so that a process tracked by several monitoringCommands by "all" patternMatchPolicy is alarmed once
+func (a *SlackWebHookAlarmer) isAlarmedByAnotherPattern(namePattern string, processInfo alarm.ProcessInfo) bool {
+	matchedPatternList := processInfo.MatchedPatternList()
+	if len(matchedPatternList) == 0 || matchedPatternList[0] == namePattern {
+		return false
+	}
+	return findNamePatternInMonitoringCommandList(matchedPatternList[0], a.processInfoMonitor.GetTrackedMonitoringCommandList())
+}
 
-			data := map[string]string{
-				"text": msg,
-			}
-			rawData, _ := json.Marshal(data)
-			buff := bytes.NewBuffer(rawData)
-			requestTimeout, err := strconv.Atoi(alarmConfig["requestTimeout"])
-			if err != nil {
-				panic(err)
-			}
-			client := http.Client{
-				Transport: &http.Transport{
-					Dial: (&net.Dialer{
-						Timeout: time.Duration(requestTimeout) * time.Millisecond,
-					}).Dial,
-					TLSHandshakeTimeout: time.Duration(requestTimeout) * time.Millisecond,
-				},
-				Timeout: time.Duration(requestTimeout) * time.Millisecond,
-			}
-			_, err = client.Post(webHookUrl, "application/json", buff)
-			if err != nil {
-				log.Printf("web hook request is failed: %v\n", err)
-			}
-		}()
+// labelOf returns the name of process in alarm.
+// ALARM_LABEL of the process is used if it is set,
+// job key of command is used for process opted in by environment marker because its monitoringCommand is the marker
+func (a *SlackWebHookAlarmer) labelOf(namePattern string, processInfo alarm.ProcessInfo) string {
+	if label, ok := processInfo.AlarmEnvironmentVariable(LabelEnvironmentVariable); ok && label != "" {
+		return label
+	}
+	environmentMarker := a.configMonitor.GetConfig().EnvironmentMarker
+	if environmentMarker != "" && namePattern == alarm.EnvironmentMarkerMonitoringCommand(environmentMarker) {
+		return a.jobKeyOf(processInfo.Cmd())
+	}
+	return namePattern
+}
+
+// findDestinationListOfDirectory finds destinations which project config of directory chooses, alarmConfig by default
+func (a *SlackWebHookAlarmer) findDestinationListOfDirectory(config alarm.Config, directory string) []NamedDestination {
+	projectConfig, ok := a.projectConfigFinder.Find(directory)
+	if !ok || len(projectConfig.Destinations) == 0 {
+		return []NamedDestination{{Name: DefaultDestinationName, AlarmConfig: config.AlarmConfig}}
+	}
+	return a.findDestinationList(config, projectConfig.Destinations, "project "+projectConfig.Directory)
+}
+
+// findDestinationList finds destinations which are chosen by name.
+// unknown destination is replaced with default destination
+func (a *SlackWebHookAlarmer) findDestinationList(config alarm.Config, nameList []string, chooser string) []NamedDestination {
+	destinationList := []NamedDestination{}
+	for _, name := range nameList {
+		name = strings.TrimSpace(name)
+		destination, err := FindDestination(config, name)
+		if err != nil {
+			log.Printf("%v, default destination is used for %s\n", err, chooser)
+			name, destination = DefaultDestinationName, config.AlarmConfig
+		}
+		if name == "" {
+			name = DefaultDestinationName
+		}
+		destinationList = append(destinationList, NamedDestination{Name: name, AlarmConfig: destination})
+	}
+	return destinationList
+}
+
+func (a *SlackWebHookAlarmer) sendMessage(destination alarm.AlarmConfig, msg string) {
+	err := SendMessage(destination, msg)
+	if err != nil {
+		log.Printf("web hook request is failed: %v\n", a.configMonitor.Redact(err.Error()))
+	}
+}
+
+// SendMessage posts msg to the web hook of destination.
+// web hook url is redacted from the error because error of http client contains it
+func SendMessage(destination alarm.AlarmConfig, msg string) error {
+	data := map[string]string{
+		"text": msg,
+	}
+	if destination.Channel != "" {
+		data["channel"] = destination.Channel
+	}
+	rawData, _ := json.Marshal(data)
+	buff := bytes.NewBuffer(rawData)
+	requestTimeout := destination.RequestTimeout
+	client := http.Client{
+		Transport: &http.Transport{
+			Dial: (&net.Dialer{
+				Timeout: requestTimeout,
+			}).Dial,
+			TLSHandshakeTimeout: requestTimeout,
+		},
+		Timeout: requestTimeout,
+	}
+	resp, err := client.Post(destination.WebHookUrl.Value(), "application/json", buff)
+	if err != nil {
+		return errors.New(alarm.RedactSecrets(err.Error(), []string{destination.WebHookUrl.Value()}))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("web hook responded %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// SetRunHistoryStore records runs in runHistoryStore.
+// runs which were running when the daemon stopped are tracked again, and the ones finished in the meantime are alarmed
+func (a *SlackWebHookAlarmer) SetRunHistoryStore(runHistoryStore *alarm.RunHistoryStore) {
+	config := a.configMonitor.GetConfig()
+	runHistoryStore.SetRetention(config.HistoryRetention, config.HistoryMaxRunCount)
+	runHistoryStore.SetCommandNormalizer(a.getCommandNormalizer())
+	for _, run := range runHistoryStore.GetRunningRunList() {
+		if a.processInfoMonitor.RestoreRun(run) {
+			continue
+		}
+		// namePattern is removed while the daemon was stopped
+		if err := runHistoryStore.DiscardRun(run); err != nil {
+			errMsg := fmt.Sprintf("error occured during discarding run of %d: %v", run.Pid, err)
+			fmt.Println(errMsg)
+		}
+	}
+
+	a.mutexForRunHistoryStore.Lock()
+	defer a.mutexForRunHistoryStore.Unlock()
+	a.runHistoryStore = runHistoryStore
+}
+
+func (a *SlackWebHookAlarmer) getRunHistoryStore() *alarm.RunHistoryStore {
+	a.mutexForRunHistoryStore.Lock()
+	defer a.mutexForRunHistoryStore.Unlock()
+	return a.runHistoryStore
+}
+
+// setCommandNormalization applies rules to job keys of alarms and run history.
+// config is validated already, so rules which fail to compile are only reported
+func (a *SlackWebHookAlarmer) setCommandNormalization(commandNormalization alarm.CommandNormalization) {
+	commandNormalizer, err := alarm.NewCommandNormalizer(commandNormalization)
+	if err != nil {
+		errMsg := fmt.Sprintf("error occured during compiling commandNormalization: %v", err)
+		fmt.Println(errMsg)
+		commandNormalizer = nil
+	}
+	a.mutexForCommandNormalizer.Lock()
+	a.commandNormalizer = commandNormalizer
+	a.mutexForCommandNormalizer.Unlock()
+	if runHistoryStore := a.getRunHistoryStore(); runHistoryStore != nil {
+		runHistoryStore.SetCommandNormalizer(commandNormalizer)
 	}
 }
 
+func (a *SlackWebHookAlarmer) getCommandNormalizer() *alarm.CommandNormalizer {
+	a.mutexForCommandNormalizer.Lock()
+	defer a.mutexForCommandNormalizer.Unlock()
+	return a.commandNormalizer
+}
+
+// jobKeyOf is the name of command in alarms, commands of the same job have the same key
+func (a *SlackWebHookAlarmer) jobKeyOf(command string) string {
+	return a.getCommandNormalizer().JobKey(command)
+}
+
+// SetDaemonPid excludes the daemon which runs the alarmer and its descendants from monitoring
+func (a *SlackWebHookAlarmer) SetDaemonPid(daemonPid int) {
+	a.processInfoMonitor.SetDaemonPid(daemonPid)
+}
+
+// ExplainProcess tells why each tracked pattern tracks the running process or not
+func (a *SlackWebHookAlarmer) ExplainProcess(pid int) (alarm.ProcessExplanation, error) {
+	return a.processInfoMonitor.ExplainPid(pid)
+}
+
 func (a *SlackWebHookAlarmer) Stop() {
 	a.isStarted = false
 }
@@ -148,20 +463,111 @@ func (a *SlackWebHookAlarmer) GetTotalAlarmCountOfMonitoringCommand(namePattern
 	return count
 }
 
-func (a *SlackWebHookAlarmer) GetAlarmConfig() map[string]string {
+func (a *SlackWebHookAlarmer) GetAlarmConfig() alarm.AlarmConfig {
 	return a.configMonitor.GetAlarmConfig()
 }
 
 func (a *SlackWebHookAlarmer) GetAlarmCountMap() map[string]int {
-	return a.alarmCountMap
+	a.mutexForAlarmCountMap.Lock()
+	defer a.mutexForAlarmCountMap.Unlock()
+	alarmCountMap := map[string]int{}
+	for namePattern, count := range a.alarmCountMap {
+		alarmCountMap[namePattern] = count
+	}
+	return alarmCountMap
+}
+
+// SendTestAlarm sends a test message to the named destination of config
+func (a *SlackWebHookAlarmer) SendTestAlarm(destinationName string) error {
+	config := a.configMonitor.GetConfig()
+	destination, err := FindDestination(config, destinationName)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	msg := fmt.Sprintf("test notification of alarm-for-programmer | HOST=%s | DESTINATION=%s", hostname, destinationName)
+	err = SendMessage(destination, msg)
+	if err != nil {
+		return errors.New(a.configMonitor.Redact(err.Error()))
+	}
+	return nil
+}
+
+// GetTrackedProcessList returns running processes matched by tracked namePatterns and running shell commands
+func (a *SlackWebHookAlarmer) GetTrackedProcessList() []TrackedProcess {
+	trackedProcessList := []TrackedProcess{}
+	for _, namePattern := range a.processInfoMonitor.GetTrackedMonitoringCommandList() {
+		for pid, processStatusHistory := range a.processInfoMonitor.GetProcessStatusLogByMonitoringCommand(namePattern) {
+			processStatus := processStatusHistory[len(processStatusHistory)-1]
+			if processStatus.Status() != alarm.ProcessStarted {
+				continue
+			}
+			processInfo := processStatus.ProcessInfo()
+			expectedDuration, _ := a.getDurationStatistics(alarm.NewRunOfProcessStatus(namePattern, processStatusHistory)).ExpectedDuration()
+			trackedProcessList = append(trackedProcessList, TrackedProcess{
+				MonitoringCommand:  namePattern,
+				Pid:                pid,
+				Cmd:                processInfo.Cmd(),
+				JobKey:             a.jobKeyOf(processInfo.Cmd()),
+				MatchedPatternList: processInfo.MatchedPatternList(),
+				StartedAt:          processStatus.TimeStamp(),
+				ExpectedDuration:   expectedDuration,
+			})
+		}
+	}
+	for _, shellCommand := range a.getRunningShellCommandList() {
+		expectedDuration, _ := a.getDurationStatistics(shellCommand.run()).ExpectedDuration()
+		trackedProcessList = append(trackedProcessList, TrackedProcess{
+			MonitoringCommand: ShellMonitoringCommand,
+			Pid:               shellCommand.ShellPid,
+			Cmd:               shellCommand.Command,
+			JobKey:            a.jobKeyOf(shellCommand.Command),
+			StartedAt:         shellCommand.StartedAt,
+			ExpectedDuration:  expectedDuration,
+		})
+	}
+	sort.Slice(trackedProcessList, func(i, j int) bool {
+		return trackedProcessList[i].StartedAt.Before(trackedProcessList[j].StartedAt)
+	})
+	return trackedProcessList
 }
 
+// GetMonitoringCommandList returns monitoringCommandList of config with namePatterns added or removed at runtime
 func (a *SlackWebHookAlarmer) GetMonitoringCommandList() []string {
-	return a.configMonitor.GetMonitoringCommandList()
+	a.mutexForMonitoringCommandList.Lock()
+	defer a.mutexForMonitoringCommandList.Unlock()
+	monitoringCommandList := []string{}
+	for _, namePattern := range append(a.configMonitor.GetMonitoringCommandList(), a.addedMonitoringCommandList...) {
+		if findNamePatternInMonitoringCommandList(namePattern, a.removedMonitoringCommandList) ||
+			findNamePatternInMonitoringCommandList(namePattern, monitoringCommandList) {
+			continue
+		}
+		monitoringCommandList = append(monitoringCommandList, namePattern)
+	}
+	return monitoringCommandList
+}
+
+// GetAddedMonitoringCommandList returns namePatterns added at runtime
+func (a *SlackWebHookAlarmer) GetAddedMonitoringCommandList() []string {
+	a.mutexForMonitoringCommandList.Lock()
+	defer a.mutexForMonitoringCommandList.Unlock()
+	return append([]string{}, a.addedMonitoringCommandList...)
+}
+
+// GetRemovedMonitoringCommandList returns namePatterns removed at runtime
+func (a *SlackWebHookAlarmer) GetRemovedMonitoringCommandList() []string {
+	a.mutexForMonitoringCommandList.Lock()
+	defer a.mutexForMonitoringCommandList.Unlock()
+	return append([]string{}, a.removedMonitoringCommandList...)
+}
+
+// GetTrackedMonitoringCommandList returns monitoringCommandList and namePatterns found in project configs
+func (a *SlackWebHookAlarmer) GetTrackedMonitoringCommandList() []string {
+	return a.processInfoMonitor.GetTrackedMonitoringCommandList()
 }
 
 func (a *SlackWebHookAlarmer) GetMonitoringPeriod() time.Duration {
-	return time.Duration(a.configMonitor.GetMonitoringPeriod())
+	return a.configMonitor.GetMonitoringPeriod()
 }
 
 func (a *SlackWebHookAlarmer) IsStarted() bool {
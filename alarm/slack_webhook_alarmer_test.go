@@ -4,4 +4,10 @@ import "testing"
 
 func TestSlackWebhookAlarmer(t *testing.T) {
 	t.Run("AlarmCount", CheckAlarmCount("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("MonitoringCommandListChange", CheckMonitoringCommandListChange("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("RunHistory", CheckRunHistory("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("DeliveryWindow", CheckDeliveryWindow("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("StartAlarm", CheckStartAlarm("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("AlmostDoneAlarm", CheckAlmostDoneAlarm("test_config_for_slack_webhook_alarmer.json"))
+	t.Run("AllPatternMatchPolicy", CheckAllPatternMatchPolicy("test_config_for_slack_webhook_alarmer.json"))
 }
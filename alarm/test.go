@@ -1,11 +1,20 @@
 package alarm
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	alarm "github.com/goodahn/alarm-for-programmer"
 	"github.com/stretchr/testify/require"
 )
 
@@ -24,6 +33,36 @@ func CheckAlarmCount(configPath string) func(*testing.T) {
 	}
 }
 
+// CheckMonitoringCommandListChange checks that namePattern added to config file
+// is alarmed without restarting alarmer
+func CheckMonitoringCommandListChange(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		data, err := ioutil.ReadFile(configPath)
+		require.NoError(t, err)
+		changingConfigPath := "changing_" + configPath
+		defer os.Remove(changingConfigPath)
+		require.NoError(t, ioutil.WriteFile(
+			changingConfigPath,
+			bytes.Replace(data, []byte(`"bash test",`), []byte(``), 1),
+			0644))
+
+		alarmer := NewAlarmer(changingConfigPath)
+		defer alarmer.Stop()
+		require.True(t, alarmer.IsStarted())
+		require.NotContains(t, alarmer.GetMonitoringCommandList(), "bash test")
+
+		require.NoError(t, ioutil.WriteFile(changingConfigPath, data, 0644))
+		time.Sleep(500 * time.Millisecond)
+		require.Contains(t, alarmer.GetMonitoringCommandList(), "bash test")
+
+		count := 5
+		executeBashScriptManyTime(count)
+		time.Sleep(time.Second)
+
+		require.Equal(t, count, alarmer.GetTotalAlarmCountOfMonitoringCommand("bash test"))
+	}
+}
+
 func CheckGetMonitoringCommandList(configPath string) func(*testing.T) {
 	return func(t *testing.T) {
 		alarmer := NewAlarmer(configPath)
@@ -49,7 +88,7 @@ func CheckGetMonitoringPeriod(configPath string) func(*testing.T) {
 
 		require.Equal(
 			t,
-			time.Duration(50),
+			50*time.Millisecond,
 			alarmer.GetMonitoringPeriod(),
 		)
 	}
@@ -63,16 +102,359 @@ func CheckGetAlarmConfig(configPath string) func(*testing.T) {
 
 		require.Equal(
 			t,
-			map[string]string{
-				"type":           "slack-webhook",
-				"webHookUrl":     "localhost",
-				"requestTimeout": "10",
+			alarm.AlarmConfig{
+				Type:           alarm.SlackWebHookAlarmType,
+				WebHookUrl:     alarm.NewSecret("localhost"),
+				RequestTimeout: time.Millisecond,
 			},
 			alarmer.GetAlarmConfig(),
 		)
 	}
 }
 
+// CheckRunHistory checks that runs are recorded, and that they are not alarmed again after restart
+func CheckRunHistory(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		historyPath := filepath.Join(dir, alarm.RunHistoryFileName)
+
+		// run which was running when the daemon stopped, and finished in the meantime
+		runHistoryStore, err := alarm.OpenRunHistoryStore(historyPath, 0, 0)
+		require.NoError(t, err)
+		require.NoError(t, runHistoryStore.StartRun(alarm.Run{
+			MonitoringCommand: "bash test",
+			Pid:               os.Getpid(),
+			ProcessStartTime:  time.Unix(1, 0),
+			Command:           "bash test_monitoring_command.sh",
+			StartedAt:         time.Now().Add(-time.Minute),
+		}))
+
+		alarmer := NewAlarmer(configPath)
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		count := 2
+		executeBashScriptManyTime(count)
+		time.Sleep(time.Second)
+		alarmer.Stop()
+		require.Equal(t, count+1, alarmer.GetTotalAlarmCountOfMonitoringCommand("bash test"))
+		require.Equal(t, count+1, len(runHistoryStore.GetFinishedRunList()))
+		require.Empty(t, runHistoryStore.GetRunningRunList())
+		require.NoError(t, runHistoryStore.Close())
+
+		runHistoryStore, err = alarm.OpenRunHistoryStore(historyPath, 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		require.Equal(t, count+1, len(runHistoryStore.GetFinishedRunList()))
+		alarmer = NewAlarmer(configPath)
+		defer alarmer.Stop()
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		time.Sleep(500 * time.Millisecond)
+		require.Equal(t, 0, alarmer.GetTotalAlarmCountOfMonitoringCommand("bash test"))
+	}
+}
+
+// CheckDeliveryWindow checks that alarms outside of delivery window are held for digest or dropped,
+// that failures of urgent patterns are not held, and that held alarms are kept after restart
+func CheckDeliveryWindow(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "delivery-window")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		now := time.Now()
+		closedWindowList := []alarm.TimeWindow{
+			{Start: now.Add(time.Hour).Format("15:04"), End: now.Add(2 * time.Hour).Format("15:04")},
+		}
+		webHookConfigPath, webHook := prepareWebHookConfig(t, configPath, dir, map[string]interface{}{
+			"destinations": map[string]interface{}{
+				"night": map[string]interface{}{
+					"type":           "slack-webhook",
+					"webHookUrl":     "localhost",
+					"requestTimeout": "1",
+				},
+			},
+		})
+		defer webHook.Close()
+		heldAlarmPath := filepath.Join(dir, HeldAlarmFileName)
+
+		// alarmers are not started, so digest is sent only when it is asked
+		configMonitor := alarm.NewConfigMonitor(webHookConfigPath)
+		defer configMonitor.Stop()
+		alarmer := NewUnstartedAlarmerWithConfigMonitor(configMonitor).(*SlackWebHookAlarmer)
+		require.NoError(t, alarmer.SetHeldAlarmPath(heldAlarmPath))
+
+		closedDeliveryWindow := alarm.DeliveryWindow{
+			WindowList:        closedWindowList,
+			OutsidePolicy:     alarm.DigestOutsidePolicy,
+			UrgentPatternList: []string{"bash test"},
+		}
+		night := NamedDestination{Name: "night"}
+		night.DeliveryWindow = closedDeliveryWindow
+		quiet := NamedDestination{Name: "quiet"}
+		quiet.DeliveryWindow = closedDeliveryWindow
+		quiet.DeliveryWindow.OutsidePolicy = alarm.DropOutsidePolicy
+		exitCode := 0
+		run := alarm.Run{
+			MonitoringCommand: "bash test",
+			Command:           "bash test_monitoring_command.sh",
+			ExitCode:          &exitCode,
+		}
+
+		alarmer.deliver(run, []NamedDestination{night, quiet}, "first")
+		alarmer.deliver(run, []NamedDestination{night, quiet}, "second")
+		failedExitCode := 1
+		failedRun := run
+		failedRun.ExitCode = &failedExitCode
+		require.Equal(t, alarm.RunFailed, failedRun.Status())
+		alarmer.deliver(failedRun, []NamedDestination{night}, "failed")
+
+		heldAlarmList := []HeldAlarm{
+			{JobKey: "bash test_monitoring_command.sh", Msg: "first"},
+			{JobKey: "bash test_monitoring_command.sh", Msg: "second"},
+		}
+		alarmer.mutexForHeldAlarmsMap.Lock()
+		require.Equal(t, 1, len(alarmer.heldAlarmsByDestination))
+		require.Equal(t, heldAlarmList, alarmer.heldAlarmsByDestination["night"].heldAlarmList)
+		heldSince := alarmer.heldAlarmsByDestination["night"].heldSince
+		alarmer.mutexForHeldAlarmsMap.Unlock()
+
+		// held alarms are kept after restart
+		restartedAlarmer := NewUnstartedAlarmerWithConfigMonitor(configMonitor).(*SlackWebHookAlarmer)
+		require.NoError(t, restartedAlarmer.SetHeldAlarmPath(heldAlarmPath))
+		restartedAlarmer.mutexForHeldAlarmsMap.Lock()
+		require.Equal(t, 1, len(restartedAlarmer.heldAlarmsByDestination))
+		require.Equal(t, heldAlarmList, restartedAlarmer.heldAlarmsByDestination["night"].heldAlarmList)
+		require.True(t, heldSince.Equal(restartedAlarmer.heldAlarmsByDestination["night"].heldSince))
+		restartedAlarmer.mutexForHeldAlarmsMap.Unlock()
+
+		// night of config has no delivery window, so the window opens
+		alarmer.sendDigestIfWindowOpen()
+		alarmer.mutexForHeldAlarmsMap.Lock()
+		require.Empty(t, alarmer.heldAlarmsByDestination)
+		alarmer.mutexForHeldAlarmsMap.Unlock()
+		require.NoError(t, restartedAlarmer.SetHeldAlarmPath(heldAlarmPath))
+		restartedAlarmer.mutexForHeldAlarmsMap.Lock()
+		require.Empty(t, restartedAlarmer.heldAlarmsByDestination)
+		restartedAlarmer.mutexForHeldAlarmsMap.Unlock()
+
+		// alarms of the same job are batched
+		require.Equal(
+			t,
+			"STATUS=DIGEST | ALARMS=4 | SINCE=2024-05-01 22:00 | DROPPED=1\n"+
+				"JOB=make build | ALARMS=2\nsecond\nfourth\n"+
+				"JOB=go test ./... | ALARMS=1\nthird",
+			FormatDigest(
+				[]HeldAlarm{
+					{JobKey: "make build", Msg: "second"},
+					{JobKey: "go test ./...", Msg: "third"},
+					{JobKey: "make build", Msg: "fourth"},
+				},
+				1, time.Date(2024, 5, 1, 22, 0, 0, 0, time.Local),
+			),
+		)
+	}
+}
+
+// testWebHook keeps texts of messages sent to it
+type testWebHook struct {
+	*httptest.Server
+	msgList []string
+
+	mutexForMsgList sync.Mutex
+}
+
+func (twh *testWebHook) GetMessageList() []string {
+	twh.mutexForMsgList.Lock()
+	defer twh.mutexForMsgList.Unlock()
+	return append([]string{}, twh.msgList...)
+}
+
+func newTestWebHook() *testWebHook {
+	webHook := &testWebHook{}
+	webHook.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		webHook.mutexForMsgList.Lock()
+		defer webHook.mutexForMsgList.Unlock()
+		webHook.msgList = append(webHook.msgList, body["text"])
+	}))
+	return webHook
+}
+
+// prepareWebHookConfig writes config of configPath into dir, with fields of configChange and a web hook which keeps messages
+func prepareWebHookConfig(t *testing.T, configPath string, dir string, configChange map[string]interface{}) (string, *testWebHook) {
+	webHook := newTestWebHook()
+
+	data, err := ioutil.ReadFile(configPath)
+	require.NoError(t, err)
+	config := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(data, &config))
+	config["alarmConfig"].(map[string]interface{})["webHookUrl"] = webHook.URL
+	config["alarmConfig"].(map[string]interface{})["requestTimeout"] = "1s"
+	for field, value := range configChange {
+		config[field] = value
+	}
+	data, err = json.Marshal(config)
+	require.NoError(t, err)
+	webHookConfigPath := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(webHookConfigPath, data, 0644))
+	return webHookConfigPath, webHook
+}
+
+// CheckStartAlarm checks that a run whose former runs are long enough is alarmed when it starts, with its expected finish
+func CheckStartAlarm(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "start-alarm")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		webHookConfigPath, webHook := prepareWebHookConfig(t, configPath, dir, nil)
+		defer webHook.Close()
+
+		runHistoryStore, err := alarm.OpenRunHistoryStore(filepath.Join(dir, alarm.RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		now := time.Now()
+		for pid := 1; pid <= 3; pid++ {
+			exitCode := 0
+			_, err := runHistoryStore.FinishRun(alarm.Run{
+				MonitoringCommand: "bash test",
+				Pid:               pid,
+				Command:           "bash test_monitoring_command.sh",
+				StartedAt:         now.Add(-time.Hour - 2*time.Second),
+				FinishedAt:        now.Add(-time.Hour),
+				ExitCode:          &exitCode,
+			})
+			require.NoError(t, err)
+		}
+
+		alarmer := NewUnstartedAlarmerWithConfigMonitor(alarm.NewConfigMonitor(webHookConfigPath))
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		alarmer.Start()
+		defer alarmer.Stop()
+		c := exec.Command("bash", "test_monitoring_command.sh")
+		require.NoError(t, c.Start())
+		time.Sleep(time.Second)
+
+		msgList := webHook.GetMessageList()
+		require.Equal(t, 1, len(msgList))
+		require.True(t, strings.HasPrefix(msgList[0], "MonitoringCommand=bash test | PID="), msgList[0])
+		require.Contains(t, msgList[0], " | STATUS=PROCESS_STARTED | ETA=")
+
+		c.Wait()
+		time.Sleep(500 * time.Millisecond)
+		msgList = webHook.GetMessageList()
+		require.Equal(t, 2, len(msgList))
+		require.Contains(t, msgList[1], "STATUS=PROCESS_FINISHED")
+	}
+}
+
+// CheckAlmostDoneAlarm checks that almost done alarm is sent to destinations and over minimum duration of the finish alarm
+func CheckAlmostDoneAlarm(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "almost-done")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		meWebHook := newTestWebHook()
+		defer meWebHook.Close()
+		webHookConfigPath, webHook := prepareWebHookConfig(t, configPath, dir, map[string]interface{}{
+			"almostDonePercent":     25,
+			"shellCommandThreshold": "1h",
+			"destinations": map[string]interface{}{
+				"me": map[string]interface{}{
+					"type":           "slack-webhook",
+					"webHookUrl":     meWebHook.URL,
+					"requestTimeout": "1s",
+				},
+			},
+		})
+		defer webHook.Close()
+
+		runHistoryStore, err := alarm.OpenRunHistoryStore(filepath.Join(dir, alarm.RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		now := time.Now()
+		for pid := 1; pid <= 3; pid++ {
+			exitCode := 0
+			_, err := runHistoryStore.FinishRun(alarm.Run{
+				MonitoringCommand: "bash test",
+				Pid:               pid,
+				Command:           "bash test_monitoring_command.sh",
+				StartedAt:         now.Add(-time.Hour - 2*time.Second),
+				FinishedAt:        now.Add(-time.Hour),
+				ExitCode:          &exitCode,
+			})
+			require.NoError(t, err)
+			_, err = runHistoryStore.FinishRun(ShellCommand{
+				ShellPid:   pid,
+				Command:    "make build",
+				Directory:  dir,
+				StartedAt:  now.Add(-time.Hour - 2*time.Second),
+				FinishedAt: now.Add(-time.Hour),
+			}.run())
+			require.NoError(t, err)
+		}
+
+		alarmer := NewUnstartedAlarmerWithConfigMonitor(alarm.NewConfigMonitor(webHookConfigPath))
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		alarmer.Start()
+		defer alarmer.Stop()
+		// the user who launched the process chooses its destination
+		c := exec.Command("bash", "test_monitoring_command.sh")
+		c.Env = append(os.Environ(), DestinationEnvironmentVariable+"=me")
+		require.NoError(t, c.Start())
+		defer c.Wait()
+		// the command is shorter than shellCommandThreshold, so its finish is not alarmed and it is not almost done either
+		alarmer.StartShellCommand(ShellCommand{
+			ShellPid:  os.Getpid(),
+			Command:   "make build",
+			Directory: dir,
+			StartedAt: time.Now(),
+		})
+		time.Sleep(time.Second)
+
+		msgList := meWebHook.GetMessageList()
+		require.Equal(t, 2, len(msgList), msgList)
+		require.Contains(t, msgList[0], "STATUS=PROCESS_STARTED")
+		require.Contains(t, msgList[1], "STATUS="+AlmostDoneStatus)
+		require.Empty(t, webHook.GetMessageList())
+	}
+}
+
+// CheckAllPatternMatchPolicy checks that a process tracked by every matched pattern is recorded and alarmed once
+func CheckAllPatternMatchPolicy(configPath string) func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "all-pattern")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		webHookConfigPath, webHook := prepareWebHookConfig(t, configPath, dir, map[string]interface{}{
+			"monitoringCommandList": []string{"bash test", "bash test_monitoring"},
+			"patternMatchPolicy":    alarm.AllPatternMatchPolicy,
+		})
+		defer webHook.Close()
+
+		runHistoryStore, err := alarm.OpenRunHistoryStore(filepath.Join(dir, alarm.RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer runHistoryStore.Close()
+		alarmer := NewUnstartedAlarmerWithConfigMonitor(alarm.NewConfigMonitor(webHookConfigPath))
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		alarmer.Start()
+		defer alarmer.Stop()
+		executeBashScriptManyTime(1)
+		time.Sleep(500 * time.Millisecond)
+
+		// both patterns count the process, which is recorded and sent by the first one
+		require.Equal(t, 1, alarmer.GetTotalAlarmCountOfMonitoringCommand("bash test"))
+		require.Equal(t, 1, alarmer.GetTotalAlarmCountOfMonitoringCommand("bash test_monitoring"))
+		finishedRunList := runHistoryStore.GetFinishedRunList()
+		require.Equal(t, 1, len(finishedRunList))
+		require.Equal(t, "bash test", finishedRunList[0].MonitoringCommand)
+		require.Equal(t, []string{"bash test", "bash test_monitoring"}, finishedRunList[0].MatchedPatternList)
+		msgList := webHook.GetMessageList()
+		require.Equal(t, 1, len(msgList))
+		require.Contains(t, msgList[0], "PATTERNS=bash test,bash test_monitoring")
+	}
+}
+
 func executeBashScriptManyTime(count int) {
 	wg := sync.WaitGroup{}
 	for i := 0; i < count; i++ {
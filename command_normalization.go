@@ -0,0 +1,156 @@
+package alarm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxJobKeyCacheSize bounds the cache of job keys, it is larger than the number of distinct commands in usual history
+const maxJobKeyCacheSize = 10000
+
+// CommandNormalization is rules which turn command lines of the same job into the same job key,
+// e.g. "go test -count=1 ./..." and "go test ./..." are the same job.
+// they are applied in this order: arguments are dropped, paths are collapsed and then the command is rewritten
+type CommandNormalization struct {
+	// DropArgumentList is regular expressions of arguments which are removed from the command, e.g. "^-count="
+	DropArgumentList []string `json:"dropArgumentList"`
+	// CollapsePathList is directories whose paths in arguments are collapsed into their base name,
+	// e.g. "/tmp/go-build1234/b001/exe/main" is "main" when "/tmp" is in the list. "~" is the home directory
+	CollapsePathList []string `json:"collapsePathList"`
+	// RewriteList is regular expressions which are replaced in the command
+	RewriteList []CommandRewrite `json:"rewriteList"`
+}
+
+// CommandRewrite replaces matches of Pattern with Replacement, which can refer to groups of Pattern like ${1}
+type CommandRewrite struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+func DefaultCommandNormalization() CommandNormalization {
+	return CommandNormalization{
+		DropArgumentList: []string{`^-(test\.)?count=`},
+		CollapsePathList: []string{os.TempDir(), "~/.cache/go-build"},
+		RewriteList: []CommandRewrite{
+			{Pattern: `(-{1,2}port[= ]|localhost:|127\.0\.0\.1:)[0-9]+`, Replacement: "${1}*"},
+		},
+	}
+}
+
+// copy returns CommandNormalization which doesn't share lists with cn
+func (cn CommandNormalization) copy() CommandNormalization {
+	cn.DropArgumentList = copyStringList(cn.DropArgumentList)
+	cn.CollapsePathList = copyStringList(cn.CollapsePathList)
+	if cn.RewriteList != nil {
+		cn.RewriteList = append([]CommandRewrite{}, cn.RewriteList...)
+	}
+	return cn
+}
+
+// CommandNormalizer makes job keys by compiled rules of CommandNormalization.
+// nil CommandNormalizer only collapses spaces of commands
+type CommandNormalizer struct {
+	dropArgumentRegexpList []*regexp.Regexp
+	collapsePathList       []string
+	rewriteRegexpList      []*regexp.Regexp
+	replacementList        []string
+
+	jobKeyByCommand         map[string]string
+	mutexForJobKeyByCommand sync.Mutex
+}
+
+func NewCommandNormalizer(cn CommandNormalization) (*CommandNormalizer, error) {
+	normalizer := &CommandNormalizer{
+		jobKeyByCommand: map[string]string{},
+	}
+	for _, pattern := range cn.DropArgumentList {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q of dropArgumentList: %v", pattern, err)
+		}
+		normalizer.dropArgumentRegexpList = append(normalizer.dropArgumentRegexpList, r)
+	}
+	for _, path := range cn.CollapsePathList {
+		normalizer.collapsePathList = append(normalizer.collapsePathList, expandHomeDirectory(path))
+	}
+	for _, rewrite := range cn.RewriteList {
+		r, err := regexp.Compile(rewrite.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q of rewriteList: %v", rewrite.Pattern, err)
+		}
+		normalizer.rewriteRegexpList = append(normalizer.rewriteRegexpList, r)
+		normalizer.replacementList = append(normalizer.replacementList, rewrite.Replacement)
+	}
+	return normalizer, nil
+}
+
+func expandHomeDirectory(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(homeDirectory(os.Getenv), strings.TrimPrefix(path, "~"))
+}
+
+// JobKey is the key which runs of the same job share, it is used for statistics and names of commands in alarms
+func (cn *CommandNormalizer) JobKey(command string) string {
+	if cn == nil {
+		return strings.Join(strings.Fields(command), " ")
+	}
+	cn.mutexForJobKeyByCommand.Lock()
+	jobKey, ok := cn.jobKeyByCommand[command]
+	cn.mutexForJobKeyByCommand.Unlock()
+	if ok {
+		return jobKey
+	}
+
+	argumentList := []string{}
+	for _, argument := range strings.Fields(command) {
+		if cn.isDroppedArgument(argument) {
+			continue
+		}
+		argumentList = append(argumentList, cn.collapsePath(argument))
+	}
+	jobKey = strings.Join(argumentList, " ")
+	for i, r := range cn.rewriteRegexpList {
+		jobKey = r.ReplaceAllString(jobKey, cn.replacementList[i])
+	}
+	jobKey = strings.Join(strings.Fields(jobKey), " ")
+
+	cn.mutexForJobKeyByCommand.Lock()
+	defer cn.mutexForJobKeyByCommand.Unlock()
+	if len(cn.jobKeyByCommand) >= maxJobKeyCacheSize {
+		cn.jobKeyByCommand = map[string]string{}
+	}
+	cn.jobKeyByCommand[command] = jobKey
+	return jobKey
+}
+
+func (cn *CommandNormalizer) isDroppedArgument(argument string) bool {
+	for _, r := range cn.dropArgumentRegexpList {
+		if r.MatchString(argument) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapsePath collapses the argument, or the value of flag like "-o=/tmp/x/main", if it is a path in collapsePathList
+func (cn *CommandNormalizer) collapsePath(argument string) string {
+	prefix, path := "", argument
+	if i := strings.Index(argument, "="); i != -1 && !filepath.IsAbs(argument) {
+		prefix, path = argument[:i+1], argument[i+1:]
+	}
+	if !filepath.IsAbs(path) {
+		return argument
+	}
+	for _, directory := range cn.collapsePathList {
+		if isInDirectory(path, directory) && path != directory {
+			return prefix + filepath.Base(path)
+		}
+	}
+	return argument
+}
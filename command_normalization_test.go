@@ -0,0 +1,86 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandNormalizer(t *testing.T) {
+	t.Run("DefaultNormalization", CheckDefaultNormalization())
+	t.Run("CustomNormalization", CheckCustomNormalization())
+	t.Run("StatisticsOfJob", CheckStatisticsOfJob())
+}
+
+func CheckDefaultNormalization() func(*testing.T) {
+	return func(t *testing.T) {
+		cn, err := NewCommandNormalizer(DefaultCommandNormalization())
+		require.NoError(t, err)
+
+		require.Equal(t, "go test ./...", cn.JobKey("go  test -count=1 ./..."))
+		require.Equal(t, "pkg.test -test.v", cn.JobKey(filepath.Join(os.TempDir(), "go-build1234/b001/pkg.test")+" -test.count=1 -test.v"))
+		require.Equal(t, "main --port=*", cn.JobKey(filepath.Join(os.TempDir(), "go-build5678/b001/exe/main")+" --port=34567"))
+		require.Equal(t, "server -port * -db localhost:*", cn.JobKey("server -port 8080 -db localhost:5432"))
+		// paths out of collapsed directories are kept
+		require.Equal(t, "make -C /home/user/project", cn.JobKey("make -C /home/user/project"))
+
+		// nil normalizer only collapses spaces
+		var nilNormalizer *CommandNormalizer
+		require.Equal(t, "go test -count=1 ./...", nilNormalizer.JobKey(" go test   -count=1 ./... "))
+	}
+}
+
+func CheckCustomNormalization() func(*testing.T) {
+	return func(t *testing.T) {
+		cn, err := NewCommandNormalizer(CommandNormalization{
+			DropArgumentList: []string{`^--seed=`},
+			CollapsePathList: []string{"/var/build"},
+			RewriteList: []CommandRewrite{
+				{Pattern: `run-[0-9a-f]{8}`, Replacement: "run-*"},
+				{Pattern: ` --verbose`, Replacement: ""},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			"tool -o=out.bin run-* /tmp/x",
+			cn.JobKey("/var/build/1/tool --seed=42 -o=/var/build/2/out.bin run-deadbeef --verbose /tmp/x"),
+		)
+		// the directory itself is not collapsed
+		require.Equal(t, "ls /var/build", cn.JobKey("ls /var/build"))
+
+		_, err = NewCommandNormalizer(CommandNormalization{DropArgumentList: []string{"("}})
+		require.Error(t, err)
+	}
+}
+
+func CheckStatisticsOfJob() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		rhs, err := OpenRunHistoryStore(filepath.Join(dir, RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+
+		now := time.Now().UTC().Round(0)
+		for i, command := range []string{"go test -count=1 ./...", "go test ./...", "go test -count=1 ./..."} {
+			run := newTestRun(i+1, now.Add(-time.Duration(i+1)*time.Minute))
+			run.Command = command
+			_, err := rhs.FinishRun(finishTestRun(run, now, 0))
+			require.NoError(t, err)
+		}
+		runningRun := newTestRun(4, now)
+		runningRun.Command = "go test ./..."
+		require.Equal(t, 1, rhs.GetDurationStatistics(runningRun).SampleCount)
+
+		cn, err := NewCommandNormalizer(DefaultCommandNormalization())
+		require.NoError(t, err)
+		rhs.SetCommandNormalizer(cn)
+		require.Equal(t, 3, rhs.GetDurationStatistics(runningRun).SampleCount)
+	}
+}
@@ -0,0 +1,639 @@
+package alarm
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SlackWebHookAlarmType = "slack-webhook"
+
+	defaultMonitoringPeriod  = time.Second
+	defaultRequestTimeout    = 2 * time.Second
+	defaultEnvironmentMarker = "ALARM_ME"
+	// defaultShellCommandThreshold is long enough not to alarm commands like ls and git status
+	defaultShellCommandThreshold = 30 * time.Second
+	defaultHistoryRetention      = 90 * 24 * time.Hour
+	defaultHistoryMaxRunCount    = 10000
+	defaultRegressionPercent     = 150
+	defaultRegressionSampleCount = 10
+
+	// EnvironmentMarkerPrefix is the prefix which every environment marker should start with,
+	// only such variables are read from environment of processes
+	EnvironmentMarkerPrefix = "ALARM"
+)
+
+type Config struct {
+	MonitoringCommandList []string      `json:"monitoringCommandList"`
+	MonitoringPeriod      time.Duration `json:"monitoringPeriod"`
+	// MinimumDuration is the duration which process should run at least to be alarmed
+	MinimumDuration time.Duration `json:"minimumDuration"`
+	// AlarmConfig is the default destination of alarms
+	AlarmConfig AlarmConfig `json:"alarmConfig"`
+	// Destinations are named destinations which project config can choose
+	Destinations map[string]AlarmConfig `json:"destinations"`
+	// EnvironmentMarker is the environment variable which opts a process in, e.g. ALARM_ME=1 make build.
+	// empty marker disables it
+	EnvironmentMarker string `json:"environmentMarker"`
+	// ShellCommandThreshold is the duration which command reported by shell hooks should run at least to be alarmed
+	ShellCommandThreshold time.Duration `json:"shellCommandThreshold"`
+	// HistoryRetention is how long finished runs are kept in history, 0 keeps them forever
+	HistoryRetention time.Duration `json:"historyRetention"`
+	// HistoryMaxRunCount is the number of finished runs kept in history, 0 keeps every run
+	HistoryMaxRunCount int `json:"historyMaxRunCount"`
+	// AlmostDonePercent is the percentage of expected duration when running process is alarmed as almost done,
+	// 0 disables it
+	AlmostDonePercent int `json:"almostDonePercent"`
+	// RegressionPercent is how much of the median of former runs a run should take to be alarmed as regression,
+	// e.g. 150 for 1.5 times, 0 disables it
+	RegressionPercent int `json:"regressionPercent"`
+	// RegressionSampleCount is the number of former runs which are needed to find regression
+	RegressionSampleCount int `json:"regressionSampleCount"`
+	// CommandNormalization turns commands of the same job into the same job key
+	CommandNormalization CommandNormalization `json:"commandNormalization"`
+	// PatternMatchPolicy chooses which monitoringCommand tracks a process matched by several ones,
+	// it is one of PatternMatchPolicyList
+	PatternMatchPolicy string `json:"patternMatchPolicy"`
+	// IgnoreCommandList is argv[0] of commands which are never tracked by namePatterns, e.g. "jq".
+	// it is added to DefaultIgnoreCommandList
+	IgnoreCommandList []string `json:"ignoreCommandList"`
+}
+
+type AlarmConfig struct {
+	Type           string        `json:"type"`
+	WebHookUrl     Secret        `json:"webHookUrl"`
+	RequestTimeout time.Duration `json:"requestTimeout"`
+	// Channel overrides the channel of web hook, e.g. "#proj-x"
+	Channel string `json:"channel"`
+	// DeliveryWindow is when alarms are sent, e.g. to keep quiet at night
+	DeliveryWindow DeliveryWindow `json:"deliveryWindow"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MonitoringCommandList: []string{},
+		MonitoringPeriod:      defaultMonitoringPeriod,
+		AlarmConfig: AlarmConfig{
+			RequestTimeout: defaultRequestTimeout,
+		},
+		Destinations:          map[string]AlarmConfig{},
+		EnvironmentMarker:     defaultEnvironmentMarker,
+		ShellCommandThreshold: defaultShellCommandThreshold,
+		HistoryRetention:      defaultHistoryRetention,
+		HistoryMaxRunCount:    defaultHistoryMaxRunCount,
+		RegressionPercent:     defaultRegressionPercent,
+		RegressionSampleCount: defaultRegressionSampleCount,
+		CommandNormalization:  DefaultCommandNormalization(),
+		PatternMatchPolicy:    MostSpecificPatternMatchPolicy,
+		IgnoreCommandList:     []string{},
+	}
+}
+
+// SecretList returns resolved values of every secret in config
+// so that they can be redacted from messages
+func (c Config) SecretList() []string {
+	secretList := []string{}
+	for _, alarmConfig := range append([]AlarmConfig{c.AlarmConfig}, c.destinationList()...) {
+		if alarmConfig.WebHookUrl.Value() != "" {
+			secretList = append(secretList, alarmConfig.WebHookUrl.Value())
+		}
+	}
+	return secretList
+}
+
+func (c Config) destinationList() []AlarmConfig {
+	destinationList := []AlarmConfig{}
+	for _, destination := range c.Destinations {
+		destinationList = append(destinationList, destination)
+	}
+	return destinationList
+}
+
+// ConfigError is a problem of config found at Path, e.g. "$.alarmConfig.type"
+type ConfigError struct {
+	Path    string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ConfigErrorList contains every problem of config, not only the first one
+type ConfigErrorList []ConfigError
+
+func (l ConfigErrorList) Error() string {
+	msgList := []string{}
+	for _, e := range l {
+		msgList = append(msgList, e.Error())
+	}
+	return strings.Join(msgList, "\n")
+}
+
+// DecodeConfig converts raw config into Config and validates it.
+// resolveSecret is used for secret references, ResolveSecretReference is used if it is nil
+func DecodeConfig(rawConfig map[string]interface{}, resolveSecret func(reference string) (string, error)) (Config, error) {
+	if resolveSecret == nil {
+		resolveSecret = ResolveSecretReference
+	}
+	d := &configDecoder{
+		resolveSecret: resolveSecret,
+	}
+	config := d.decodeConfig(rawConfig, "$")
+	if len(d.errorList) != 0 {
+		return Config{}, d.errorList
+	}
+	return config, nil
+}
+
+type configDecoder struct {
+	errorList     ConfigErrorList
+	resolveSecret func(reference string) (string, error)
+}
+
+func (d *configDecoder) addError(path string, format string, args ...interface{}) {
+	d.errorList = append(d.errorList, ConfigError{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *configDecoder) decodeConfig(rawConfig map[string]interface{}, path string) Config {
+	config := DefaultConfig()
+	d.checkUnknownFields(rawConfig, path, "monitoringCommandList", "monitoringPeriod", "minimumDuration", "alarmConfig", "destinations", "environmentMarker", "shellCommandThreshold", "historyRetention", "historyMaxRunCount", "almostDonePercent", "regressionPercent", "regressionSampleCount", "commandNormalization", "patternMatchPolicy", "ignoreCommandList")
+
+	if val, ok := rawConfig["monitoringCommandList"]; ok {
+		config.MonitoringCommandList = d.decodeMonitoringCommandList(val, path+".monitoringCommandList")
+	}
+	if val, ok := rawConfig["monitoringPeriod"]; ok {
+		config.MonitoringPeriod = d.decodeDuration(val, path+".monitoringPeriod")
+	}
+	if val, ok := rawConfig["minimumDuration"]; ok {
+		config.MinimumDuration = d.decodeNonNegativeDuration(val, path+".minimumDuration")
+	}
+	if val, ok := rawConfig["alarmConfig"]; ok {
+		config.AlarmConfig = d.decodeAlarmConfig(val, path+".alarmConfig")
+	} else {
+		d.addError(path+".alarmConfig", "required")
+	}
+	if val, ok := rawConfig["destinations"]; ok {
+		config.Destinations = d.decodeDestinations(val, path+".destinations")
+	}
+	if val, ok := rawConfig["environmentMarker"]; ok {
+		config.EnvironmentMarker = d.decodeEnvironmentMarker(val, path+".environmentMarker")
+	}
+	if val, ok := rawConfig["shellCommandThreshold"]; ok {
+		config.ShellCommandThreshold = d.decodeNonNegativeDuration(val, path+".shellCommandThreshold")
+	}
+	if val, ok := rawConfig["historyRetention"]; ok {
+		config.HistoryRetention = d.decodeNonNegativeDuration(val, path+".historyRetention")
+	}
+	if val, ok := rawConfig["historyMaxRunCount"]; ok {
+		config.HistoryMaxRunCount = d.decodeNonNegativeInteger(val, path+".historyMaxRunCount")
+	}
+	if val, ok := rawConfig["almostDonePercent"]; ok {
+		config.AlmostDonePercent = d.decodeNonNegativeInteger(val, path+".almostDonePercent")
+		if config.AlmostDonePercent >= 100 {
+			d.addError(path+".almostDonePercent", "should be less than 100, not %d", config.AlmostDonePercent)
+		}
+	}
+	if val, ok := rawConfig["regressionPercent"]; ok {
+		config.RegressionPercent = d.decodeNonNegativeInteger(val, path+".regressionPercent")
+		if config.RegressionPercent != 0 && config.RegressionPercent <= 100 {
+			d.addError(path+".regressionPercent", "should be more than 100, not %d", config.RegressionPercent)
+		}
+	}
+	if val, ok := rawConfig["regressionSampleCount"]; ok {
+		config.RegressionSampleCount = d.decodeNonNegativeInteger(val, path+".regressionSampleCount")
+		if config.RegressionSampleCount == 0 || config.RegressionSampleCount > DurationSampleCount {
+			d.addError(path+".regressionSampleCount", "should be between 1 and %d, not %d", DurationSampleCount, config.RegressionSampleCount)
+		}
+	}
+	if val, ok := rawConfig["commandNormalization"]; ok {
+		config.CommandNormalization = d.decodeCommandNormalization(val, path+".commandNormalization")
+	}
+	if val, ok := rawConfig["patternMatchPolicy"]; ok {
+		config.PatternMatchPolicy = d.decodeString(val, path+".patternMatchPolicy")
+		if config.PatternMatchPolicy != "" && !findNamePattern(config.PatternMatchPolicy, PatternMatchPolicyList) {
+			d.addError(path+".patternMatchPolicy", "unknown policy %q, it should be one of %s", config.PatternMatchPolicy, strings.Join(PatternMatchPolicyList, ", "))
+		}
+	}
+	if val, ok := rawConfig["ignoreCommandList"]; ok {
+		config.IgnoreCommandList = d.decodeStringList(val, path+".ignoreCommandList")
+		for i, command := range config.IgnoreCommandList {
+			if command == "" || strings.ContainsAny(command, "/ ") {
+				d.addError(fmt.Sprintf("%s.ignoreCommandList[%d]", path, i), "should be a command name without path, not %q", command)
+			}
+		}
+	}
+	return config
+}
+
+// decodeCommandNormalization replaces each list of default rules which is given
+func (d *configDecoder) decodeCommandNormalization(val interface{}, path string) CommandNormalization {
+	commandNormalization := DefaultCommandNormalization()
+	rawCommandNormalization, ok := d.decodeObject(val, path)
+	if !ok {
+		return commandNormalization
+	}
+	d.checkUnknownFields(rawCommandNormalization, path, "dropArgumentList", "collapsePathList", "rewriteList")
+
+	if val, ok := rawCommandNormalization["dropArgumentList"]; ok {
+		commandNormalization.DropArgumentList = d.decodeStringList(val, path+".dropArgumentList")
+		for i, pattern := range commandNormalization.DropArgumentList {
+			d.checkRegexp(pattern, fmt.Sprintf("%s.dropArgumentList[%d]", path, i))
+		}
+	}
+	if val, ok := rawCommandNormalization["collapsePathList"]; ok {
+		commandNormalization.CollapsePathList = d.decodeStringList(val, path+".collapsePathList")
+		for i, collapsePath := range commandNormalization.CollapsePathList {
+			if collapsePath != "~" && !strings.HasPrefix(collapsePath, "~/") && !filepath.IsAbs(collapsePath) {
+				d.addError(fmt.Sprintf("%s.collapsePathList[%d]", path, i), "%q should be an absolute path or start with ~/", collapsePath)
+			}
+		}
+	}
+	if val, ok := rawCommandNormalization["rewriteList"]; ok {
+		commandNormalization.RewriteList = d.decodeCommandRewriteList(val, path+".rewriteList")
+	}
+	return commandNormalization
+}
+
+func (d *configDecoder) decodeCommandRewriteList(val interface{}, path string) []CommandRewrite {
+	commandRewriteList := []CommandRewrite{}
+	rawList, ok := val.([]interface{})
+	if !ok {
+		d.addError(path, "should be a list of objects, not %s", describeType(val))
+		return commandRewriteList
+	}
+	for i, rawCommandRewrite := range rawList {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		rawObject, ok := d.decodeObject(rawCommandRewrite, itemPath)
+		if !ok {
+			continue
+		}
+		d.checkUnknownFields(rawObject, itemPath, "pattern", "replacement")
+		commandRewrite := CommandRewrite{}
+		if val, ok := rawObject["pattern"]; ok {
+			commandRewrite.Pattern = d.decodeString(val, itemPath+".pattern")
+			d.checkRegexp(commandRewrite.Pattern, itemPath+".pattern")
+		} else {
+			d.addError(itemPath+".pattern", "required")
+		}
+		// replacement can be empty to remove matches
+		if val, ok := rawObject["replacement"]; ok {
+			replacement, ok := val.(string)
+			if !ok {
+				d.addError(itemPath+".replacement", "should be a string, not %s", describeType(val))
+			}
+			commandRewrite.Replacement = replacement
+		}
+		commandRewriteList = append(commandRewriteList, commandRewrite)
+	}
+	return commandRewriteList
+}
+
+func (d *configDecoder) checkRegexp(pattern string, path string) {
+	if pattern == "" {
+		return
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		d.addError(path, "%q is not a regular expression: %v", pattern, err)
+	}
+}
+
+func (d *configDecoder) decodeEnvironmentMarker(val interface{}, path string) string {
+	str, ok := val.(string)
+	if !ok {
+		d.addError(path, "should be a string, not %s", describeType(val))
+		return ""
+	}
+	if str != "" && (!strings.HasPrefix(str, EnvironmentMarkerPrefix) || strings.ContainsAny(str, "= ")) {
+		d.addError(path, "%q should be a name of environment variable starting with %s, e.g. ALARM_ME", str, EnvironmentMarkerPrefix)
+		return ""
+	}
+	return str
+}
+
+func (d *configDecoder) decodeDestinations(val interface{}, path string) map[string]AlarmConfig {
+	destinations := map[string]AlarmConfig{}
+	rawDestinations, ok := d.decodeObject(val, path)
+	if !ok {
+		return destinations
+	}
+	for name, rawAlarmConfig := range rawDestinations {
+		destinations[name] = d.decodeAlarmConfig(rawAlarmConfig, path+"."+name)
+	}
+	return destinations
+}
+
+func (d *configDecoder) decodeAlarmConfig(val interface{}, path string) AlarmConfig {
+	alarmConfig := DefaultConfig().AlarmConfig
+	rawAlarmConfig, ok := d.decodeObject(val, path)
+	if !ok {
+		return alarmConfig
+	}
+	d.checkUnknownFields(rawAlarmConfig, path, "type", "webHookUrl", "requestTimeout", "channel", "deliveryWindow")
+
+	if val, ok := rawAlarmConfig["type"]; ok {
+		alarmConfig.Type = d.decodeString(val, path+".type")
+		if alarmConfig.Type != "" && alarmConfig.Type != SlackWebHookAlarmType {
+			d.addError(path+".type", "unknown type %q, it should be %q", alarmConfig.Type, SlackWebHookAlarmType)
+		}
+	} else {
+		d.addError(path+".type", "required")
+	}
+	if val, ok := rawAlarmConfig["webHookUrl"]; ok {
+		alarmConfig.WebHookUrl = d.decodeSecret(val, path+".webHookUrl")
+	} else {
+		d.addError(path+".webHookUrl", "required")
+	}
+	if val, ok := rawAlarmConfig["requestTimeout"]; ok {
+		alarmConfig.RequestTimeout = d.decodeDuration(val, path+".requestTimeout")
+	}
+	if val, ok := rawAlarmConfig["channel"]; ok {
+		alarmConfig.Channel = d.decodeString(val, path+".channel")
+	}
+	if val, ok := rawAlarmConfig["deliveryWindow"]; ok {
+		alarmConfig.DeliveryWindow = d.decodeDeliveryWindow(val, path+".deliveryWindow")
+	}
+	return alarmConfig
+}
+
+func (d *configDecoder) decodeDeliveryWindow(val interface{}, path string) DeliveryWindow {
+	deliveryWindow := DeliveryWindow{
+		WindowList:        []TimeWindow{},
+		OutsidePolicy:     DigestOutsidePolicy,
+		UrgentPatternList: []string{},
+	}
+	rawDeliveryWindow, ok := d.decodeObject(val, path)
+	if !ok {
+		return deliveryWindow
+	}
+	d.checkUnknownFields(rawDeliveryWindow, path, "timeZone", "windowList", "outsidePolicy", "urgentPatternList")
+
+	if val, ok := rawDeliveryWindow["timeZone"]; ok {
+		deliveryWindow.TimeZone = d.decodeString(val, path+".timeZone")
+		if _, err := time.LoadLocation(deliveryWindow.TimeZone); err != nil {
+			d.addError(path+".timeZone", "unknown time zone %q", deliveryWindow.TimeZone)
+		}
+	}
+	if val, ok := rawDeliveryWindow["windowList"]; ok {
+		deliveryWindow.WindowList = d.decodeTimeWindowList(val, path+".windowList")
+	}
+	if val, ok := rawDeliveryWindow["outsidePolicy"]; ok {
+		deliveryWindow.OutsidePolicy = d.decodeString(val, path+".outsidePolicy")
+		if !findNamePattern(deliveryWindow.OutsidePolicy, OutsidePolicyList) {
+			d.addError(path+".outsidePolicy", "unknown policy %q, it should be one of %s", deliveryWindow.OutsidePolicy, strings.Join(OutsidePolicyList, ", "))
+		}
+	}
+	if val, ok := rawDeliveryWindow["urgentPatternList"]; ok {
+		deliveryWindow.UrgentPatternList = d.decodeStringList(val, path+".urgentPatternList")
+	}
+	return deliveryWindow
+}
+
+func (d *configDecoder) decodeTimeWindowList(val interface{}, path string) []TimeWindow {
+	timeWindowList := []TimeWindow{}
+	rawList, ok := val.([]interface{})
+	if !ok {
+		d.addError(path, "should be a list of objects, not %s", describeType(val))
+		return timeWindowList
+	}
+	for i, rawTimeWindow := range rawList {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		rawObject, ok := d.decodeObject(rawTimeWindow, itemPath)
+		if !ok {
+			continue
+		}
+		d.checkUnknownFields(rawObject, itemPath, "weekdays", "start", "end")
+		timeWindow := TimeWindow{
+			Weekdays: []string{},
+		}
+		if val, ok := rawObject["weekdays"]; ok {
+			timeWindow.Weekdays = d.decodeStringList(val, itemPath+".weekdays")
+			for j, weekday := range timeWindow.Weekdays {
+				if _, ok := weekdayByName[strings.ToLower(weekday)]; !ok {
+					d.addError(fmt.Sprintf("%s.weekdays[%d]", itemPath, j), "unknown weekday %q, it should be one of sun, mon, tue, wed, thu, fri, sat", weekday)
+				}
+			}
+		}
+		timeWindow.Start = d.decodeTimeOfDay(rawObject, itemPath, "start")
+		timeWindow.End = d.decodeTimeOfDay(rawObject, itemPath, "end")
+		timeWindowList = append(timeWindowList, timeWindow)
+	}
+	return timeWindowList
+}
+
+func (d *configDecoder) decodeTimeOfDay(object map[string]interface{}, path string, field string) string {
+	val, ok := object[field]
+	if !ok {
+		d.addError(path+"."+field, "required")
+		return ""
+	}
+	timeOfDay := d.decodeString(val, path+"."+field)
+	if _, err := parseTimeOfDay(timeOfDay); err != nil {
+		d.addError(path+"."+field, "should be a time of day like 09:00, not %q", timeOfDay)
+	}
+	return timeOfDay
+}
+
+func (d *configDecoder) checkUnknownFields(object map[string]interface{}, path string, knownFieldList ...string) {
+	unknownFieldList := []string{}
+	for field := range object {
+		known := false
+		for _, knownField := range knownFieldList {
+			if field == knownField {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknownFieldList = append(unknownFieldList, field)
+		}
+	}
+	// map iteration order is random, errors should be reported in same order
+	sort.Strings(unknownFieldList)
+	for _, field := range unknownFieldList {
+		d.addError(path+"."+field, "unknown field")
+	}
+}
+
+func (d *configDecoder) decodeObject(val interface{}, path string) (map[string]interface{}, bool) {
+	object, ok := val.(map[string]interface{})
+	if !ok {
+		d.addError(path, "should be an object, not %s", describeType(val))
+	}
+	return object, ok
+}
+
+func (d *configDecoder) decodeString(val interface{}, path string) string {
+	str, ok := val.(string)
+	if !ok {
+		d.addError(path, "should be a string, not %s", describeType(val))
+		return ""
+	}
+	if str == "" {
+		d.addError(path, "should not be empty")
+	}
+	return str
+}
+
+func (d *configDecoder) decodeStringList(val interface{}, path string) []string {
+	stringList := []string{}
+	rawList, ok := val.([]interface{})
+	if !ok {
+		d.addError(path, "should be a list of strings, not %s", describeType(val))
+		return stringList
+	}
+	for i, rawString := range rawList {
+		str := d.decodeString(rawString, fmt.Sprintf("%s[%d]", path, i))
+		if str != "" {
+			stringList = append(stringList, str)
+		}
+	}
+	return stringList
+}
+
+// decodeMonitoringCommandList decodes namePatterns, which can not start with ReservedMonitoringCommandPrefix
+func (d *configDecoder) decodeMonitoringCommandList(val interface{}, path string) []string {
+	monitoringCommandList := d.decodeStringList(val, path)
+	rawList, _ := val.([]interface{})
+	for i, rawNamePattern := range rawList {
+		namePattern, ok := rawNamePattern.(string)
+		if !ok {
+			continue
+		}
+		if err := ValidateNamePattern(namePattern); err != nil {
+			d.addError(fmt.Sprintf("%s[%d]", path, i), "%v", err)
+		}
+	}
+	return monitoringCommandList
+}
+
+func (d *configDecoder) decodeDuration(val interface{}, path string) time.Duration {
+	duration, ok := d.parseDuration(val, path)
+	if ok && duration <= 0 {
+		d.addError(path, "should be positive")
+	}
+	return duration
+}
+
+func (d *configDecoder) decodeNonNegativeDuration(val interface{}, path string) time.Duration {
+	duration, ok := d.parseDuration(val, path)
+	if ok && duration < 0 {
+		d.addError(path, "should not be negative")
+	}
+	return duration
+}
+
+// decodeNonNegativeInteger accepts a number or a string of number, which is given by environment variable or flag
+func (d *configDecoder) decodeNonNegativeInteger(val interface{}, path string) int {
+	var integer int64
+	switch v := val.(type) {
+	case string:
+		parsedInteger, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			d.addError(path, "%q is not an integer", v)
+			return 0
+		}
+		integer = parsedInteger
+	case float64:
+		if v != math.Trunc(v) {
+			d.addError(path, "should be an integer, not %v", v)
+			return 0
+		}
+		integer = int64(v)
+	case int:
+		integer = int64(v)
+	case int64:
+		integer = v
+	case uint64:
+		integer = int64(v)
+	default:
+		d.addError(path, "should be an integer, not %s", describeType(val))
+		return 0
+	}
+	if integer < 0 {
+		d.addError(path, "should not be negative")
+		return 0
+	}
+	return int(integer)
+}
+
+// parseDuration accepts a number of milliseconds, e.g. 1000 or "1000",
+// or a duration string of golang, e.g. "5s"
+func (d *configDecoder) parseDuration(val interface{}, path string) (time.Duration, bool) {
+	var duration time.Duration
+	switch v := val.(type) {
+	case string:
+		if milliseconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			duration = time.Duration(milliseconds) * time.Millisecond
+			break
+		}
+		parsedDuration, err := time.ParseDuration(v)
+		if err != nil {
+			d.addError(path, "%q is neither milliseconds nor a duration like \"5s\"", v)
+			return 0, false
+		}
+		duration = parsedDuration
+	case float64:
+		if v != math.Trunc(v) {
+			d.addError(path, "milliseconds should be an integer, not %v", v)
+			return 0, false
+		}
+		duration = time.Duration(v) * time.Millisecond
+	case int:
+		duration = time.Duration(v) * time.Millisecond
+	case int64:
+		duration = time.Duration(v) * time.Millisecond
+	case uint64:
+		duration = time.Duration(v) * time.Millisecond
+	default:
+		d.addError(path, "should be milliseconds or a duration like \"5s\", not %s", describeType(val))
+		return 0, false
+	}
+	return duration, true
+}
+
+func (d *configDecoder) decodeSecret(val interface{}, path string) Secret {
+	str := d.decodeString(val, path)
+	if str == "" {
+		return Secret{}
+	}
+	if !IsSecretReference(str) {
+		return NewSecret(str)
+	}
+	value, err := d.resolveSecret(str)
+	if err != nil {
+		d.addError(path, "%v", err)
+		return Secret{}
+	}
+	return Secret{
+		reference: str,
+		value:     value,
+	}
+}
+
+func describeType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case float64, int, int64, uint64:
+		return "a number"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", val)
+}
@@ -0,0 +1,128 @@
+package alarm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	JsonConfigFormat = "json"
+	YamlConfigFormat = "yaml"
+	TomlConfigFormat = "toml"
+)
+
+// ConfigFormatOf chooses format of config file by its extension
+func ConfigFormatOf(configPath string) (string, error) {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".json":
+		return JsonConfigFormat, nil
+	case ".yaml", ".yml":
+		return YamlConfigFormat, nil
+	case ".toml":
+		return TomlConfigFormat, nil
+	}
+	return "", fmt.Errorf("unknown format of config file %s, extension should be .json, .yaml, .yml or .toml", configPath)
+}
+
+// readConfigFile reads config file of any format into raw config.
+// raw config of every format has same types as raw config of json
+// except numbers, which DecodeConfig handles
+func readConfigFile(configPath string) (map[string]interface{}, error) {
+	format, err := ConfigFormatOf(configPath)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	return unmarshalConfig(data, format)
+}
+
+func unmarshalConfig(data []byte, format string) (map[string]interface{}, error) {
+	rawConfig := map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return rawConfig, nil
+	}
+
+	var err error
+	switch format {
+	case JsonConfigFormat:
+		err = json.Unmarshal(data, &rawConfig)
+	case YamlConfigFormat:
+		err = yaml.Unmarshal(data, &rawConfig)
+	case TomlConfigFormat:
+		err = toml.Unmarshal(data, &rawConfig)
+	default:
+		err = fmt.Errorf("unknown format of config %q", format)
+	}
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	return rawConfig, nil
+}
+
+func marshalConfig(rawConfig map[string]interface{}, format string) ([]byte, error) {
+	switch format {
+	case JsonConfigFormat:
+		data, err := json.MarshalIndent(rawConfig, "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case YamlConfigFormat:
+		return yaml.Marshal(rawConfig)
+	case TomlConfigFormat:
+		buff := bytes.Buffer{}
+		if err := toml.NewEncoder(&buff).Encode(rawConfig); err != nil {
+			return nil, err
+		}
+		return buff.Bytes(), nil
+	}
+	return nil, fmt.Errorf("unknown format of config %q", format)
+}
+
+// ConvertConfigFile translates config file into the format of dstConfigPath.
+// secret references are kept as they are, comments are not kept.
+// the converted file is only readable by the owner like WriteConfigFile, because plaintext web hook is kept too
+func ConvertConfigFile(srcConfigPath, dstConfigPath string) error {
+	dstFormat, err := ConfigFormatOf(dstConfigPath)
+	if err != nil {
+		return err
+	}
+	rawConfig, err := readConfigFile(srcConfigPath)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(dstConfigPath, rawConfig, dstFormat)
+}
+
+// WriteConfigFile writes raw config in the format of configPath.
+// it is only readable by the owner because config can contain a plaintext web hook
+func WriteConfigFile(configPath string, rawConfig map[string]interface{}) error {
+	format, err := ConfigFormatOf(configPath)
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(configPath, rawConfig, format)
+}
+
+// writeConfigFile writes config only readable by the owner, mode of existing file is changed too
+func writeConfigFile(configPath string, rawConfig map[string]interface{}, format string) error {
+	data, err := marshalConfig(rawConfig, format)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(configPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(configPath, 0600)
+}
@@ -0,0 +1,138 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testJsonConfig = `{
+		"monitoringCommandList": ["go test", "make e2e"],
+		"monitoringPeriod": 500,
+		"alarmConfig": {
+			"type": "slack-webhook",
+			"webHookUrl": "localhost",
+			"requestTimeout": "5s"
+		}
+	}`
+	testYamlConfig = `
+# go test is run by everyone
+monitoringCommandList:
+  - go test
+  - make e2e  # takes 20 minutes
+monitoringPeriod: 500
+alarmConfig:
+  type: slack-webhook
+  webHookUrl: localhost
+  requestTimeout: 5s
+`
+	testTomlConfig = `
+# go test is run by everyone
+monitoringCommandList = ["go test", "make e2e"]
+monitoringPeriod = 500
+
+[alarmConfig]
+type = "slack-webhook"
+webHookUrl = "localhost"
+requestTimeout = "5s"
+`
+)
+
+func TestConfigFormat(t *testing.T) {
+	t.Run("ConfigFormatOf", CheckConfigFormatOf())
+	t.Run("ReadConfigFileOfEveryFormat", CheckReadConfigFileOfEveryFormat())
+	t.Run("ConvertConfigFile", CheckConvertConfigFile())
+}
+
+func CheckConfigFormatOf() func(*testing.T) {
+	return func(t *testing.T) {
+		for configPath, format := range map[string]string{
+			"config.json":            JsonConfigFormat,
+			"config.yaml":            YamlConfigFormat,
+			"/home/user/.config.yml": YamlConfigFormat,
+			"config.TOML":            TomlConfigFormat,
+		} {
+			configFormat, err := ConfigFormatOf(configPath)
+			require.NoError(t, err)
+			require.Equal(t, format, configFormat)
+		}
+
+		_, err := ConfigFormatOf("config.ini")
+		require.Error(t, err)
+	}
+}
+
+func CheckReadConfigFileOfEveryFormat() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		expectedConfig := Config{
+			MonitoringCommandList: []string{"go test", "make e2e"},
+			MonitoringPeriod:      500 * time.Millisecond,
+			AlarmConfig: AlarmConfig{
+				Type:           SlackWebHookAlarmType,
+				WebHookUrl:     NewSecret("localhost"),
+				RequestTimeout: 5 * time.Second,
+			},
+			Destinations:          map[string]AlarmConfig{},
+			EnvironmentMarker:     defaultEnvironmentMarker,
+			ShellCommandThreshold: defaultShellCommandThreshold,
+			HistoryRetention:      defaultHistoryRetention,
+			HistoryMaxRunCount:    defaultHistoryMaxRunCount,
+			RegressionPercent:     defaultRegressionPercent,
+			RegressionSampleCount: defaultRegressionSampleCount,
+			CommandNormalization:  DefaultCommandNormalization(),
+			PatternMatchPolicy:    MostSpecificPatternMatchPolicy,
+			IgnoreCommandList:     []string{},
+		}
+		for configName, configContent := range map[string]string{
+			"config.json": testJsonConfig,
+			"config.yaml": testYamlConfig,
+			"config.yml":  testYamlConfig,
+			"config.toml": testTomlConfig,
+		} {
+			configPath := filepath.Join(dir, configName)
+			require.NoError(t, ioutil.WriteFile(configPath, []byte(configContent), 0644))
+
+			config, err := ReadConfigFile(configPath)
+			require.NoError(t, err, configName)
+			require.Equal(t, expectedConfig, config, configName)
+		}
+	}
+}
+
+func CheckConvertConfigFile() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		jsonConfigPath := filepath.Join(dir, "config.json")
+		require.NoError(t, ioutil.WriteFile(jsonConfigPath, []byte(testJsonConfig), 0644))
+		expectedConfig, err := ReadConfigFile(jsonConfigPath)
+		require.NoError(t, err)
+
+		// converted.json exists already, and it becomes only readable by the owner too
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "converted.json"), []byte("{}"), 0644))
+		srcConfigPath := jsonConfigPath
+		for _, configName := range []string{"config.yaml", "config.toml", "converted.json"} {
+			dstConfigPath := filepath.Join(dir, configName)
+			require.NoError(t, ConvertConfigFile(srcConfigPath, dstConfigPath), configName)
+			fileInfo, err := os.Stat(dstConfigPath)
+			require.NoError(t, err, configName)
+			require.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm(), configName)
+
+			config, err := ReadConfigFile(dstConfigPath)
+			require.NoError(t, err, configName)
+			require.Equal(t, expectedConfig, config, configName)
+			srcConfigPath = dstConfigPath
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package alarm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	DropInDirectoryName      = "conf.d"
+	ConfigEnvironmentPrefix  = "ALARM_"
+	configListValueSeparator = ","
+	environmentSourcePrefix  = "env "
+	flagSourcePrefix         = "flag --set "
+)
+
+// configField is a field of config which can be set by environment variable or flag.
+// "*" in path matches any key, such field can not be set by environment variable or flag
+type configField struct {
+	path     string
+	isList   bool
+	isSecret bool
+}
+
+var (
+	configFieldList = []configField{
+		{path: "monitoringCommandList", isList: true},
+		{path: "monitoringPeriod"},
+		{path: "minimumDuration"},
+		{path: "alarmConfig.type"},
+		{path: "alarmConfig.webHookUrl", isSecret: true},
+		{path: "alarmConfig.requestTimeout"},
+		{path: "alarmConfig.channel"},
+		{path: "alarmConfig.deliveryWindow.timeZone"},
+		{path: "alarmConfig.deliveryWindow.outsidePolicy"},
+		{path: "alarmConfig.deliveryWindow.urgentPatternList", isList: true},
+		{path: "destinations.*.webHookUrl", isSecret: true},
+		{path: "environmentMarker"},
+		{path: "shellCommandThreshold"},
+		{path: "historyRetention"},
+		{path: "historyMaxRunCount"},
+		{path: "almostDonePercent"},
+		{path: "regressionPercent"},
+		{path: "regressionSampleCount"},
+		{path: "commandNormalization.dropArgumentList", isList: true},
+		{path: "commandNormalization.collapsePathList", isList: true},
+		{path: "patternMatchPolicy"},
+		{path: "ignoreCommandList", isList: true},
+	}
+)
+
+// ConfigLayers assembles effective config from several layers, which are applied in this order
+//  1. base config file
+//  2. drop-in config files in conf.d next to base config file, in alphabetical order
+//  3. ALARM_* environment variables, e.g. ALARM_MONITORING_PERIOD=5s
+//  4. flags, e.g. --set monitoringPeriod=5s
+//
+// objects are merged, lists are appended and other values are replaced by later layer
+type ConfigLayers struct {
+	BaseConfigPath string
+	// Environ is in the form of os.Environ()
+	Environ []string
+	// FlagList is values of --set flags in the form of "path=value"
+	FlagList []string
+}
+
+// EffectiveConfigValue is a value of effective config with the layer where it came from
+type EffectiveConfigValue struct {
+	Path   string
+	Value  interface{}
+	Source string
+}
+
+func (cl ConfigLayers) DropInDirectory() string {
+	return filepath.Join(filepath.Dir(cl.BaseConfigPath), DropInDirectoryName)
+}
+
+// DropInConfigPathList returns drop-in config files in the order they are applied.
+// hidden files and files of unknown format, e.g. swap files of editors, are skipped
+func (cl ConfigLayers) DropInConfigPathList() []string {
+	dropInConfigPathList := []string{}
+	fileList, err := ioutil.ReadDir(cl.DropInDirectory())
+	if err != nil {
+		return dropInConfigPathList
+	}
+	for _, f := range fileList {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		if _, err := ConfigFormatOf(f.Name()); err != nil {
+			continue
+		}
+		dropInConfigPathList = append(dropInConfigPathList, filepath.Join(cl.DropInDirectory(), f.Name()))
+	}
+	// ReadDir returns files sorted by name already, it is sorted again to make the order explicit
+	sort.Strings(dropInConfigPathList)
+	return dropInConfigPathList
+}
+
+// ConfigPathList returns every config file of layers
+func (cl ConfigLayers) ConfigPathList() []string {
+	return append([]string{cl.BaseConfigPath}, cl.DropInConfigPathList()...)
+}
+
+// Read merges every layer into raw config.
+// source of each value is returned together, it is keyed by path like "alarmConfig.type" or "monitoringCommandList[0]"
+func (cl ConfigLayers) Read() (map[string]interface{}, map[string]string, error) {
+	rawConfig := map[string]interface{}{}
+	sourceByPath := map[string]string{}
+
+	for _, configPath := range cl.ConfigPathList() {
+		rawLayer, err := readConfigFile(configPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", configPath, err)
+		}
+		mergeRawConfig(rawConfig, rawLayer, "", configPath, sourceByPath)
+	}
+
+	for _, env := range cl.Environ {
+		if !strings.HasPrefix(env, ConfigEnvironmentPrefix) {
+			continue
+		}
+		name := strings.SplitN(env, "=", 2)[0]
+		val := strings.TrimPrefix(env, name+"=")
+		field, ok := findConfigFieldByEnvironmentVariable(name)
+		if !ok {
+			// ALARM_* variables which are not config, e.g. ALARM_LABEL of monitored processes
+			continue
+		}
+		mergeRawConfig(rawConfig, field.rawLayer(val), "", environmentSourcePrefix+name, sourceByPath)
+	}
+
+	// source of flag is its path without value, like name of environment variable, because value can be a secret
+	for _, flag := range cl.FlagList {
+		rawLayer, path, err := rawLayerOfFlag(flag)
+		if err != nil {
+			return nil, nil, err
+		}
+		mergeRawConfig(rawConfig, rawLayer, "", flagSourcePrefix+path, sourceByPath)
+	}
+	return rawConfig, sourceByPath, nil
+}
+
+// ReadEffectiveConfig returns every value of effective config with its source.
+// secrets written in plaintext are redacted, secret references are shown as they are
+func (cl ConfigLayers) ReadEffectiveConfig() ([]EffectiveConfigValue, error) {
+	rawConfig, sourceByPath, err := cl.Read()
+	if err != nil {
+		return nil, err
+	}
+	effectiveConfig := []EffectiveConfigValue{}
+	walkRawConfig(rawConfig, "", func(path string, val interface{}) {
+		if str, ok := val.(string); ok && isSecretConfigPath(path) && !IsSecretReference(str) {
+			val = RedactedSecret
+		}
+		effectiveConfig = append(effectiveConfig, EffectiveConfigValue{
+			Path:   path,
+			Value:  val,
+			Source: sourceByPath[path],
+		})
+	})
+	return effectiveConfig, nil
+}
+
+// ReadConfig reads and validates effective config without monitoring it
+func (cl ConfigLayers) ReadConfig() (Config, error) {
+	rawConfig, sourceByPath, err := cl.Read()
+	if err != nil {
+		return Config{}, err
+	}
+	config, err := DecodeConfig(rawConfig, nil)
+	return config, annotateConfigErrorList(err, sourceByPath)
+}
+
+// EnvironmentVariableOf returns the name of environment variable which overrides config value at path,
+// e.g. ALARM_ALARM_CONFIG_REQUEST_TIMEOUT for "alarmConfig.requestTimeout"
+func EnvironmentVariableOf(path string) string {
+	name := ConfigEnvironmentPrefix
+	for i, key := range strings.Split(path, ".") {
+		if i != 0 {
+			name += "_"
+		}
+		for j, r := range key {
+			if j != 0 && unicode.IsUpper(r) {
+				name += "_"
+			}
+			name += string(unicode.ToUpper(r))
+		}
+	}
+	return name
+}
+
+func findConfigFieldByEnvironmentVariable(name string) (configField, bool) {
+	for _, field := range configFieldList {
+		if strings.Contains(field.path, "*") {
+			continue
+		}
+		if EnvironmentVariableOf(field.path) == name {
+			return field, true
+		}
+	}
+	return configField{}, false
+}
+
+func findConfigFieldByPath(path string) (configField, bool) {
+	for _, field := range configFieldList {
+		if !strings.Contains(field.path, "*") && field.path == path {
+			return field, true
+		}
+	}
+	return configField{}, false
+}
+
+func isSecretConfigPath(path string) bool {
+	for _, field := range configFieldList {
+		if field.isSecret && matchConfigPath(field.path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchConfigPath(pattern, path string) bool {
+	patternKeyList := strings.Split(pattern, ".")
+	keyList := strings.Split(path, ".")
+	if len(patternKeyList) != len(keyList) {
+		return false
+	}
+	for i := range keyList {
+		if patternKeyList[i] != "*" && patternKeyList[i] != keyList[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// rawLayer builds raw config which only has this field.
+// value of list field is separated by comma, e.g. "go test,make e2e"
+func (field configField) rawLayer(val string) map[string]interface{} {
+	var rawVal interface{} = val
+	if field.isList {
+		rawList := []interface{}{}
+		for _, item := range strings.Split(val, configListValueSeparator) {
+			if item = strings.TrimSpace(item); item != "" {
+				rawList = append(rawList, item)
+			}
+		}
+		rawVal = rawList
+	}
+
+	keyList := strings.Split(field.path, ".")
+	rawLayer := map[string]interface{}{
+		keyList[len(keyList)-1]: rawVal,
+	}
+	for i := len(keyList) - 2; i >= 0; i-- {
+		rawLayer = map[string]interface{}{
+			keyList[i]: rawLayer,
+		}
+	}
+	return rawLayer
+}
+
+// rawLayerOfFlag builds raw config of flag in the form of path=value, and returns its path
+func rawLayerOfFlag(flag string) (map[string]interface{}, string, error) {
+	keyAndValue := strings.SplitN(flag, "=", 2)
+	if len(keyAndValue) != 2 {
+		return nil, "", fmt.Errorf("flag --set %s should be in the form of path=value", flag)
+	}
+	field, ok := findConfigFieldByPath(keyAndValue[0])
+	if !ok {
+		return nil, "", fmt.Errorf("unknown config path %q in flag --set", keyAndValue[0])
+	}
+	return field.rawLayer(keyAndValue[1]), field.path, nil
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func mergeRawConfig(dst, src map[string]interface{}, path string, source string, sourceByPath map[string]string) {
+	for key, srcVal := range src {
+		keyPath := joinConfigPath(path, key)
+		// src is copied because it can be cached by caller and lists of dst are appended
+		srcVal = copyRawValue(srcVal)
+		dstVal, ok := dst[key]
+		if !ok {
+			dst[key] = srcVal
+			walkRawConfig(srcVal, keyPath, func(path string, _ interface{}) {
+				sourceByPath[path] = source
+			})
+			continue
+		}
+
+		dstObject, isDstObject := dstVal.(map[string]interface{})
+		srcObject, isSrcObject := srcVal.(map[string]interface{})
+		if isDstObject && isSrcObject {
+			mergeRawConfig(dstObject, srcObject, keyPath, source, sourceByPath)
+			continue
+		}
+
+		dstList, isDstList := dstVal.([]interface{})
+		srcList, isSrcList := srcVal.([]interface{})
+		if isDstList && isSrcList {
+			for _, item := range srcList {
+				if containsRawValue(dstList, item) {
+					continue
+				}
+				walkRawConfig(item, fmt.Sprintf("%s[%d]", keyPath, len(dstList)), func(path string, _ interface{}) {
+					sourceByPath[path] = source
+				})
+				dstList = append(dstList, item)
+			}
+			dst[key] = dstList
+			continue
+		}
+
+		for path := range sourceByPath {
+			if path == keyPath || strings.HasPrefix(path, keyPath+".") || strings.HasPrefix(path, keyPath+"[") {
+				delete(sourceByPath, path)
+			}
+		}
+		dst[key] = srcVal
+		walkRawConfig(srcVal, keyPath, func(path string, _ interface{}) {
+			sourceByPath[path] = source
+		})
+	}
+}
+
+func copyRawValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for key, item := range v {
+			object[key] = copyRawValue(item)
+		}
+		return object
+	case []interface{}:
+		list := []interface{}{}
+		for _, item := range v {
+			list = append(list, copyRawValue(item))
+		}
+		return list
+	}
+	return val
+}
+
+func containsRawValue(rawList []interface{}, val interface{}) bool {
+	for _, item := range rawList {
+		if reflect.DeepEqual(item, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkRawConfig calls visit for every value which is neither object nor list
+// in the order of keys and indexes
+func walkRawConfig(val interface{}, path string, visit func(path string, val interface{})) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		keyList := []string{}
+		for key := range v {
+			keyList = append(keyList, key)
+		}
+		sort.Strings(keyList)
+		for _, key := range keyList {
+			walkRawConfig(v[key], joinConfigPath(path, key), visit)
+		}
+	case []interface{}:
+		for i, item := range v {
+			walkRawConfig(item, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	default:
+		visit(path, val)
+	}
+}
+
+// annotateConfigErrorList adds the source of wrong value to each ConfigError
+func annotateConfigErrorList(err error, sourceByPath map[string]string) error {
+	errorList, ok := err.(ConfigErrorList)
+	if !ok {
+		return err
+	}
+	annotatedErrorList := ConfigErrorList{}
+	for _, configError := range errorList {
+		path := strings.TrimPrefix(strings.TrimPrefix(configError.Path, "$"), ".")
+		for path != "" {
+			if source, ok := sourceByPath[path]; ok {
+				configError.Message = fmt.Sprintf("%s (from %s)", configError.Message, source)
+				break
+			}
+			path = parentConfigPath(path)
+		}
+		annotatedErrorList = append(annotatedErrorList, configError)
+	}
+	return annotatedErrorList
+}
+
+func parentConfigPath(path string) string {
+	i := strings.LastIndexAny(path, ".[")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
@@ -0,0 +1,208 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLayers(t *testing.T) {
+	t.Run("EnvironmentVariableOf", CheckEnvironmentVariableOf())
+	t.Run("LayerOrder", CheckLayerOrder())
+	t.Run("EffectiveConfig", CheckEffectiveConfig())
+	t.Run("WrongFlag", CheckWrongFlag())
+	t.Run("FlagSource", CheckFlagSource())
+	t.Run("DropInChange", CheckDropInChange())
+}
+
+func CheckEnvironmentVariableOf() func(*testing.T) {
+	return func(t *testing.T) {
+		require.Equal(t, "ALARM_MONITORING_PERIOD", EnvironmentVariableOf("monitoringPeriod"))
+		require.Equal(t, "ALARM_ALARM_CONFIG_WEB_HOOK_URL", EnvironmentVariableOf("alarmConfig.webHookUrl"))
+	}
+}
+
+func CheckLayerOrder() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareConfigLayers(t)
+		defer os.RemoveAll(dir)
+
+		config, err := ConfigLayers{
+			BaseConfigPath: filepath.Join(dir, "config.json"),
+			Environ: []string{
+				"ALARM_MONITORING_PERIOD=3s",
+				"ALARM_LABEL=not a config",
+				"ALARM_MONITORING_COMMAND_LIST=cargo test, go test",
+			},
+			FlagList: []string{
+				"monitoringPeriod=4s",
+			},
+		}.ReadConfig()
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			Config{
+				MonitoringCommandList: []string{"go test", "make e2e", "npm test", "cargo test"},
+				MonitoringPeriod:      4 * time.Second,
+				AlarmConfig: AlarmConfig{
+					Type:           SlackWebHookAlarmType,
+					WebHookUrl:     NewSecret("localhost"),
+					RequestTimeout: 5 * time.Second,
+				},
+				Destinations:          map[string]AlarmConfig{},
+				EnvironmentMarker:     defaultEnvironmentMarker,
+				ShellCommandThreshold: defaultShellCommandThreshold,
+				HistoryRetention:      defaultHistoryRetention,
+				HistoryMaxRunCount:    defaultHistoryMaxRunCount,
+				RegressionPercent:     defaultRegressionPercent,
+				RegressionSampleCount: defaultRegressionSampleCount,
+				CommandNormalization:  DefaultCommandNormalization(),
+				PatternMatchPolicy:    MostSpecificPatternMatchPolicy,
+				IgnoreCommandList:     []string{},
+			},
+			config,
+		)
+	}
+}
+
+func CheckEffectiveConfig() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareConfigLayers(t)
+		defer os.RemoveAll(dir)
+
+		effectiveConfig, err := ConfigLayers{
+			BaseConfigPath: filepath.Join(dir, "config.json"),
+			Environ: []string{
+				"ALARM_MONITORING_PERIOD=3s",
+			},
+		}.ReadEffectiveConfig()
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			[]EffectiveConfigValue{
+				{Path: "alarmConfig.requestTimeout", Value: "5s", Source: filepath.Join(dir, "conf.d", "10-team.json")},
+				{Path: "alarmConfig.type", Value: "slack-webhook", Source: filepath.Join(dir, "config.json")},
+				{Path: "alarmConfig.webHookUrl", Value: RedactedSecret, Source: filepath.Join(dir, "config.json")},
+				{Path: "monitoringCommandList[0]", Value: "go test", Source: filepath.Join(dir, "config.json")},
+				{Path: "monitoringCommandList[1]", Value: "make e2e", Source: filepath.Join(dir, "conf.d", "10-team.json")},
+				{Path: "monitoringCommandList[2]", Value: "npm test", Source: filepath.Join(dir, "conf.d", "20-me.yaml")},
+				{Path: "monitoringPeriod", Value: "3s", Source: "env ALARM_MONITORING_PERIOD"},
+			},
+			effectiveConfig,
+		)
+	}
+}
+
+func CheckWrongFlag() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareConfigLayers(t)
+		defer os.RemoveAll(dir)
+
+		_, err := ConfigLayers{
+			BaseConfigPath: filepath.Join(dir, "config.json"),
+			FlagList:       []string{"monitoringPeriod=forever"},
+		}.ReadConfig()
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{
+					Path:    "$.monitoringPeriod",
+					Message: `"forever" is neither milliseconds nor a duration like "5s" (from flag --set monitoringPeriod)`,
+				},
+			},
+			err,
+		)
+
+		_, err = ConfigLayers{
+			BaseConfigPath: filepath.Join(dir, "config.json"),
+			FlagList:       []string{"monitoringperiod=5s"},
+		}.ReadConfig()
+		require.Error(t, err)
+	}
+}
+
+// value of flag can be a secret, so it is never shown in its source
+func CheckFlagSource() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareConfigLayers(t)
+		defer os.RemoveAll(dir)
+
+		secret := "https://hooks.slack.com/SECRETXYZ"
+		configLayers := ConfigLayers{
+			BaseConfigPath: filepath.Join(dir, "config.json"),
+			FlagList:       []string{"alarmConfig.webHookUrl=" + secret},
+		}
+		_, sourceByPath, err := configLayers.Read()
+		require.NoError(t, err)
+		require.Equal(t, "flag --set alarmConfig.webHookUrl", sourceByPath["alarmConfig.webHookUrl"])
+		for _, source := range sourceByPath {
+			require.NotContains(t, source, secret)
+		}
+
+		effectiveConfig, err := configLayers.ReadEffectiveConfig()
+		require.NoError(t, err)
+		for _, effectiveConfigValue := range effectiveConfig {
+			require.NotContains(t, effectiveConfigValue.Source, secret)
+			require.NotEqual(t, secret, effectiveConfigValue.Value)
+		}
+	}
+}
+
+func CheckDropInChange() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareConfigLayers(t)
+		defer os.RemoveAll(dir)
+		dropInDirectory := filepath.Join(dir, DropInDirectoryName)
+		require.NoError(t, os.RemoveAll(dropInDirectory))
+
+		cm := NewConfigMonitor(filepath.Join(dir, "config.json"))
+		defer cm.Stop()
+		require.Equal(t, []string{"go test"}, cm.GetMonitoringCommandList())
+
+		// drop-in directory is created after monitoring is started
+		require.NoError(t, os.Mkdir(dropInDirectory, 0755))
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, ioutil.WriteFile(
+			filepath.Join(dropInDirectory, "10-me.toml"),
+			[]byte(`monitoringCommandList = ["make"]`),
+			0644))
+		time.Sleep(500 * time.Millisecond)
+		require.Equal(t, []string{"go test", "make"}, cm.GetMonitoringCommandList())
+
+		require.NoError(t, os.Remove(filepath.Join(dropInDirectory, "10-me.toml")))
+		time.Sleep(500 * time.Millisecond)
+		require.Equal(t, []string{"go test"}, cm.GetMonitoringCommandList())
+	}
+}
+
+func prepareConfigLayers(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "config")
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, DropInDirectoryName), 0755))
+
+	for configName, configContent := range map[string]string{
+		"config.json": `{
+			"monitoringCommandList": ["go test"],
+			"monitoringPeriod": "1s",
+			"alarmConfig": {"type": "slack-webhook", "webHookUrl": "localhost"}
+		}`,
+		"conf.d/10-team.json": `{
+			"monitoringCommandList": ["make e2e", "go test"],
+			"monitoringPeriod": "2s",
+			"alarmConfig": {"requestTimeout": "5s"}
+		}`,
+		"conf.d/20-me.yaml": `
+monitoringCommandList:
+  - npm test
+`,
+		"conf.d/.20-me.yaml.swp": `not a config`,
+		"conf.d/README.md":       `not a config`,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, configName), []byte(configContent), 0644))
+	}
+	return dir
+}
@@ -1,43 +1,67 @@
 package alarm
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"strconv"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 const (
 	defaultPeriod = 250 * time.Millisecond
+
+	// editors write a file in several steps, e.g. truncate and write,
+	// config is read after events of config file stop for this delay
+	configReloadDelay = 50 * time.Millisecond
 )
 
 type ConfigMonitor struct {
-	configPath       string
-	config           map[string]interface{}
-	monitoringPeriod time.Duration
-	isStarted        bool
+	configLayers ConfigLayers
+	config       Config
+	isStarted    bool
+	watcher      *fsnotify.Watcher
+
+	// secret which is referenced by command is resolved only once while the reference stays in config
+	// so that commands like "pass show" are not executed on every update
+	resolvedSecretByCmdReference map[string]string
+
+	changeCallbackList []func(oldConfig, newConfig Config)
 
 	mutexForSynchronousMethodCall sync.Mutex
 	mutexForConfig                sync.Mutex
+	mutexForUpdateConfig          sync.Mutex
+	mutexForChangeCallbackList    sync.Mutex
 }
 
+// NewConfigMonitor monitors config file and drop-in config files next to it
 func NewConfigMonitor(configPath string) *ConfigMonitor {
+	return NewLayeredConfigMonitor(ConfigLayers{
+		BaseConfigPath: configPath,
+	})
+}
+
+func NewLayeredConfigMonitor(configLayers ConfigLayers) *ConfigMonitor {
 	cm := &ConfigMonitor{}
-	cm.Init(configPath)
+	cm.Init(configLayers)
 	cm.Start()
-	time.Sleep(defaultPeriod)
 	return cm
 }
 
-func (cm *ConfigMonitor) Init(configPath string) {
-	cm.configPath = configPath
-	cm.config = map[string]interface{}{}
+func (cm *ConfigMonitor) Init(configLayers ConfigLayers) {
+	cm.configLayers = configLayers
+	cm.config = DefaultConfig()
+	cm.resolvedSecretByCmdReference = map[string]string{}
+	cm.changeCallbackList = []func(oldConfig, newConfig Config){}
 	cm.UpdateConfig()
-	cm.SetPeriod(defaultPeriod)
 }
 
+// Start watches config file, the directory containing it and drop-in directory with inotify.
+// watching the directory is needed because editors save a file by renaming a new file onto it,
+// after that the watch of the file itself is gone
 func (cm *ConfigMonitor) Start() {
 	cm.mutexForSynchronousMethodCall.Lock()
 	defer cm.mutexForSynchronousMethodCall.Unlock()
@@ -45,103 +69,215 @@ func (cm *ConfigMonitor) Start() {
 	if cm.isStarted {
 		return
 	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		errMsg := fmt.Sprintf("error occured during creating watcher of config file: %v", err)
+		fmt.Println(errMsg)
+		return
+	}
+	if err := watcher.Add(filepath.Dir(cm.configLayers.BaseConfigPath)); err != nil {
+		errMsg := fmt.Sprintf("error occured during watching directory of config file: %v", err)
+		fmt.Println(errMsg)
+		watcher.Close()
+		return
+	}
+	cm.addWatchOfConfigFiles(watcher)
+
 	cm.isStarted = true
+	cm.watcher = watcher
+	// config file could be changed while it was not watched
+	cm.UpdateConfig()
 
-	go func(cm *ConfigMonitor) {
-		for {
-			if !cm.isStarted {
-				break
-			}
+	go cm.watch(watcher)
+}
 
+func (cm *ConfigMonitor) watch(watcher *fsnotify.Watcher) {
+	var reload <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !cm.isEventOfConfigFiles(event) {
+				continue
+			}
+			reload = time.After(configReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			errMsg := fmt.Sprintf("error occured during watching config file: %v", err)
+			fmt.Println(errMsg)
+		case <-reload:
+			reload = nil
+			cm.addWatchOfConfigFiles(watcher)
 			cm.UpdateConfig()
-			time.Sleep(cm.monitoringPeriod)
 		}
-	}(cm)
+	}
+}
+
+// addWatchOfConfigFiles is called again whenever config files are changed
+// because watch of a file is removed when it is replaced
+// and drop-in directory can be created after monitoring is started.
+// adding same file again is not a problem for inotify
+func (cm *ConfigMonitor) addWatchOfConfigFiles(watcher *fsnotify.Watcher) {
+	// config file can be a symbolic link to a file in another directory, e.g. dotfiles repository.
+	// errors are ignored because config file and drop-in directory can be created later
+	_ = watcher.Add(cm.configLayers.BaseConfigPath)
+	_ = watcher.Add(cm.configLayers.DropInDirectory())
+}
+
+func (cm *ConfigMonitor) isEventOfConfigFiles(event fsnotify.Event) bool {
+	eventPath := filepath.Clean(event.Name)
+	dropInDirectory := filepath.Clean(cm.configLayers.DropInDirectory())
+	if eventPath == filepath.Clean(cm.configLayers.BaseConfigPath) || eventPath == dropInDirectory {
+		return true
+	}
+	if filepath.Dir(eventPath) != dropInDirectory || strings.HasPrefix(filepath.Base(eventPath), ".") {
+		return false
+	}
+	_, err := ConfigFormatOf(eventPath)
+	return err == nil
+}
+
+// OnChange registers callback which is called with old and new config
+// whenever the config is changed. callback should not block
+func (cm *ConfigMonitor) OnChange(callback func(oldConfig, newConfig Config)) {
+	cm.mutexForChangeCallbackList.Lock()
+	defer cm.mutexForChangeCallbackList.Unlock()
+	cm.changeCallbackList = append(cm.changeCallbackList, callback)
 }
 
+// UpdateConfig reads config file again.
+// if the config file is invalid, last valid config is kept
 func (cm *ConfigMonitor) UpdateConfig() {
-	config, err := readJsonFile(cm.configPath)
+	cm.mutexForUpdateConfig.Lock()
+	defer cm.mutexForUpdateConfig.Unlock()
+
+	rawConfig, sourceByPath, err := cm.configLayers.Read()
 	if err != nil {
 		errMsg := fmt.Sprintf("error occured during reading config file: %v", err)
 		fmt.Println(errMsg)
 		return
 	}
+	resolvedSecretByCmdReference := map[string]string{}
+	config, err := DecodeConfig(rawConfig, func(reference string) (string, error) {
+		return cm.resolveSecretReference(reference, resolvedSecretByCmdReference)
+	})
+	cm.resolvedSecretByCmdReference = resolvedSecretByCmdReference
+	if err != nil {
+		err = annotateConfigErrorList(err, sourceByPath)
+		errMsg := fmt.Sprintf("config of %s is invalid, last valid config is kept:\n%v", cm.configLayers.BaseConfigPath, err)
+		fmt.Println(errMsg)
+		return
+	}
+	cm.setConfig(config)
+}
+
+func (cm *ConfigMonitor) setConfig(config Config) {
 	cm.mutexForConfig.Lock()
-	defer cm.mutexForConfig.Unlock()
-	cm.config = map[string]interface{}{}
-	for key, val := range config {
-		cm.config[key] = val
+	oldConfig := cm.config
+	cm.config = config
+	cm.mutexForConfig.Unlock()
+
+	if reflect.DeepEqual(oldConfig, config) {
+		return
+	}
+	cm.mutexForChangeCallbackList.Lock()
+	defer cm.mutexForChangeCallbackList.Unlock()
+	for _, callback := range cm.changeCallbackList {
+		callback(copyConfig(oldConfig), copyConfig(config))
 	}
 }
 
-func readJsonFile(configPath string) (map[string]interface{}, error) {
-	config := map[string]interface{}{}
-	data, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		return config, err
+func (cm *ConfigMonitor) resolveSecretReference(reference string, resolvedSecretByCmdReference map[string]string) (string, error) {
+	if !strings.HasPrefix(reference, CmdSecretReferencePrefix) {
+		return ResolveSecretReference(reference)
 	}
-	if len(data) == 0 {
-		return config, nil
+	secret, ok := cm.resolvedSecretByCmdReference[reference]
+	if !ok {
+		var err error
+		secret, err = ResolveSecretReference(reference)
+		if err != nil {
+			return "", err
+		}
 	}
-	err = json.Unmarshal(data, &config)
+	resolvedSecretByCmdReference[reference] = secret
+	return secret, nil
+}
+
+// ReadConfigFile reads and validates config file without monitoring it.
+// drop-in config files are not applied
+func ReadConfigFile(configPath string) (Config, error) {
+	rawConfig, err := readConfigFile(configPath)
 	if err != nil {
-		return config, err
+		return Config{}, err
 	}
-	return config, nil
+	return DecodeConfig(rawConfig, nil)
 }
 
-func (cm *ConfigMonitor) Stop() {
-	cm.isStarted = false
+func (cm *ConfigMonitor) GetConfigLayers() ConfigLayers {
+	return cm.configLayers
 }
 
-func (cm *ConfigMonitor) SetPeriod(period time.Duration) {
-	cm.monitoringPeriod = period
-}
+func (cm *ConfigMonitor) Stop() {
+	cm.mutexForSynchronousMethodCall.Lock()
+	defer cm.mutexForSynchronousMethodCall.Unlock()
 
-func (cm *ConfigMonitor) GetConfig() map[string]interface{} {
-	cm.mutexForConfig.Lock()
-	defer cm.mutexForConfig.Unlock()
-	config := map[string]interface{}{}
-	for key, val := range cm.config {
-		config[key] = val
+	if !cm.isStarted {
+		return
 	}
-	return config
+	cm.isStarted = false
+	cm.watcher.Close()
+	cm.watcher = nil
 }
 
-func (cm *ConfigMonitor) GetMonitoringCommandList() []string {
+func (cm *ConfigMonitor) GetConfig() Config {
 	cm.mutexForConfig.Lock()
 	defer cm.mutexForConfig.Unlock()
-	monitoringCommandList := []string{}
+	return copyConfig(cm.config)
+}
 
-	rawMonitoringCommandList := cm.config["monitoringCommandList"].([]interface{})
-	for _, rawMonitoringCommand := range rawMonitoringCommandList {
-		namePattern, ok := rawMonitoringCommand.(string)
-		if !ok {
-			continue
-		}
-		monitoringCommandList = append(monitoringCommandList, namePattern)
+// copyConfig returns config which doesn't share lists and maps with config,
+// so that callers can change config which they got without changing the one of ConfigMonitor
+func copyConfig(config Config) Config {
+	destinations := map[string]AlarmConfig{}
+	for name, destination := range config.Destinations {
+		destination.DeliveryWindow = destination.DeliveryWindow.copy()
+		destinations[name] = destination
 	}
-	return monitoringCommandList
+	config.MonitoringCommandList = append([]string{}, config.MonitoringCommandList...)
+	config.Destinations = destinations
+	config.AlarmConfig.DeliveryWindow = config.AlarmConfig.DeliveryWindow.copy()
+	config.CommandNormalization = config.CommandNormalization.copy()
+	config.IgnoreCommandList = copyStringList(config.IgnoreCommandList)
+	return config
 }
 
-func (cm *ConfigMonitor) GetMonitoringPeriod() int {
-	cm.mutexForConfig.Lock()
-	defer cm.mutexForConfig.Unlock()
-	rawMonitoringPeriod := cm.config["monitoringPeriod"].(string)
-	monitoringPeriod, err := strconv.Atoi(rawMonitoringPeriod)
-	if err != nil {
-		panic("monitoringPeriod value should be integer value")
+// copyStringList copies list, nil is kept as nil
+func copyStringList(list []string) []string {
+	if list == nil {
+		return nil
 	}
-	return monitoringPeriod
+	return append([]string{}, list...)
 }
 
-func (cm *ConfigMonitor) GetAlarmConfig() map[string]string {
-	cm.mutexForConfig.Lock()
-	defer cm.mutexForConfig.Unlock()
-	alarmConfig := map[string]string{}
+func (cm *ConfigMonitor) GetMonitoringCommandList() []string {
+	return cm.GetConfig().MonitoringCommandList
+}
 
-	for key, val := range cm.config["alarmConfig"].(map[string]interface{}) {
-		alarmConfig[key] = val.(string)
-	}
-	return alarmConfig
+func (cm *ConfigMonitor) GetMonitoringPeriod() time.Duration {
+	return cm.GetConfig().MonitoringPeriod
+}
+
+func (cm *ConfigMonitor) GetAlarmConfig() AlarmConfig {
+	return cm.GetConfig().AlarmConfig
+}
+
+// Redact hides resolved secrets of config in message.
+// it should be applied to every message which can contain a value of alarmConfig
+func (cm *ConfigMonitor) Redact(message string) string {
+	return RedactSecrets(message, cm.GetConfig().SecretList())
 }
@@ -1,8 +1,13 @@
 package alarm
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -19,6 +24,11 @@ func TestConfigMonitor(t *testing.T) {
 	t.Run("GetMonitoringCommandList", CheckGetMonitoringCommandList())
 	t.Run("GetMonitoringPeriod", CheckGetMonitoringPeriod())
 	t.Run("GetAlarmConfig", CheckGetAlarmConfig())
+	t.Run("SecretReferenceInAlarmConfig", CheckSecretReferenceInAlarmConfig())
+	t.Run("InvalidConfigKeepsLastValidConfig", CheckInvalidConfigKeepsLastValidConfig())
+	t.Run("AtomicRenameSave", CheckAtomicRenameSave())
+	t.Run("OnChange", CheckOnChange())
+	t.Run("ConfigIsCopied", CheckConfigIsCopied())
 }
 
 func CheckEmptyConfig() func(*testing.T) {
@@ -29,8 +39,8 @@ func CheckEmptyConfig() func(*testing.T) {
 
 		require.Equal(
 			t,
-			0,
-			len(cm.GetConfig()))
+			DefaultConfig(),
+			cm.GetConfig())
 	}
 }
 
@@ -43,15 +53,14 @@ func CheckChangeConfig() func(*testing.T) {
 		require.Equal(
 			t,
 			0,
-			len(cm.GetConfig()))
+			len(cm.GetMonitoringCommandList()))
 
 		addMonitoringCommandList()
 		time.Sleep(500 * time.Millisecond)
 
-		config := cm.GetConfig()
 		require.Greater(
 			t,
-			len(config),
+			len(cm.GetMonitoringCommandList()),
 			0)
 	}
 }
@@ -78,7 +87,7 @@ func CheckGetMonitoringPeriod() func(*testing.T) {
 
 		require.Equal(
 			t,
-			1000,
+			time.Second,
 			cm.GetMonitoringPeriod(),
 		)
 	}
@@ -91,16 +100,177 @@ func CheckGetAlarmConfig() func(*testing.T) {
 
 		require.Equal(
 			t,
-			map[string]string{
-				"type":           "slack-webhook",
-				"webHookUrl":     "localhost",
-				"requestTimeout": "10",
+			AlarmConfig{
+				Type:           SlackWebHookAlarmType,
+				WebHookUrl:     NewSecret("localhost"),
+				RequestTimeout: 10 * time.Millisecond,
 			},
 			cm.GetAlarmConfig(),
 		)
 	}
 }
 
+func CheckSecretReferenceInAlarmConfig() func(*testing.T) {
+	return func(t *testing.T) {
+		os.Setenv("ALARM_TEST_WEBHOOK", testSecret)
+		defer os.Unsetenv("ALARM_TEST_WEBHOOK")
+		addSecretReference()
+		cm := NewConfigMonitor(TestConfigPath)
+		defer cm.Stop()
+
+		require.Equal(
+			t,
+			testSecret,
+			cm.GetAlarmConfig().WebHookUrl.Value(),
+		)
+		for _, format := range []string{"%v", "%+v", "%#v"} {
+			require.NotContains(
+				t,
+				fmt.Sprintf(format, cm.GetConfig()),
+				testSecret,
+			)
+		}
+		rawConfig, err := json.Marshal(cm.GetConfig())
+		require.NoError(t, err)
+		require.NotContains(
+			t,
+			string(rawConfig),
+			testSecret,
+		)
+		require.Equal(
+			t,
+			"web hook request is failed: Post \"[REDACTED]\"",
+			cm.Redact("web hook request is failed: Post \""+testSecret+"\""),
+		)
+	}
+}
+
+func CheckInvalidConfigKeepsLastValidConfig() func(*testing.T) {
+	return func(t *testing.T) {
+		addMonitoringCommandList()
+		cm := NewConfigMonitor(TestConfigPath)
+		defer cm.Stop()
+		validConfig := cm.GetConfig()
+
+		_ = ioutil.WriteFile(TestConfigPath, []byte(`{"monitoringPeriod": "forever"}`), 0644)
+		time.Sleep(500 * time.Millisecond)
+
+		require.Equal(
+			t,
+			validConfig,
+			cm.GetConfig(),
+		)
+	}
+}
+
+// editors like vim save a file by writing a new file and renaming it onto the old one
+func CheckAtomicRenameSave() func(*testing.T) {
+	return func(t *testing.T) {
+		addMonitoringCommandList()
+		cm := NewConfigMonitor(TestConfigPath)
+		defer cm.Stop()
+
+		for _, monitoringCommand := range []string{"make build", "make e2e"} {
+			saveConfigByRename(`{
+				"monitoringCommandList": ["` + monitoringCommand + `"],
+				"alarmConfig": {"type": "slack-webhook", "webHookUrl": "localhost"}
+			}`)
+			time.Sleep(500 * time.Millisecond)
+
+			require.Equal(
+				t,
+				[]string{monitoringCommand},
+				cm.GetMonitoringCommandList(),
+			)
+		}
+	}
+}
+
+func CheckOnChange() func(*testing.T) {
+	return func(t *testing.T) {
+		addMonitoringCommandList()
+		cm := NewConfigMonitor(TestConfigPath)
+		defer cm.Stop()
+
+		mutex := sync.Mutex{}
+		changeList := [][2]Config{}
+		cm.OnChange(func(oldConfig, newConfig Config) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			changeList = append(changeList, [2]Config{oldConfig, newConfig})
+		})
+
+		// same content does not change config
+		addMonitoringCommandList()
+		time.Sleep(500 * time.Millisecond)
+		mutex.Lock()
+		require.Equal(t, 0, len(changeList))
+		mutex.Unlock()
+
+		saveConfigByRename(`{
+			"monitoringCommandList": ["go test", "make"],
+			"monitoringPeriod": "1000",
+			"alarmConfig": {"type": "slack-webhook", "webHookUrl": "localhost", "requestTimeout": "10"}
+		}`)
+		time.Sleep(500 * time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Equal(t, 1, len(changeList))
+		require.Equal(t, []string{"go test"}, changeList[0][0].MonitoringCommandList)
+		require.Equal(t, []string{"go test", "make"}, changeList[0][1].MonitoringCommandList)
+	}
+}
+
+// config of ConfigMonitor is not changed by changing the one which it returned
+func CheckConfigIsCopied() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "config")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		configPath := filepath.Join(dir, "config.json")
+		require.NoError(t, ioutil.WriteFile(configPath, []byte(`{
+			"monitoringCommandList": ["go test"],
+			"alarmConfig": {
+				"type": "slack-webhook", "webHookUrl": "localhost",
+				"deliveryWindow": {"windowList": [{"weekdays": ["mon"], "start": "09:00", "end": "18:00"}], "urgentPatternList": ["deploy"]}
+			},
+			"destinations": {
+				"team": {
+					"type": "slack-webhook", "webHookUrl": "localhost",
+					"deliveryWindow": {"windowList": [{"weekdays": ["tue"], "start": "09:00", "end": "18:00"}], "urgentPatternList": ["release"]}
+				}
+			},
+			"commandNormalization": {"dropArgumentList": ["^-v$"], "collapsePathList": ["/tmp"], "rewriteList": [{"pattern": "[0-9]+", "replacement": "N"}]},
+			"ignoreCommandList": ["jq"]
+		}`), 0644))
+		expectedConfig, err := ReadConfigFile(configPath)
+		require.NoError(t, err)
+		cm := NewConfigMonitor(configPath)
+		defer cm.Stop()
+		require.Equal(t, expectedConfig, cm.GetConfig())
+
+		config := cm.GetConfig()
+		config.MonitoringCommandList[0] = "changed"
+		config.AlarmConfig.DeliveryWindow.WindowList[0].Weekdays[0] = "sun"
+		config.AlarmConfig.DeliveryWindow.WindowList[0].Start = "00:00"
+		config.AlarmConfig.DeliveryWindow.UrgentPatternList[0] = "changed"
+		config.Destinations["team"].DeliveryWindow.WindowList[0].Weekdays[0] = "sun"
+		config.Destinations["team"].DeliveryWindow.UrgentPatternList[0] = "changed"
+		delete(config.Destinations, "team")
+		config.CommandNormalization.DropArgumentList[0] = "changed"
+		config.CommandNormalization.CollapsePathList[0] = "changed"
+		config.CommandNormalization.RewriteList[0].Pattern = "changed"
+		config.IgnoreCommandList[0] = "changed"
+		require.Equal(t, expectedConfig, cm.GetConfig())
+	}
+}
+
+func saveConfigByRename(configContent string) {
+	tmpConfigPath := TestConfigPath + ".swp"
+	_ = ioutil.WriteFile(tmpConfigPath, []byte(configContent), 0644)
+	_ = os.Rename(tmpConfigPath, TestConfigPath)
+}
+
 func prepareEmptyConfig() {
 	configContent := "{}\n"
 	_ = ioutil.WriteFile(TestConfigPath, []byte(configContent), 0644)
@@ -122,3 +292,20 @@ func addMonitoringCommandList() {
 	`)
 	_ = ioutil.WriteFile(TestConfigPath, []byte(configContent), 0644)
 }
+
+func addSecretReference() {
+	configContent := strings.TrimSpace(`
+	{
+		"monitoringCommandList" : [
+			"go test"
+		],
+		"monitoringPeriod": "1000",
+		"alarmConfig" : {
+			"type": "slack-webhook",
+			"webHookUrl":"env:ALARM_TEST_WEBHOOK",
+			"requestTimeout": "10"
+		}
+	}
+	`)
+	_ = ioutil.WriteFile(TestConfigPath, []byte(configContent), 0644)
+}
@@ -0,0 +1,244 @@
+package alarm
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("DecodeConfig", CheckDecodeConfig())
+	t.Run("DecodeDuration", CheckDecodeDuration())
+	t.Run("DecodeEnvironmentMarker", CheckDecodeEnvironmentMarker())
+	t.Run("ValidationErrors", CheckValidationErrors())
+}
+
+func CheckDecodeConfig() func(*testing.T) {
+	return func(t *testing.T) {
+		config, err := DecodeConfig(mustUnmarshalJson(`
+		{
+			"monitoringCommandList": ["go test", "make"],
+			"alarmConfig": {
+				"type": "slack-webhook",
+				"webHookUrl": "localhost"
+			}
+		}`), nil)
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			Config{
+				MonitoringCommandList: []string{"go test", "make"},
+				MonitoringPeriod:      defaultMonitoringPeriod,
+				AlarmConfig: AlarmConfig{
+					Type:           SlackWebHookAlarmType,
+					WebHookUrl:     NewSecret("localhost"),
+					RequestTimeout: defaultRequestTimeout,
+				},
+				Destinations:          map[string]AlarmConfig{},
+				EnvironmentMarker:     defaultEnvironmentMarker,
+				ShellCommandThreshold: defaultShellCommandThreshold,
+				HistoryRetention:      defaultHistoryRetention,
+				HistoryMaxRunCount:    defaultHistoryMaxRunCount,
+				RegressionPercent:     defaultRegressionPercent,
+				RegressionSampleCount: defaultRegressionSampleCount,
+				CommandNormalization:  DefaultCommandNormalization(),
+				PatternMatchPolicy:    MostSpecificPatternMatchPolicy,
+				IgnoreCommandList:     []string{},
+			},
+			config,
+		)
+	}
+}
+
+func CheckDecodeDuration() func(*testing.T) {
+	return func(t *testing.T) {
+		for rawDuration, duration := range map[string]time.Duration{
+			`1000`:     time.Second,
+			`"1000"`:   time.Second,
+			`"5s"`:     5 * time.Second,
+			`"1m30s"`:  90 * time.Second,
+			`"250ms"`:  250 * time.Millisecond,
+			`2.5e3`:    2500 * time.Millisecond,
+			`"2000ms"`: 2 * time.Second,
+		} {
+			config, err := DecodeConfig(mustUnmarshalJson(`
+			{
+				"monitoringPeriod": `+rawDuration+`,
+				"alarmConfig": {
+					"type": "slack-webhook",
+					"webHookUrl": "localhost"
+				}
+			}`), nil)
+			require.NoError(t, err, rawDuration)
+			require.Equal(t, duration, config.MonitoringPeriod, rawDuration)
+		}
+	}
+}
+
+func CheckDecodeEnvironmentMarker() func(*testing.T) {
+	return func(t *testing.T) {
+		for rawEnvironmentMarker, environmentMarker := range map[string]string{
+			`"ALARM_ME"`:      "ALARM_ME",
+			`"ALARM_NIGHTLY"`: "ALARM_NIGHTLY",
+			`""`:              "",
+		} {
+			config, err := DecodeConfig(mustUnmarshalJson(`
+			{
+				"environmentMarker": `+rawEnvironmentMarker+`,
+				"alarmConfig": {
+					"type": "slack-webhook",
+					"webHookUrl": "localhost"
+				}
+			}`), nil)
+			require.NoError(t, err, rawEnvironmentMarker)
+			require.Equal(t, environmentMarker, config.EnvironmentMarker, rawEnvironmentMarker)
+		}
+
+		for _, rawEnvironmentMarker := range []string{`"NOTIFY_ME"`, `"ALARM_ME=1"`, `true`} {
+			_, err := DecodeConfig(mustUnmarshalJson(`
+			{
+				"environmentMarker": `+rawEnvironmentMarker+`,
+				"alarmConfig": {
+					"type": "slack-webhook",
+					"webHookUrl": "localhost"
+				}
+			}`), nil)
+			require.Error(t, err, rawEnvironmentMarker)
+		}
+	}
+}
+
+func CheckValidationErrors() func(*testing.T) {
+	return func(t *testing.T) {
+		_, err := DecodeConfig(mustUnmarshalJson(`
+		{
+			"monitoringCommandList": ["go test", 3, "", "@pid 1"],
+			"monitoringPeriod": "forever",
+			"alarmConfig": {
+				"type": "email",
+				"requestTimeOut": "10"
+			}
+		}`), nil)
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{Path: "$.monitoringCommandList[1]", Message: "should be a string, not a number"},
+				{Path: "$.monitoringCommandList[2]", Message: "should not be empty"},
+				{Path: "$.monitoringCommandList[3]", Message: `pattern can not start with "@", it is reserved for processes watched by pid or opted in by environment marker`},
+				{Path: "$.monitoringPeriod", Message: `"forever" is neither milliseconds nor a duration like "5s"`},
+				{Path: "$.alarmConfig.requestTimeOut", Message: "unknown field"},
+				{Path: "$.alarmConfig.type", Message: `unknown type "email", it should be "slack-webhook"`},
+				{Path: "$.alarmConfig.webHookUrl", Message: "required"},
+			},
+			err,
+		)
+
+		_, err = DecodeConfig(mustUnmarshalJson(`
+		{
+			"alarmConfig": {"type": "slack-webhook", "webHookUrl": "localhost"},
+			"historyRetention": "-1h",
+			"historyMaxRunCount": 1.5,
+			"almostDonePercent": 100,
+			"regressionPercent": 90,
+			"regressionSampleCount": 0,
+			"patternMatchPolicy": "last",
+			"ignoreCommandList": ["jq", "/usr/bin/jq"]
+		}`), nil)
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{Path: "$.historyRetention", Message: "should not be negative"},
+				{Path: "$.historyMaxRunCount", Message: "should be an integer, not 1.5"},
+				{Path: "$.almostDonePercent", Message: "should be less than 100, not 100"},
+				{Path: "$.regressionPercent", Message: "should be more than 100, not 90"},
+				{Path: "$.regressionSampleCount", Message: "should be between 1 and 20, not 0"},
+				{Path: "$.patternMatchPolicy", Message: `unknown policy "last", it should be one of first, mostSpecific, all`},
+				{Path: "$.ignoreCommandList[1]", Message: `should be a command name without path, not "/usr/bin/jq"`},
+			},
+			err,
+		)
+
+		_, err = DecodeConfig(mustUnmarshalJson(`
+		{
+			"alarmConfig": {"type": "slack-webhook", "webHookUrl": "localhost"},
+			"commandNormalization": {
+				"dropArgumentList": ["("],
+				"collapsePathList": ["tmp"],
+				"rewriteList": [{"pattern": "[0-9"}, {"replacement": 3}]
+			}
+		}`), nil)
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{Path: "$.commandNormalization.dropArgumentList[0]", Message: `"(" is not a regular expression: error parsing regexp: missing closing ): ` + "`(`"},
+				{Path: "$.commandNormalization.collapsePathList[0]", Message: `"tmp" should be an absolute path or start with ~/`},
+				{Path: "$.commandNormalization.rewriteList[0].pattern", Message: `"[0-9" is not a regular expression: error parsing regexp: missing closing ]: ` + "`[0-9`"},
+				{Path: "$.commandNormalization.rewriteList[1].pattern", Message: "required"},
+				{Path: "$.commandNormalization.rewriteList[1].replacement", Message: "should be a string, not a number"},
+			},
+			err,
+		)
+
+		config, err := DecodeConfig(mustUnmarshalJson(`
+		{
+			"alarmConfig": {
+				"type": "slack-webhook",
+				"webHookUrl": "localhost",
+				"deliveryWindow": {"windowList": [{"start": "09:00", "end": "18:00"}], "urgentPatternList": ["deploy"]}
+			}
+		}`), nil)
+		require.NoError(t, err)
+		require.Equal(
+			t,
+			DeliveryWindow{
+				WindowList:        []TimeWindow{{Weekdays: []string{}, Start: "09:00", End: "18:00"}},
+				OutsidePolicy:     DigestOutsidePolicy,
+				UrgentPatternList: []string{"deploy"},
+			},
+			config.AlarmConfig.DeliveryWindow,
+		)
+
+		_, err = DecodeConfig(mustUnmarshalJson(`
+		{
+			"alarmConfig": {
+				"type": "slack-webhook",
+				"webHookUrl": "localhost",
+				"deliveryWindow": {
+					"timeZone": "Mars/Olympus",
+					"windowList": [{"weekdays": ["mon", "someday"], "start": "9am"}],
+					"outsidePolicy": "queue"
+				}
+			}
+		}`), nil)
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{Path: "$.alarmConfig.deliveryWindow.timeZone", Message: `unknown time zone "Mars/Olympus"`},
+				{Path: "$.alarmConfig.deliveryWindow.windowList[0].weekdays[1]", Message: `unknown weekday "someday", it should be one of sun, mon, tue, wed, thu, fri, sat`},
+				{Path: "$.alarmConfig.deliveryWindow.windowList[0].start", Message: `should be a time of day like 09:00, not "9am"`},
+				{Path: "$.alarmConfig.deliveryWindow.windowList[0].end", Message: "required"},
+				{Path: "$.alarmConfig.deliveryWindow.outsidePolicy", Message: `unknown policy "queue", it should be one of digest, drop`},
+			},
+			err,
+		)
+
+		_, err = DecodeConfig(mustUnmarshalJson(`{"alarmConfig": []}`), nil)
+		require.Equal(
+			t,
+			ConfigErrorList{
+				{Path: "$.alarmConfig", Message: "should be an object, not a list"},
+			},
+			err,
+		)
+	}
+}
+
+func mustUnmarshalJson(data string) map[string]interface{} {
+	rawConfig := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(data), &rawConfig); err != nil {
+		panic(err)
+	}
+	return rawConfig
+}
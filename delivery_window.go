@@ -0,0 +1,145 @@
+package alarm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DigestOutsidePolicy holds alarms outside of delivery windows and sends them as one digest when a window opens
+	DigestOutsidePolicy = "digest"
+	// DropOutsidePolicy drops alarms outside of delivery windows, they are still recorded and counted
+	DropOutsidePolicy = "drop"
+)
+
+var OutsidePolicyList = []string{DigestOutsidePolicy, DropOutsidePolicy}
+
+var weekdayByName = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// DeliveryWindow is when alarms are sent to a destination, e.g. only in working hours.
+// alarms are sent at any time if WindowList is empty
+type DeliveryWindow struct {
+	// TimeZone is a name of IANA time zone database like "Asia/Seoul", local time zone is used if it is empty
+	TimeZone   string       `json:"timeZone"`
+	WindowList []TimeWindow `json:"windowList"`
+	// OutsidePolicy is what happens to alarms outside of windows, it is one of OutsidePolicyList
+	OutsidePolicy string `json:"outsidePolicy"`
+	// UrgentPatternList is patterns whose failed runs are alarmed outside of windows too,
+	// they match like patterns of mute rules
+	UrgentPatternList []string `json:"urgentPatternList"`
+}
+
+// TimeWindow is hours of weekdays, e.g. from 09:00 to 18:00 from mon to fri
+type TimeWindow struct {
+	// Weekdays is names like "mon" and "tue", every day if it is empty.
+	// window which passes midnight belongs to the day when it starts
+	Weekdays []string `json:"weekdays"`
+	// Start and End are times of day like "09:00", window passes midnight if End is before Start.
+	// the same Start and End is the whole day
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// copy returns DeliveryWindow which doesn't share lists with dw
+func (dw DeliveryWindow) copy() DeliveryWindow {
+	if dw.WindowList != nil {
+		windowList := []TimeWindow{}
+		for _, timeWindow := range dw.WindowList {
+			timeWindow.Weekdays = copyStringList(timeWindow.Weekdays)
+			windowList = append(windowList, timeWindow)
+		}
+		dw.WindowList = windowList
+	}
+	dw.UrgentPatternList = copyStringList(dw.UrgentPatternList)
+	return dw
+}
+
+// IsOpen reports whether alarms are sent at now
+func (dw DeliveryWindow) IsOpen(now time.Time) bool {
+	if len(dw.WindowList) == 0 {
+		return true
+	}
+	location, err := dw.location()
+	if err != nil {
+		// invalid config is refused when it is read, so this is not expected
+		return true
+	}
+	now = now.In(location)
+	for _, timeWindow := range dw.WindowList {
+		if timeWindow.isOpen(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUrgent reports whether the alarm of monitoringCommand and command is sent outside of windows
+func (dw DeliveryWindow) IsUrgent(monitoringCommand string, command string, isFailed bool) bool {
+	if !isFailed {
+		return false
+	}
+	for _, pattern := range dw.UrgentPatternList {
+		if pattern == monitoringCommand || strings.Contains(command, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (dw DeliveryWindow) location() (*time.Location, error) {
+	if dw.TimeZone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(dw.TimeZone)
+}
+
+func (tw TimeWindow) isOpen(now time.Time) bool {
+	start, err := parseTimeOfDay(tw.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseTimeOfDay(tw.End)
+	if err != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	yesterday := now.AddDate(0, 0, -1).Weekday()
+	switch {
+	case start == end:
+		return tw.hasWeekday(now.Weekday())
+	case start < end:
+		return tw.hasWeekday(now.Weekday()) && start <= minute && minute < end
+	default:
+		return (tw.hasWeekday(now.Weekday()) && start <= minute) || (tw.hasWeekday(yesterday) && minute < end)
+	}
+}
+
+func (tw TimeWindow) hasWeekday(weekday time.Weekday) bool {
+	if len(tw.Weekdays) == 0 {
+		return true
+	}
+	for _, name := range tw.Weekdays {
+		if _weekday, ok := weekdayByName[strings.ToLower(name)]; ok && _weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeOfDay returns minutes since midnight of time like "09:00"
+func parseTimeOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day like 09:00", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
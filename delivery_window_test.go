@@ -0,0 +1,72 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryWindow(t *testing.T) {
+	t.Run("OpenWindow", CheckOpenWindow())
+	t.Run("UrgentPattern", CheckUrgentPattern())
+}
+
+func CheckOpenWindow() func(*testing.T) {
+	return func(t *testing.T) {
+		seoul, err := time.LoadLocation("Asia/Seoul")
+		require.NoError(t, err)
+		// 2024-05-03 is friday
+		friday := func(hour int, minute int) time.Time {
+			return time.Date(2024, 5, 3, hour, minute, 0, 0, seoul)
+		}
+
+		require.True(t, DeliveryWindow{}.IsOpen(friday(3, 0)))
+
+		workingHours := DeliveryWindow{
+			TimeZone: "Asia/Seoul",
+			WindowList: []TimeWindow{
+				{Weekdays: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00"},
+			},
+		}
+		require.False(t, workingHours.IsOpen(friday(3, 0)))
+		require.True(t, workingHours.IsOpen(friday(9, 0)))
+		require.False(t, workingHours.IsOpen(friday(18, 0)))
+		require.False(t, workingHours.IsOpen(friday(10, 0).AddDate(0, 0, 1)))
+		// time zone of now doesn't matter
+		require.True(t, workingHours.IsOpen(friday(10, 0).UTC()))
+
+		// window passing midnight belongs to the day when it starts
+		nightShift := DeliveryWindow{
+			TimeZone: "Asia/Seoul",
+			WindowList: []TimeWindow{
+				{Weekdays: []string{"Fri"}, Start: "22:00", End: "06:00"},
+			},
+		}
+		require.True(t, nightShift.IsOpen(friday(23, 0)))
+		require.True(t, nightShift.IsOpen(friday(5, 59).AddDate(0, 0, 1)))
+		require.False(t, nightShift.IsOpen(friday(5, 0)))
+		require.False(t, nightShift.IsOpen(friday(12, 0)))
+
+		weekend := DeliveryWindow{
+			TimeZone: "Asia/Seoul",
+			WindowList: []TimeWindow{
+				{Weekdays: []string{"sat", "sun"}, Start: "00:00", End: "00:00"},
+			},
+		}
+		require.False(t, weekend.IsOpen(friday(12, 0)))
+		require.True(t, weekend.IsOpen(friday(12, 0).AddDate(0, 0, 2)))
+	}
+}
+
+func CheckUrgentPattern() func(*testing.T) {
+	return func(t *testing.T) {
+		deliveryWindow := DeliveryWindow{
+			UrgentPatternList: []string{"deploy"},
+		}
+		require.True(t, deliveryWindow.IsUrgent("deploy", "deploy.sh production", true))
+		require.True(t, deliveryWindow.IsUrgent("shell", "make deploy", true))
+		require.False(t, deliveryWindow.IsUrgent("deploy", "deploy.sh production", false))
+		require.False(t, deliveryWindow.IsUrgent("make", "make build", true))
+	}
+}
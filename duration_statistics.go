@@ -0,0 +1,139 @@
+package alarm
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// DurationSampleCount is the number of the latest runs which statistics are computed from
+	DurationSampleCount = 20
+	// minimumDurationSampleCount is the number of runs which are needed to expect duration of the next run
+	minimumDurationSampleCount = 3
+)
+
+// DurationStatistics is durations of the latest runs of a command
+type DurationStatistics struct {
+	SampleCount int
+	Median      time.Duration
+	P90         time.Duration
+	// LastDurationList is durations of the runs in the order they are finished
+	LastDurationList []time.Duration
+}
+
+func NewDurationStatistics(durationList []time.Duration) DurationStatistics {
+	if len(durationList) > DurationSampleCount {
+		durationList = durationList[len(durationList)-DurationSampleCount:]
+	}
+	sortedDurationList := append([]time.Duration{}, durationList...)
+	sort.Slice(sortedDurationList, func(i, j int) bool {
+		return sortedDurationList[i] < sortedDurationList[j]
+	})
+	ds := DurationStatistics{
+		SampleCount:      len(durationList),
+		LastDurationList: append([]time.Duration{}, durationList...),
+	}
+	if len(sortedDurationList) == 0 {
+		return ds
+	}
+	middle := len(sortedDurationList) / 2
+	ds.Median = sortedDurationList[middle]
+	if len(sortedDurationList)%2 == 0 {
+		ds.Median = (sortedDurationList[middle-1] + sortedDurationList[middle]) / 2
+	}
+	// nearest rank, so p90 is always a duration which a run actually took
+	rank := (len(sortedDurationList)*90 + 99) / 100
+	ds.P90 = sortedDurationList[rank-1]
+	return ds
+}
+
+// ExpectedDuration is the median, it is not expected until enough runs are finished
+func (ds DurationStatistics) ExpectedDuration() (time.Duration, bool) {
+	if ds.SampleCount < minimumDurationSampleCount {
+		return 0, false
+	}
+	return ds.Median, true
+}
+
+// GetDurationStatistics returns statistics of the latest runs of the job except the given run.
+// failed runs are excluded because a command which fails fast doesn't tell how long it takes
+func (rhs *RunHistoryStore) GetDurationStatistics(run Run) DurationStatistics {
+	durationList := []time.Duration{}
+	for _, sampleRun := range rhs.findSampleRunList(run, func(Run) bool { return true }) {
+		durationList = append(durationList, sampleRun.Duration())
+	}
+	return NewDurationStatistics(durationList)
+}
+
+// PeakMemoryStatistics is peak memory of the latest runs of a command, which are jobs found by monitoringCommand
+type PeakMemoryStatistics struct {
+	SampleCount int
+	Median      uint64
+}
+
+func NewPeakMemoryStatistics(peakMemoryList []uint64) PeakMemoryStatistics {
+	if len(peakMemoryList) > DurationSampleCount {
+		peakMemoryList = peakMemoryList[len(peakMemoryList)-DurationSampleCount:]
+	}
+	sortedPeakMemoryList := append([]uint64{}, peakMemoryList...)
+	sort.Slice(sortedPeakMemoryList, func(i, j int) bool {
+		return sortedPeakMemoryList[i] < sortedPeakMemoryList[j]
+	})
+	pms := PeakMemoryStatistics{
+		SampleCount: len(peakMemoryList),
+	}
+	if len(sortedPeakMemoryList) == 0 {
+		return pms
+	}
+	middle := len(sortedPeakMemoryList) / 2
+	pms.Median = sortedPeakMemoryList[middle]
+	if len(sortedPeakMemoryList)%2 == 0 {
+		pms.Median = sortedPeakMemoryList[middle-1]/2 + sortedPeakMemoryList[middle]/2
+	}
+	return pms
+}
+
+// GetPeakMemoryStatistics returns statistics of the latest runs of the job except the given run,
+// runs whose memory is not measured, e.g. commands reported by shell hooks, are excluded
+func (rhs *RunHistoryStore) GetPeakMemoryStatistics(run Run) PeakMemoryStatistics {
+	peakMemoryList := []uint64{}
+	for _, sampleRun := range rhs.findSampleRunList(run, func(sampleRun Run) bool { return sampleRun.PeakMemory != 0 }) {
+		peakMemoryList = append(peakMemoryList, sampleRun.PeakMemory)
+	}
+	return NewPeakMemoryStatistics(peakMemoryList)
+}
+
+// findSampleRunList returns the latest DurationSampleCount runs of the same job key which are not failed,
+// in the order they are finished
+func (rhs *RunHistoryStore) findSampleRunList(run Run, isSample func(Run) bool) []Run {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	finishedRunList := rhs.getFinishedRunListOfJobKey(rhs.commandNormalizer.JobKey(run.Command))
+	key := run.key()
+	sampleRunList := []Run{}
+	for i := len(finishedRunList) - 1; i >= 0 && len(sampleRunList) < DurationSampleCount; i-- {
+		finishedRun := finishedRunList[i]
+		if finishedRun.Status() == RunFailed || finishedRun.key() == key || !isSample(finishedRun) {
+			continue
+		}
+		sampleRunList = append(sampleRunList, finishedRun)
+	}
+	// runs are collected from the latest one
+	for i, j := 0, len(sampleRunList)-1; i < j; i, j = i+1, j-1 {
+		sampleRunList[i], sampleRunList[j] = sampleRunList[j], sampleRunList[i]
+	}
+	return sampleRunList
+}
+
+// getFinishedRunListOfJobKey returns finished runs of the job key in the order they are finished,
+// job keys of every run are computed only when the index is built again
+func (rhs *RunHistoryStore) getFinishedRunListOfJobKey(jobKey string) []Run {
+	if rhs.finishedRunListByJobKey == nil {
+		rhs.finishedRunListByJobKey = map[string][]Run{}
+		for _, finishedRun := range rhs.finishedRunList {
+			finishedRunJobKey := rhs.commandNormalizer.JobKey(finishedRun.Command)
+			rhs.finishedRunListByJobKey[finishedRunJobKey] = append(rhs.finishedRunListByJobKey[finishedRunJobKey], finishedRun)
+		}
+	}
+	return rhs.finishedRunListByJobKey[jobKey]
+}
@@ -0,0 +1,113 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationStatistics(t *testing.T) {
+	t.Run("MedianAndP90", CheckMedianAndP90())
+	t.Run("ExpectedDuration", CheckExpectedDuration())
+	t.Run("DurationStatisticsOfRun", CheckDurationStatisticsOfRun())
+}
+
+func CheckMedianAndP90() func(*testing.T) {
+	return func(t *testing.T) {
+		ds := NewDurationStatistics([]time.Duration{3 * time.Second, time.Second, 2 * time.Second})
+		require.Equal(t, 3, ds.SampleCount)
+		require.Equal(t, 2*time.Second, ds.Median)
+		require.Equal(t, 3*time.Second, ds.P90)
+		require.Equal(t, []time.Duration{3 * time.Second, time.Second, 2 * time.Second}, ds.LastDurationList)
+
+		ds = NewDurationStatistics([]time.Duration{4 * time.Second, time.Second, 2 * time.Second, 3 * time.Second})
+		require.Equal(t, 2500*time.Millisecond, ds.Median)
+
+		// only the latest runs are sampled
+		durationList := []time.Duration{}
+		for i := 1; i <= DurationSampleCount+10; i++ {
+			durationList = append(durationList, time.Duration(i)*time.Second)
+		}
+		ds = NewDurationStatistics(durationList)
+		require.Equal(t, DurationSampleCount, ds.SampleCount)
+		require.Equal(t, 11*time.Second, ds.LastDurationList[0])
+		require.Equal(t, 20500*time.Millisecond, ds.Median)
+		require.Equal(t, 28*time.Second, ds.P90)
+
+		ds = NewDurationStatistics(nil)
+		require.Zero(t, ds.SampleCount)
+		require.Zero(t, ds.Median)
+	}
+}
+
+func CheckExpectedDuration() func(*testing.T) {
+	return func(t *testing.T) {
+		_, ok := NewDurationStatistics([]time.Duration{time.Second, time.Second}).ExpectedDuration()
+		require.False(t, ok)
+
+		expectedDuration, ok := NewDurationStatistics([]time.Duration{time.Second, time.Second, 4 * time.Second}).ExpectedDuration()
+		require.True(t, ok)
+		require.Equal(t, time.Second, expectedDuration)
+	}
+}
+
+func CheckDurationStatisticsOfRun() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		rhs, err := OpenRunHistoryStore(filepath.Join(dir, RunHistoryFileName), 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+
+		now := time.Now().UTC().Round(0)
+		finish := func(pid int, command string, duration time.Duration, exitCode int) Run {
+			run := newTestRun(pid, now.Add(-duration))
+			run.Command = command
+			run = finishTestRun(run, now, exitCode)
+			_, err := rhs.FinishRun(run)
+			require.NoError(t, err)
+			return run
+		}
+		finish(1, "go test ./...", time.Minute, 0)
+		finish(2, "go  test ./...", 2*time.Minute, 0)
+		// failed and other runs are not samples
+		finish(3, "go test ./...", time.Second, 1)
+		finish(4, "make build", time.Hour, 0)
+		latestRun := finish(5, "go test ./...", 3*time.Minute, 0)
+
+		ds := rhs.GetDurationStatistics(newTestRun(6, now))
+		require.Equal(t, []time.Duration{time.Hour}, ds.LastDurationList)
+
+		runningRun := newTestRun(6, now)
+		runningRun.Command = "go test ./..."
+		ds = rhs.GetDurationStatistics(runningRun)
+		require.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}, ds.LastDurationList)
+		require.Equal(t, 2*time.Minute, ds.Median)
+
+		// statistics of finished run are computed from the other runs
+		ds = rhs.GetDurationStatistics(latestRun)
+		require.Equal(t, []time.Duration{time.Minute, 2 * time.Minute}, ds.LastDurationList)
+
+		// runs finished or dropped after statistics are read are reflected
+		finish(7, "go test ./...", 4*time.Minute, 0)
+		ds = rhs.GetDurationStatistics(runningRun)
+		require.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute}, ds.LastDurationList)
+		commandNormalizer, err := NewCommandNormalizer(CommandNormalization{
+			RewriteList: []CommandRewrite{{Pattern: `^make build$`, Replacement: "go test ./..."}},
+		})
+		require.NoError(t, err)
+		rhs.SetCommandNormalizer(commandNormalizer)
+		ds = rhs.GetDurationStatistics(runningRun)
+		require.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, time.Hour, 3 * time.Minute, 4 * time.Minute}, ds.LastDurationList)
+		rhs.SetCommandNormalizer(nil)
+		rhs.SetRetention(0, 3)
+		finish(8, "go test ./...", 5*time.Minute, 0)
+		ds = rhs.GetDurationStatistics(runningRun)
+		require.Equal(t, []time.Duration{3 * time.Minute, 4 * time.Minute, 5 * time.Minute}, ds.LastDurationList)
+	}
+}
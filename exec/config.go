@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+)
+
+const configUsage = `config validate | show [--effective] | init [--force] | convert src dst`
+
+func runConfigCommand(o *options, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: alarm %s\n", configUsage)
+		return 2
+	}
+	switch args[0] {
+	case "validate":
+		return validateConfig(o, args[1:])
+	case "show":
+		return showConfig(o, args[1:])
+	case "init":
+		return initConfig(o, args[1:])
+	case "convert":
+		return convertConfig(o, args[1:])
+	}
+	fmt.Fprintf(os.Stderr, "unknown config command %q\nusage: alarm %s\n", args[0], configUsage)
+	return 2
+}
+
+func validateConfig(o *options, args []string) int {
+	flagSet := newFlagSet(o, "config validate", "config validate")
+	flagSet.Parse(args)
+
+	if _, ok := o.readConfig(); !ok {
+		return 1
+	}
+	fmt.Printf("config of %s is valid\n", o.getConfigPath())
+	return 0
+}
+
+func showConfig(o *options, args []string) int {
+	flagSet := newFlagSet(o, "config show", "config show [--effective]")
+	effective := flagSet.Bool("effective", false, "print the layer where each value came from")
+	flagSet.Parse(args)
+
+	effectiveConfig, err := o.getConfigLayers().ReadEffectiveConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read config: %v\n", err)
+		return 1
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, effectiveConfigValue := range effectiveConfig {
+		rawValue, _ := json.Marshal(effectiveConfigValue.Value)
+		if *effective {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", effectiveConfigValue.Path, rawValue, effectiveConfigValue.Source)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", effectiveConfigValue.Path, rawValue)
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+func initConfig(o *options, args []string) int {
+	flagSet := newFlagSet(o, "config init", "config init [--force]")
+	force := flagSet.Bool("force", false, "overwrite existing config file")
+	flagSet.Parse(args)
+
+	configPath := o.getConfigPath()
+	if _, err := os.Stat(configPath); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "config file %s already exists, --force overwrites it\n", configPath)
+		return 1
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create directory of config file: %v\n", err)
+		return 1
+	}
+	// web hook is referred by environment variable so that the config file can be shared
+	rawConfig := map[string]interface{}{
+		"monitoringCommandList": []interface{}{"go test", "make"},
+		"monitoringPeriod":      "1s",
+		"minimumDuration":       "10s",
+		"alarmConfig": map[string]interface{}{
+			"type":       monitor.SlackWebHookAlarmType,
+			"webHookUrl": monitor.EnvSecretReferencePrefix + "SLACK_WEBHOOK",
+		},
+	}
+	if err := monitor.WriteConfigFile(configPath, rawConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write config file: %v\n", err)
+		return 1
+	}
+	fmt.Printf("config is written to %s\n", configPath)
+	fmt.Println("set SLACK_WEBHOOK or alarmConfig.webHookUrl to the url of your web hook")
+	return 0
+}
+
+func convertConfig(o *options, args []string) int {
+	flagSet := newFlagSet(o, "config convert", "config convert src dst")
+	flagSet.Parse(args)
+	if flagSet.NArg() != 2 {
+		flagSet.Usage()
+		return 2
+	}
+
+	srcConfigPath, dstConfigPath := flagSet.Arg(0), flagSet.Arg(1)
+	err := monitor.ConvertConfigFile(srcConfigPath, dstConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to convert %s into %s: %v\n", srcConfigPath, dstConfigPath, err)
+		return 1
+	}
+	fmt.Printf("%s is converted into %s\n", srcConfigPath, dstConfigPath)
+	return 0
+}
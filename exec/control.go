@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+const (
+	patternUsage = `pattern list | add pattern | remove pattern`
+	muteUsage    = `mute [--pattern pattern] [--destination name] [--for duration | --until time] | mute list`
+	unmuteUsage  = `unmute [--id id | --all | [--pattern pattern] [--destination name]]`
+)
+
+// dialDaemon connects to control socket of running daemon
+func dialDaemon(o *options) (*alarm.ControlClient, bool) {
+	controlClient, err := alarm.DialControlServer(o.getSocketPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "daemon is not running on %s: %v\n", o.getSocketPath(), err)
+		return nil, false
+	}
+	return controlClient, true
+}
+
+func runPatternCommand(o *options, args []string) int {
+	flagSet := newFlagSet(o, "pattern", patternUsage)
+	flagSet.Parse(args)
+	args = flagSet.Args()
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		flagSet.Usage()
+		return 2
+	}
+
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+
+	var patternList alarm.PatternListReply
+	var err error
+	switch args[0] {
+	case "list":
+		patternList, err = controlClient.ListPatterns()
+	case "add":
+		patternList, err = controlClient.AddPattern(args[1])
+	case "remove":
+		patternList, err = controlClient.RemovePattern(args[1])
+	default:
+		flagSet.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to %s pattern: %v\n", args[0], err)
+		return 1
+	}
+	printPatternList(patternList)
+	return 0
+}
+
+func printPatternList(patternList alarm.PatternListReply) {
+	for _, namePattern := range patternList.Tracked {
+		note := ""
+		if findString(namePattern, patternList.Added) {
+			note = "  (added)"
+		} else if !findString(namePattern, patternList.MonitoringCommandList) {
+			note = "  (project)"
+		}
+		fmt.Printf("%s%s\n", namePattern, note)
+	}
+	for _, namePattern := range patternList.Removed {
+		fmt.Printf("%s  (removed)\n", namePattern)
+	}
+}
+
+func mute(o *options, args []string) int {
+	flagSet := newFlagSet(o, "mute", muteUsage)
+	pattern := flagSet.String("pattern", "", "mute alarms of the pattern, or of commands which contain it")
+	destinationName := flagSet.String("destination", "", "mute alarms sent to the named destination of config")
+	duration := flagSet.String("for", "", "mute for the duration, e.g. 2h or 3d")
+	until := flagSet.String("until", "", "mute until the time, e.g. 09:00, \"2006-01-02 15:04\" or RFC 3339")
+	flagSet.Parse(args)
+	isList := flagSet.NArg() == 1 && flagSet.Arg(0) == "list"
+	if (flagSet.NArg() != 0 && !isList) || (*duration != "" && *until != "") {
+		flagSet.Usage()
+		return 2
+	}
+
+	muteArgs := alarm.MuteArgs{
+		Pattern:     *pattern,
+		Destination: *destinationName,
+	}
+	now := time.Now()
+	if *duration != "" {
+		muteDuration, err := monitor.ParseMuteDuration(*duration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --for: %v\n", err)
+			return 2
+		}
+		muteArgs.ExpiresAt = now.Add(muteDuration)
+	}
+	if *until != "" {
+		var err error
+		if muteArgs.ExpiresAt, err = monitor.ParseMuteUntil(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --until: %v\n", err)
+			return 2
+		}
+	}
+
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+
+	var muteState alarm.MuteReply
+	var err error
+	if isList {
+		muteState, err = controlClient.GetMuteState()
+	} else {
+		muteState, err = controlClient.Mute(muteArgs)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to mute: %v\n", err)
+		return 1
+	}
+	printMuteRuleList(muteState.MuteRuleList)
+	return 0
+}
+
+func unmute(o *options, args []string) int {
+	flagSet := newFlagSet(o, "unmute", unmuteUsage)
+	id := flagSet.Int("id", 0, "remove the mute rule of id")
+	isAll := flagSet.Bool("all", false, "remove every mute rule")
+	pattern := flagSet.String("pattern", "", "remove mute rules of exactly the pattern, alone it removes only rules of the pattern without destination")
+	destinationName := flagSet.String("destination", "", "remove mute rules of exactly the destination, alone it removes only rules of the destination without pattern")
+	flagSet.Parse(args)
+	// rules are chosen by one of id, all and the pair of pattern and destination
+	choiceCount := 0
+	for _, isChosen := range []bool{*id != 0, *isAll, *pattern != "" || *destinationName != ""} {
+		if isChosen {
+			choiceCount += 1
+		}
+	}
+	if flagSet.NArg() != 0 || choiceCount > 1 {
+		flagSet.Usage()
+		return 2
+	}
+
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+
+	muteState, err := controlClient.Unmute(alarm.UnmuteArgs{
+		Id:          *id,
+		All:         *isAll,
+		Pattern:     *pattern,
+		Destination: *destinationName,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to unmute: %v\n", err)
+		return 1
+	}
+	printMuteRuleList(muteState.MuteRuleList)
+	return 0
+}
+
+// printMuteRuleList prints active mute rules, "*" is every pattern or every destination
+func printMuteRuleList(muteRuleList []monitor.MuteRule) {
+	if len(muteRuleList) == 0 {
+		fmt.Println("alarms are not muted")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPATTERN\tDESTINATION\tUNTIL")
+	for _, muteRule := range muteRuleList {
+		pattern, destinationName, until := muteRule.Pattern, muteRule.Destination, "-"
+		if pattern == "" {
+			pattern = "*"
+		}
+		if destinationName == "" {
+			destinationName = "*"
+		}
+		if !muteRule.ExpiresAt.IsZero() {
+			until = muteRule.ExpiresAt.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", muteRule.Id, pattern, destinationName, until)
+	}
+	w.Flush()
+}
+
+func findString(str string, strList []string) bool {
+	for _, _str := range strList {
+		if str == _str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+func runDaemon(o *options, args []string) int {
+	flagSet := newFlagSet(o, "daemon", "daemon [--history path] [--mute-rules path] [--held-alarms path]")
+	historyPath := flagSet.String("history", "", "path of run history, $XDG_STATE_HOME/alarm-for-programmer/history.jsonl by default")
+	muteRulePath := flagSet.String("mute-rules", "", "path of mute rules, $XDG_STATE_HOME/alarm-for-programmer/mute.json by default")
+	heldAlarmPath := flagSet.String("held-alarms", "", "path of alarms held outside of delivery windows, $XDG_STATE_HOME/alarm-for-programmer/held.json by default")
+	flagSet.Parse(args)
+
+	// alarmer keeps last valid config, so it can not start without a valid one
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	if *historyPath == "" {
+		*historyPath = monitor.RunHistoryPath()
+	}
+	if *muteRulePath == "" {
+		*muteRulePath = monitor.MuteRulePath()
+	}
+	if *heldAlarmPath == "" {
+		*heldAlarmPath = alarm.HeldAlarmPath()
+	}
+	configMonitor := monitor.NewLayeredConfigMonitor(o.getConfigLayers())
+	// stores are set before the alarmer is started and requests are served, so no run or request misses them
+	alarmer := alarm.NewUnstartedAlarmerWithConfigMonitor(configMonitor)
+	// the daemon has patterns in its arguments, and its children like shell hooks could have them too
+	alarmer.SetDaemonPid(os.Getpid())
+	// another daemon would alarm every process again, and write run history too
+	controlServer := &alarm.ControlServer{}
+	controlServer.Init(alarmer, o.getSocketPath())
+	if err := controlServer.Listen(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to listen on control socket: %v\n", err)
+		configMonitor.Stop()
+		return 1
+	}
+
+	// alarms are sent without history rather than not at all
+	runHistoryStore, err := monitor.OpenRunHistoryStore(*historyPath, config.HistoryRetention, config.HistoryMaxRunCount)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open run history, runs are not recorded: %v\n", err)
+	} else {
+		defer runHistoryStore.Close()
+		alarmer.SetRunHistoryStore(runHistoryStore)
+		fmt.Printf("run history is %s\n", *historyPath)
+	}
+
+	// alarms muted before restart stay muted
+	muteRuleStore, err := monitor.OpenMuteRuleStore(*muteRulePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open mute rules, they are kept only until restart: %v\n", err)
+	} else {
+		alarmer.SetMuteRuleStore(muteRuleStore)
+		fmt.Printf("mute rules are %s\n", *muteRulePath)
+	}
+
+	// digest of alarms held before restart is sent when the delivery window opens
+	if err := alarmer.SetHeldAlarmPath(*heldAlarmPath); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read held alarms, they are lost: %v\n", err)
+	} else {
+		fmt.Printf("held alarms are %s\n", *heldAlarmPath)
+	}
+
+	alarmer.Start()
+	if err := controlServer.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to listen on control socket: %v\n", err)
+		alarmer.Stop()
+		configMonitor.Stop()
+		return 1
+	}
+	fmt.Printf("alarmer is started with config %s\n", configMonitor.GetConfigLayers().BaseConfigPath)
+	fmt.Printf("control socket is %s\n", controlServer.GetSocketPath())
+
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
+	sig := <-signalChannel
+
+	fmt.Printf("alarmer is stopped by %v\n", sig)
+	controlServer.Stop()
+	alarmer.Stop()
+	configMonitor.Stop()
+	return 0
+}
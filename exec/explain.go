@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func explainProcess(o *options, args []string) int {
+	flagSet := newFlagSet(o, "explain", "explain --pid pid")
+	pid := flagSet.Int("pid", 0, "pid of the running process")
+	flagSet.Parse(args)
+	if *pid <= 0 || flagSet.NArg() != 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	// only the daemon knows which processes are tracked already
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+
+	processExplanation, err := controlClient.ExplainProcess(*pid)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to explain %d: %v\n", *pid, err)
+		return 1
+	}
+
+	fmt.Printf("pid: %d\n", processExplanation.Pid)
+	fmt.Printf("command: %s\n", shortenCommand(processExplanation.Cmd))
+	fmt.Printf("directory: %s\n", processExplanation.Directory)
+	if processExplanation.IgnoredReason != "" {
+		fmt.Printf("ignored: %s\n", processExplanation.IgnoredReason)
+	}
+	fmt.Println()
+
+	if len(processExplanation.PatternExplanationList) == 0 {
+		fmt.Println("no pattern is tracked")
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATTERN\tTRACKED\tREASON")
+	for _, patternExplanation := range processExplanation.PatternExplanationList {
+		isTracked := "no"
+		if patternExplanation.IsTracked {
+			isTracked = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", patternExplanation.MonitoringCommand, isTracked, patternExplanation.Reason)
+	}
+	w.Flush()
+	return 0
+}
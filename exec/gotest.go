@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+const goTestUsage = "go [--destination name] test [build/test flags] [packages]"
+
+// runGoTest runs go test with -json and alarms result of each package,
+// output of tests is printed like go test without -json
+func runGoTest(o *options, args []string) int {
+	flagSet := newFlagSet(o, "go", goTestUsage)
+	destinationName := flagSet.String("destination", "", "name of destination in config, alarmConfig is used by default")
+	flagSet.Parse(args)
+	if flagSet.NArg() == 0 || flagSet.Arg(0) != "test" {
+		flagSet.Usage()
+		return 2
+	}
+
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	destination, err := alarm.FindDestination(config, *destinationName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	goTestArgs := flagSet.Args()
+	if !findString("-json", goTestArgs) {
+		goTestArgs = append([]string{"test", "-json"}, goTestArgs[1:]...)
+	}
+	c := exec.Command("go", goTestArgs...)
+	c.Stdin = os.Stdin
+	c.Stderr = os.Stderr
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run go test: %v\n", err)
+		return 1
+	}
+
+	modulePath, _ := monitor.FindGolangModulePath(".")
+	report := monitor.NewGolangTestReport(modulePath)
+	startedAt := time.Now()
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run go test: %v\n", err)
+		return exitCodeOfStartError(err)
+	}
+
+	// go test receives signals of terminal by itself like run
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signalChannel)
+	go func() {
+		for sig := range signalChannel {
+			if sig != os.Interrupt {
+				c.Process.Signal(sig)
+			}
+		}
+	}()
+
+	readGoTestEvents(stdout, report)
+	c.Wait()
+	duration := time.Since(startedAt)
+	exitCode := exitCodeOf(c.ProcessState)
+
+	packageNameByImportPath, _ := monitor.ListGolangPackageNames(".", report.GetImportPathList())
+	for importPath, name := range packageNameByImportPath {
+		report.SetPackageName(importPath, name)
+	}
+
+	msg := fmt.Sprintf(
+		"Command=go %s | PID=%d | STATUS=%s | EXIT=%d | DURATION=%s",
+		strings.Join(flagSet.Args(), " "), c.Process.Pid, monitor.ProcessFinished, exitCode, duration.Round(time.Millisecond),
+	) + alarm.FormatGolangTestReport(report)
+	sendMessage(config, destination, msg)
+	return exitCode
+}
+
+// readGoTestEvents prints output of events and adds them to report,
+// lines which are not events, e.g. output of go vet, are printed as they are
+func readGoTestEvents(r io.Reader, report *monitor.GolangTestReport) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		event := monitor.GolangTestEvent{}
+		if err := json.Unmarshal(line, &event); err != nil || event.Action == "" {
+			fmt.Println(string(line))
+			continue
+		}
+		if event.Action == "output" || event.Action == "build-output" {
+			fmt.Print(event.Output)
+		}
+		report.AddEvent(event)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+)
+
+const (
+	tableHistoryFormat = "table"
+	jsonlHistoryFormat = "jsonl"
+	csvHistoryFormat   = "csv"
+)
+
+var csvHistoryHeader = []string{
+	"finishedAt", "startedAt", "durationSeconds", "status", "exitCode",
+	"monitoringCommand", "pid", "directory", "command", "processCount", "cpuSeconds", "peakMemory",
+}
+
+// historyLine is a line of jsonl output, status and duration are added to the recorded run
+type historyLine struct {
+	monitor.Run
+	Status          string  `json:"status"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+func printHistory(o *options, args []string) int {
+	flagSet := newFlagSet(o, "history", "history [--pattern pattern] [--since time] [--until time] [--status status] [--cwd dir] [--min-duration duration] [--limit n] [--format table|jsonl|csv]")
+	historyPath := flagSet.String("file", "", "path of run history, $XDG_STATE_HOME/alarm-for-programmer/history.jsonl by default")
+	pattern := flagSet.String("pattern", "", "monitoringCommand, or a part of command")
+	since := flagSet.String("since", "", "runs finished at or after the time, e.g. 2024-05-01, 2024-05-01T09:00:00+09:00, 36h or 7d ago")
+	until := flagSet.String("until", "", "runs finished before the time, in the same form as --since")
+	status := flagSet.String("status", "", "succeeded, failed or unknown")
+	directory := flagSet.String("cwd", "", "runs in the directory or its subdirectories")
+	minimumDuration := flagSet.Duration("min-duration", 0, "runs which took at least the duration")
+	limit := flagSet.Int("limit", 0, "print only the last n runs")
+	format := flagSet.String("format", tableHistoryFormat, "table, jsonl or csv")
+	flagSet.Parse(args)
+
+	if *historyPath == "" {
+		*historyPath = monitor.RunHistoryPath()
+	}
+	now := time.Now()
+	runQuery := monitor.RunQuery{
+		Pattern:         *pattern,
+		Status:          *status,
+		MinimumDuration: *minimumDuration,
+	}
+	var err error
+	if *since != "" {
+		if runQuery.Since, err = monitor.ParseQueryTime(*since, now); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --since: %v\n", err)
+			return 2
+		}
+	}
+	if *until != "" {
+		if runQuery.Until, err = monitor.ParseQueryTime(*until, now); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --until: %v\n", err)
+			return 2
+		}
+	}
+	switch *status {
+	case "", monitor.RunSucceeded, monitor.RunFailed, monitor.RunUnknown:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --status %q, it is one of succeeded, failed and unknown\n", *status)
+		return 2
+	}
+	switch *format {
+	case tableHistoryFormat, jsonlHistoryFormat, csvHistoryFormat:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --format %q, it is one of table, jsonl and csv\n", *format)
+		return 2
+	}
+	if *directory != "" {
+		// directories of runs are absolute
+		if runQuery.Directory, err = filepath.Abs(*directory); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --cwd: %v\n", err)
+			return 2
+		}
+	}
+
+	runList, err := monitor.ReadRunHistory(*historyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read run history %s: %v\n", *historyPath, err)
+		return 1
+	}
+	if *status == monitor.RunFailed || *status == monitor.RunSucceeded {
+		// runs whose exit code was not observed can be either of them
+		unknownRunQuery := runQuery
+		unknownRunQuery.Status = monitor.RunUnknown
+		if unknownRunCount := len(unknownRunQuery.FindRunList(runList)); unknownRunCount > 0 {
+			fmt.Fprintf(os.Stderr, "%d runs are not filtered by --status %s because their exit codes are unknown, see --status unknown\n", unknownRunCount, *status)
+		}
+	}
+	runList = runQuery.FindRunList(runList)
+	if *limit > 0 && len(runList) > *limit {
+		runList = runList[len(runList)-*limit:]
+	}
+
+	switch *format {
+	case tableHistoryFormat:
+		printHistoryTable(runList)
+	case jsonlHistoryFormat:
+		encoder := json.NewEncoder(os.Stdout)
+		for _, run := range runList {
+			encoder.Encode(historyLine{
+				Run:             run,
+				Status:          run.Status(),
+				DurationSeconds: run.Duration().Seconds(),
+			})
+		}
+	case csvHistoryFormat:
+		w := csv.NewWriter(os.Stdout)
+		w.Write(csvHistoryHeader)
+		for _, run := range runList {
+			w.Write(csvRecordOfRun(run))
+		}
+		w.Flush()
+	}
+	return 0
+}
+
+// printHistoryTable prints runs and how many of them failed, so the question "how often does it fail" is answered at a glance
+func printHistoryTable(runList []monitor.Run) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FINISHED\tDURATION\tSTATUS\tEXIT\tPATTERN\tDIRECTORY\tCOMMAND")
+	countByStatus := map[string]int{}
+	totalDuration := time.Duration(0)
+	for _, run := range runList {
+		countByStatus[run.Status()] += 1
+		totalDuration += run.Duration()
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			run.FinishedAt.Local().Format("2006-01-02 15:04:05"), run.Duration().Round(time.Second),
+			run.Status(), exitCodeOfRun(run), run.MonitoringCommand, run.Directory, shortenCommand(run.Command),
+		)
+	}
+	w.Flush()
+	if len(runList) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Printf(
+		"%d runs: %d succeeded, %d failed, %d unknown, total %s, average %s\n",
+		len(runList), countByStatus[monitor.RunSucceeded], countByStatus[monitor.RunFailed], countByStatus[monitor.RunUnknown],
+		totalDuration.Round(time.Second), (totalDuration / time.Duration(len(runList))).Round(time.Second),
+	)
+}
+
+func exitCodeOfRun(run monitor.Run) string {
+	if run.ExitCode == nil {
+		return "-"
+	}
+	return strconv.Itoa(*run.ExitCode)
+}
+
+func csvRecordOfRun(run monitor.Run) []string {
+	exitCode := ""
+	if run.ExitCode != nil {
+		exitCode = strconv.Itoa(*run.ExitCode)
+	}
+	return []string{
+		run.FinishedAt.Format(time.RFC3339),
+		run.StartedAt.Format(time.RFC3339),
+		strconv.FormatFloat(run.Duration().Seconds(), 'f', 3, 64),
+		run.Status(),
+		exitCode,
+		run.MonitoringCommand,
+		strconv.Itoa(run.Pid),
+		run.Directory,
+		run.Command,
+		strconv.Itoa(run.ProcessCount),
+		strconv.FormatFloat(run.CpuTime.Seconds(), 'f', 3, 64),
+		strconv.FormatUint(run.PeakMemory, 10),
+	}
+}
@@ -1,20 +1,183 @@
 package main
 
 import (
-	"path"
-	"runtime"
-	"time"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
 
+	monitor "github.com/goodahn/alarm-for-programmer"
 	alarm "github.com/goodahn/alarm-for-programmer/alarm"
 )
 
+const usage = `usage: alarm [--config path] [--set path=value]... [--socket path] command [args]
+
+commands:
+  daemon                           monitor processes of monitoringCommandList and alarm when they finish
+  run [--destination name] -- command [arg]...
+                                   run command and alarm when it finishes, exit status of command is returned
+  watch --pid pid | --pidfile path alarm when the running process finishes
+  go [--destination name] test [build/test flags] [packages]
+                                   run go test and alarm passed, failed and skipped tests of each package
+  status                           print monitored patterns and running processes matched by them
+  explain --pid pid                print why each pattern of running daemon tracks the process or not
+  pattern list | add pattern | remove pattern
+                                   change patterns of running daemon until it is restarted
+  mute [--pattern pattern] [--destination name] [--for duration | --until time]
+                                   stop sending alarms of running daemon, every alarm by default
+  mute list                        print mute rules of running daemon
+  unmute [--id id | --all | [--pattern pattern] [--destination name]]
+                                   remove mute rules, global ones by default,
+                                   --pattern and --destination remove rules of exactly both of them
+  history [--pattern pattern] [--since time] [--until time] [--status status] [--cwd dir]
+          [--min-duration duration] [--limit n] [--format table|jsonl|csv]
+                                   print finished runs recorded by daemon
+  shell-init bash|zsh              print hooks which report interactive commands to running daemon,
+                                   eval "$(alarm shell-init bash)" in ~/.bashrc
+  notify [--destination name] message
+                                   send message
+  test-notify [--destination name] [--daemon]
+                                   send test message to every destination, or only to the given one
+  config validate                  check effective config and report every problem
+  config show [--effective]        print effective config, --effective prints source of each value
+  config init [--force]            write config file with default values
+  config convert src dst           translate config file into the format of dst, e.g. config.json config.yaml
+  version                          print version
+
+config is assembled from these layers, later one wins
+  1. config file given by --config,
+     $XDG_CONFIG_HOME/alarm-for-programmer/config.{json,yaml,yml,toml} by default
+  2. conf.d/* next to the config file, in alphabetical order
+  3. ALARM_* environment variables, e.g. ALARM_MONITORING_PERIOD=5s
+  4. --set flags, e.g. --set monitoringPeriod=5s
+
+running daemon is controlled by JSON-RPC on its socket in $XDG_RUNTIME_DIR/alarm-for-programmer
+
+--config, --set and --socket can be given after the command too`
+
+type flagList []string
+
+func (l *flagList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *flagList) Set(val string) error {
+	*l = append(*l, val)
+	return nil
+}
+
+// options are flags shared by every command
+type options struct {
+	configPath  string
+	setFlagList flagList
+	socketPath  string
+}
+
+func (o *options) register(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&o.configPath, "config", o.configPath, "path of config file, found in $XDG_CONFIG_HOME/alarm-for-programmer by default")
+	flagSet.Var(&o.setFlagList, "set", "override config value, e.g. monitoringPeriod=5s")
+	flagSet.StringVar(&o.socketPath, "socket", o.socketPath, "path of control socket of daemon, $XDG_RUNTIME_DIR/alarm-for-programmer/control.sock by default")
+}
+
+func (o *options) getSocketPath() string {
+	if o.socketPath == "" {
+		return alarm.ControlSocketPath()
+	}
+	return o.socketPath
+}
+
+func (o *options) getConfigPath() string {
+	if o.configPath == "" {
+		return monitor.FindConfigPath()
+	}
+	return o.configPath
+}
+
+func (o *options) getConfigLayers() monitor.ConfigLayers {
+	return monitor.ConfigLayers{
+		BaseConfigPath: o.getConfigPath(),
+		Environ:        os.Environ(),
+		FlagList:       o.setFlagList,
+	}
+}
+
+// readConfig reads effective config for commands which don't monitor config
+func (o *options) readConfig() (monitor.Config, bool) {
+	configLayers := o.getConfigLayers()
+	config, err := configLayers.ReadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config of %s is invalid:\n%v\n", configLayers.BaseConfigPath, err)
+		if _, err := os.Stat(configLayers.BaseConfigPath); os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "config file can be created by \"alarm config init\"")
+		}
+		return monitor.Config{}, false
+	}
+	return config, true
+}
+
+// newFlagSet creates flags of a command, which accepts shared options too
+func newFlagSet(o *options, name string, commandUsage string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: alarm %s\n\n", commandUsage)
+		flagSet.PrintDefaults()
+	}
+	o.register(flagSet)
+	return flagSet
+}
+
 func main() {
-	_, currentFilePath, _, _ := runtime.Caller(0)
-	dirpath := path.Dir(currentFilePath)
+	o := &options{}
+	flagSet := flag.NewFlagSet("alarm", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+	}
+	o.register(flagSet)
+	flagSet.Parse(os.Args[1:])
 
-	alarmer := alarm.NewAlarmer(dirpath + "/config.json")
-	alarmer.Start()
-	for {
-		time.Sleep(3 * time.Second)
+	args := flagSet.Args()
+	if len(args) == 0 {
+		flagSet.Usage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "daemon":
+		os.Exit(runDaemon(o, args[1:]))
+	case "run":
+		os.Exit(runCommand(o, args[1:]))
+	case "watch":
+		os.Exit(watchProcess(o, args[1:]))
+	case "go":
+		os.Exit(runGoTest(o, args[1:]))
+	case "status":
+		os.Exit(printStatus(o, args[1:]))
+	case "explain":
+		os.Exit(explainProcess(o, args[1:]))
+	case "shell-init":
+		os.Exit(printShellInit(o, args[1:]))
+	case "shell-report":
+		os.Exit(reportShellCommand(o, args[1:]))
+	case "history":
+		os.Exit(printHistory(o, args[1:]))
+	case "pattern":
+		os.Exit(runPatternCommand(o, args[1:]))
+	case "mute":
+		os.Exit(mute(o, args[1:]))
+	case "unmute":
+		os.Exit(unmute(o, args[1:]))
+	case "notify":
+		os.Exit(notify(o, args[1:]))
+	case "test-notify":
+		os.Exit(testNotify(o, args[1:]))
+	case "config":
+		os.Exit(runConfigCommand(o, args[1:]))
+	case "version":
+		os.Exit(printVersion(o, args[1:]))
+	case "help":
+		fmt.Println(usage)
+		os.Exit(0)
 	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+	flagSet.Usage()
+	os.Exit(2)
 }
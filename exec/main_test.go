@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// alarmBinaryPath is alarm built for tests, commands are run as processes because flags exit on error
+var alarmBinaryPath string
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "alarm-exec")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	alarmBinaryPath = filepath.Join(dir, "alarm")
+	output, err := exec.Command("go", "build", "-o", alarmBinaryPath, ".").CombinedOutput()
+	if err != nil {
+		fmt.Println(string(output))
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	exitCode := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(exitCode)
+}
+
+func TestCommand(t *testing.T) {
+	t.Run("RunExitCode", CheckRunExitCode())
+	t.Run("FlagParsing", CheckFlagParsing())
+	t.Run("BashDebugTrap", CheckBashDebugTrap())
+}
+
+func CheckRunExitCode() func(*testing.T) {
+	return func(t *testing.T) {
+		webHook := newTestWebHook()
+		defer webHook.Close()
+		dir := prepareTestConfig(t, webHook.URL)
+		defer os.RemoveAll(dir)
+
+		notExecutablePath := filepath.Join(dir, "not-executable.sh")
+		require.NoError(t, ioutil.WriteFile(notExecutablePath, []byte("exit 0\n"), 0644))
+		notBinaryPath := filepath.Join(dir, "not-binary")
+		require.NoError(t, ioutil.WriteFile(notBinaryPath, []byte("not a binary\n"), 0755))
+
+		for _, testCase := range []struct {
+			commandLine      []string
+			expectedExitCode int
+		}{
+			{[]string{"true"}, 0},
+			{[]string{"sh", "-c", "exit 3"}, 3},
+			{[]string{"sh", "-c", "kill -TERM $$"}, 143},
+			{[]string{"alarm-there-is-no-command-like-this"}, 127},
+			{[]string{filepath.Join(dir, "there-is-no-file-like-this")}, 127},
+			{[]string{notExecutablePath}, 126},
+			{[]string{notBinaryPath}, 126},
+			{[]string{dir}, 126},
+		} {
+			args := append([]string{"--config", filepath.Join(dir, "config.json"), "run", "--"}, testCase.commandLine...)
+			exitCode, _ := runAlarm(t, args...)
+			require.Equal(t, testCase.expectedExitCode, exitCode, testCase.commandLine)
+		}
+
+		msgList := webHook.GetMessageList()
+		require.Equal(t, 3, len(msgList))
+		require.Contains(t, msgList[1], "Command=sh -c exit 3 |")
+		require.Contains(t, msgList[1], "| EXIT=3 |")
+	}
+}
+
+func CheckFlagParsing() func(*testing.T) {
+	return func(t *testing.T) {
+		webHook := newTestWebHook()
+		defer webHook.Close()
+		dir := prepareTestConfig(t, webHook.URL)
+		defer os.RemoveAll(dir)
+		configPath := filepath.Join(dir, "config.json")
+		historyPath := filepath.Join(dir, "history.jsonl")
+		history := `{"type": "finish", "run": {"monitoringCommand": "make", "pid": 100, "command": "make", "startedAt": "2024-05-01T09:00:00Z", "finishedAt": "2024-05-01T09:01:00Z", "exitCode": 2}}
+{"type": "finish", "run": {"monitoringCommand": "make", "pid": 200, "command": "make", "startedAt": "2024-05-01T10:00:00Z", "finishedAt": "2024-05-01T10:01:00Z"}}
+`
+		require.NoError(t, ioutil.WriteFile(historyPath, []byte(history), 0644))
+
+		for _, testCase := range []struct {
+			args             []string
+			expectedExitCode int
+			expectedStderr   string
+		}{
+			{[]string{}, 2, "usage: alarm"},
+			{[]string{"there-is-no-command-like-this"}, 2, "unknown command"},
+			{[]string{"--config", configPath, "run"}, 2, "usage: alarm run"},
+			{[]string{"--config", configPath, "run", "--there-is-no-flag-like-this", "true"}, 2, "flag provided but not defined"},
+			{[]string{"--config", configPath, "run", "--destination", "nowhere", "--", "true"}, 1, "nowhere"},
+			{[]string{"--config", configPath, "go", "build"}, 2, "usage: alarm go"},
+			{[]string{"--config", configPath, "watch"}, 2, "usage: alarm watch"},
+			{[]string{"--config", configPath, "notify"}, 2, "usage: alarm notify"},
+			{[]string{"--config", configPath, "unmute", "--id", "2", "--pattern", "make"}, 2, "usage: alarm unmute"},
+			{[]string{"--config", configPath, "unmute", "--all", "--destination", "team"}, 2, "usage: alarm unmute"},
+			// shared options can be given after the command too
+			{[]string{"run", "--config", configPath, "--set", "alarmConfig.requestTimeout=3s", "--", "true"}, 0, ""},
+			{[]string{"--config", configPath, "--set", "monitoringPeriod=later", "run", "--", "true"}, 1, "monitoringPeriod"},
+			{[]string{"--config", filepath.Join(dir, "there-is-no-file-like-this.json"), "run", "--", "true"}, 1, "alarm config init"},
+			{[]string{"history", "--file", historyPath, "--status", "bad"}, 2, "invalid --status"},
+			// runs of unknown status can't be filtered by --status failed
+			{[]string{"history", "--file", historyPath, "--status", "failed"}, 0, "1 runs are not filtered by --status failed"},
+			{[]string{"history", "--file", historyPath, "--status", "unknown"}, 0, ""},
+		} {
+			exitCode, stderr := runAlarm(t, testCase.args...)
+			require.Equal(t, testCase.expectedExitCode, exitCode, testCase.args)
+			require.Contains(t, stderr, testCase.expectedStderr, testCase.args)
+		}
+	}
+}
+
+// DEBUG trap installed before the bash hook keeps running, and it is chained once even if hooks are evaluated twice
+func CheckBashDebugTrap() func(*testing.T) {
+	return func(t *testing.T) {
+		if _, err := exec.LookPath("bash"); err != nil {
+			t.Skip("bash is not installed")
+		}
+		script := fmt.Sprintf(`trap 'echo "previous trap" >&2' DEBUG
+eval "$(%[1]q shell-init bash)"
+eval "$(%[1]q shell-init bash)"
+trap -p DEBUG`, alarmBinaryPath)
+		output, err := exec.Command("bash", "-c", script).Output()
+		require.NoError(t, err)
+		require.Equal(t, "trap -- 'echo \"previous trap\" >&2\n__alarm_preexec' DEBUG\n", string(output))
+	}
+}
+
+// runAlarm runs alarm without ALARM_* environment variables, which are layers of config
+func runAlarm(t *testing.T, args ...string) (int, string) {
+	c := exec.Command(alarmBinaryPath, args...)
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "ALARM_") {
+			c.Env = append(c.Env, variable)
+		}
+	}
+	stderr := bytes.Buffer{}
+	c.Stderr = &stderr
+	err := c.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		require.NoError(t, err)
+	}
+	return c.ProcessState.ExitCode(), stderr.String()
+}
+
+func prepareTestConfig(t *testing.T, webHookUrl string) string {
+	dir, err := ioutil.TempDir("", "alarm-exec-config")
+	require.NoError(t, err)
+	config := fmt.Sprintf(`{"alarmConfig": {"type": "slack-webhook", "webHookUrl": %q}}`, webHookUrl)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0644))
+	return dir
+}
+
+// testWebHook keeps bodies of requests sent to it
+type testWebHook struct {
+	*httptest.Server
+	msgList []string
+
+	mutexForMsgList sync.Mutex
+}
+
+func newTestWebHook() *testWebHook {
+	webHook := &testWebHook{}
+	webHook.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		webHook.mutexForMsgList.Lock()
+		defer webHook.mutexForMsgList.Unlock()
+		webHook.msgList = append(webHook.msgList, string(body))
+	}))
+	return webHook
+}
+
+func (twh *testWebHook) GetMessageList() []string {
+	twh.mutexForMsgList.Lock()
+	defer twh.mutexForMsgList.Unlock()
+	return append([]string{}, twh.msgList...)
+}
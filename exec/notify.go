@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+func notify(o *options, args []string) int {
+	flagSet := newFlagSet(o, "notify", "notify [--destination name] message")
+	destinationName := flagSet.String("destination", "", "name of destination in config, alarmConfig is used by default")
+	flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	destination, err := alarm.FindDestination(config, *destinationName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !sendMessage(config, destination, strings.Join(flagSet.Args(), " ")) {
+		return 1
+	}
+	return 0
+}
+
+func testNotify(o *options, args []string) int {
+	flagSet := newFlagSet(o, "test-notify", "test-notify [--destination name]")
+	destinationName := flagSet.String("destination", "", "name of destination in config, every destination is tested by default")
+	byDaemon := flagSet.Bool("daemon", false, "send by running daemon, which tests config loaded by it")
+	flagSet.Parse(args)
+	if *byDaemon {
+		return testNotifyByDaemon(o, *destinationName)
+	}
+
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	nameList := []string{*destinationName}
+	if *destinationName == "" {
+		nameList = []string{alarm.DefaultDestinationName}
+		for name := range config.Destinations {
+			nameList = append(nameList, name)
+		}
+		sort.Strings(nameList[1:])
+	}
+
+	hostname, _ := os.Hostname()
+	exitCode := 0
+	for _, name := range nameList {
+		destination, err := alarm.FindDestination(config, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			continue
+		}
+		msg := fmt.Sprintf("test notification of alarm-for-programmer | HOST=%s | DESTINATION=%s", hostname, name)
+		if !sendMessage(config, destination, msg) {
+			fmt.Printf("%s: failed\n", name)
+			exitCode = 1
+			continue
+		}
+		fmt.Printf("%s: ok\n", name)
+	}
+	return exitCode
+}
+
+func testNotifyByDaemon(o *options, destinationName string) int {
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+
+	if destinationName == "" {
+		destinationName = alarm.DefaultDestinationName
+	}
+	if err := controlClient.TestAlarm(destinationName); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to send message: %v\n", err)
+		fmt.Printf("%s: failed\n", destinationName)
+		return 1
+	}
+	fmt.Printf("%s: ok\n", destinationName)
+	return 0
+}
+
+// sendMessage sends msg and prints error of it
+func sendMessage(config monitor.Config, destination monitor.AlarmConfig, msg string) bool {
+	err := alarm.SendMessage(destination, msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to send message: %v\n", monitor.RedactSecrets(err.Error(), config.SecretList()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+// exit status of shells when command is found but can not be executed, e.g. permission denied, and when command is not found
+const (
+	commandNotExecutableExitCode = 126
+	commandNotFoundExitCode      = 127
+)
+
+func runCommand(o *options, args []string) int {
+	flagSet := newFlagSet(o, "run", "run [--destination name] -- command [arg]...")
+	destinationName := flagSet.String("destination", "", "name of destination in config, alarmConfig is used by default")
+	flagSet.Parse(args)
+	if flagSet.NArg() == 0 {
+		flagSet.Usage()
+		return 2
+	}
+
+	// config is checked before running command, otherwise nobody would be alarmed after a long run
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	destination, err := alarm.FindDestination(config, *destinationName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	commandLine := flagSet.Args()
+	c := exec.Command(commandLine[0], commandLine[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	startedAt := time.Now()
+	if err := c.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run %s: %v\n", commandLine[0], err)
+		return exitCodeOfStartError(err)
+	}
+
+	// command receives signals of terminal by itself,
+	// they are only kept from killing alarm before the command finishes
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signalChannel)
+	go func() {
+		for sig := range signalChannel {
+			if sig != os.Interrupt {
+				c.Process.Signal(sig)
+			}
+		}
+	}()
+
+	c.Wait()
+	duration := time.Since(startedAt)
+	exitCode := exitCodeOf(c.ProcessState)
+
+	msg := fmt.Sprintf(
+		"Command=%s | PID=%d | STATUS=%s | EXIT=%d | DURATION=%s",
+		strings.Join(commandLine, " "), c.Process.Pid, monitor.ProcessFinished, exitCode, duration.Round(time.Millisecond),
+	)
+	if directory, err := os.Getwd(); err == nil {
+		msg += alarm.FormatProject(monitor.NewProjectFinder().Find(directory))
+		msg += alarm.FormatGitContext(monitor.FindGitContext(directory))
+	}
+	sendMessage(config, destination, msg)
+	return exitCode
+}
+
+// exitCodeOfStartError returns exit status like shells do for command which can not be started
+func exitCodeOfStartError(err error) int {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) {
+		return commandNotFoundExitCode
+	}
+	return commandNotExecutableExitCode
+}
+
+// exitCodeOf returns exit status like shells do, 128+n for process killed by signal n
+func exitCodeOf(processState *os.ProcessState) int {
+	waitStatus, ok := processState.Sys().(syscall.WaitStatus)
+	if ok && waitStatus.Signaled() {
+		return 128 + int(waitStatus.Signal())
+	}
+	return processState.ExitCode()
+}
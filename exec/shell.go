@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+const shellReportUsage = `shell-report start|finish --shell-pid pid --started-at time [--finished-at time] [--exit-status status] [--directory dir] [--command command]`
+
+// bash has no preexec hook, so DEBUG trap is used like bash-preexec, and DEBUG trap of user is chained.
+// __alarm_at_prompt makes only the first command after prompt reported,
+// and commands of PROMPT_COMMAND are not reported
+const bashHooks = `# alarm-for-programmer shell integration, add this line to ~/.bashrc
+#   eval "$(alarm shell-init bash)"
+__alarm_report() {
+    (__ALARM__ shell-report "$@" >/dev/null 2>&1 &)
+}
+
+__alarm_now() {
+    if [ -n "${EPOCHREALTIME:-}" ]; then
+        __alarm_time=$EPOCHREALTIME
+    else
+        printf -v __alarm_time '%(%s)T' -1
+    fi
+}
+
+__alarm_preexec() {
+    [ -n "${__alarm_at_prompt:-}" ] || return 0
+    [ -z "${COMP_LINE:-}" ] || return 0
+    [ "$BASH_COMMAND" != __alarm_precmd ] || return 0
+    __alarm_at_prompt=
+    local command
+    command=$(HISTTIMEFORMAT= builtin history 1)
+    command=${command#"${command%%[![:space:]]*}"}
+    command=${command#*[[:space:]]}
+    command=${command#"${command%%[![:space:]]*}"}
+    [ -n "$command" ] || command=$BASH_COMMAND
+    __alarm_now
+    __alarm_started_at=$__alarm_time
+    __alarm_command=$command
+    __alarm_directory=$PWD
+    __alarm_report start --shell-pid $$ --started-at "$__alarm_started_at" --directory "$__alarm_directory" --command "$__alarm_command"
+}
+
+__alarm_precmd() {
+    local exit_status=$?
+    if [ -n "${__alarm_started_at:-}" ]; then
+        __alarm_now
+        __alarm_report finish --shell-pid $$ --started-at "$__alarm_started_at" --finished-at "$__alarm_time" \
+            --exit-status "$exit_status" --directory "$__alarm_directory" --command "$__alarm_command"
+        __alarm_started_at=
+    fi
+    return $exit_status
+}
+
+__alarm_ready() {
+    __alarm_at_prompt=1
+}
+
+if [[ "${PROMPT_COMMAND:-}" != *__alarm_precmd* ]]; then
+    # DEBUG trap installed already, e.g. by bash-preexec, is kept and runs before the hook
+    __alarm_debug_trap=$(trap -p DEBUG)
+    __alarm_debug_trap=${__alarm_debug_trap#"trap -- "}
+    __alarm_debug_trap=${__alarm_debug_trap%" DEBUG"}
+    eval "__alarm_debug_trap=${__alarm_debug_trap:-''}"
+    trap -- "${__alarm_debug_trap:+$__alarm_debug_trap
+}__alarm_preexec" DEBUG
+    unset __alarm_debug_trap
+    PROMPT_COMMAND="__alarm_precmd;${PROMPT_COMMAND:+$PROMPT_COMMAND;}__alarm_ready"
+fi
+`
+
+const zshHooks = `# alarm-for-programmer shell integration, add this line to ~/.zshrc
+#   eval "$(alarm shell-init zsh)"
+zmodload zsh/datetime
+autoload -Uz add-zsh-hook
+
+__alarm_report() {
+    __ALARM__ shell-report "$@" >/dev/null 2>&1 &!
+}
+
+__alarm_preexec() {
+    __alarm_started_at=$EPOCHREALTIME
+    __alarm_command=$1
+    __alarm_directory=$PWD
+    __alarm_report start --shell-pid $$ --started-at "$__alarm_started_at" --directory "$__alarm_directory" --command "$__alarm_command"
+}
+
+__alarm_precmd() {
+    local exit_status=$?
+    [[ -n "${__alarm_started_at:-}" ]] || return 0
+    __alarm_report finish --shell-pid $$ --started-at "$__alarm_started_at" --finished-at "$EPOCHREALTIME" \
+        --exit-status "$exit_status" --directory "$__alarm_directory" --command "$__alarm_command"
+    __alarm_started_at=
+}
+
+add-zsh-hook preexec __alarm_preexec
+add-zsh-hook precmd __alarm_precmd
+`
+
+func printShellInit(o *options, args []string) int {
+	flagSet := newFlagSet(o, "shell-init", "shell-init bash|zsh")
+	flagSet.Parse(args)
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return 2
+	}
+
+	hooks := ""
+	switch flagSet.Arg(0) {
+	case "bash":
+		hooks = bashHooks
+	case "zsh":
+		hooks = zshHooks
+	default:
+		fmt.Fprintf(os.Stderr, "unknown shell %q, bash and zsh are supported\n", flagSet.Arg(0))
+		return 2
+	}
+
+	// hooks run the same binary so that it works without PATH
+	executable, err := os.Executable()
+	if err != nil {
+		executable = os.Args[0]
+	}
+	command := quoteForShell(executable)
+	if o.socketPath != "" {
+		command += " --socket " + quoteForShell(o.socketPath)
+	}
+	fmt.Print(strings.Replace(hooks, "__ALARM__", command, -1))
+	return 0
+}
+
+func quoteForShell(str string) string {
+	return "'" + strings.Replace(str, "'", `'\''`, -1) + "'"
+}
+
+// reportShellCommand is called by hooks of shell-init in background, so it doesn't print anything on success
+func reportShellCommand(o *options, args []string) int {
+	if len(args) == 0 || (args[0] != "start" && args[0] != "finish") {
+		fmt.Fprintf(os.Stderr, "usage: alarm %s\n", shellReportUsage)
+		return 2
+	}
+	event := args[0]
+	flagSet := newFlagSet(o, "shell-report", shellReportUsage)
+	shellPid := flagSet.Int("shell-pid", 0, "pid of the shell")
+	startedAt := flagSet.String("started-at", "", "unix time when the command started, fraction is allowed")
+	finishedAt := flagSet.String("finished-at", "", "unix time when the command finished, now by default")
+	exitStatus := flagSet.Int("exit-status", 0, "exit status of the command")
+	directory := flagSet.String("directory", "", "working directory of the command")
+	command := flagSet.String("command", "", "command line")
+	flagSet.Parse(args[1:])
+
+	shellCommand := alarm.ShellCommand{
+		ShellPid:   *shellPid,
+		Command:    *command,
+		Directory:  *directory,
+		ExitStatus: *exitStatus,
+	}
+	var err error
+	shellCommand.StartedAt, err = parseUnixTime(*startedAt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --started-at: %v\n", err)
+		return 2
+	}
+	shellCommand.FinishedAt = time.Now()
+	if *finishedAt != "" {
+		shellCommand.FinishedAt, err = parseUnixTime(*finishedAt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --finished-at: %v\n", err)
+			return 2
+		}
+	}
+
+	controlClient, ok := dialDaemon(o)
+	if !ok {
+		return 1
+	}
+	defer controlClient.Close()
+	if event == "start" {
+		err = controlClient.StartShellCommand(shellCommand)
+	} else {
+		err = controlClient.FinishShellCommand(shellCommand)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to report shell command: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseUnixTime parses seconds like $EPOCHREALTIME, whose decimal separator follows locale
+func parseUnixTime(str string) (time.Time, error) {
+	seconds, err := strconv.ParseFloat(strings.Replace(str, ",", ".", 1), 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+// commands are shortened to a line of terminal
+const maxCommandLength = 80
+
+func printStatus(o *options, args []string) int {
+	flagSet := newFlagSet(o, "status", "status")
+	flagSet.Parse(args)
+
+	controlClient, err := alarm.DialControlServer(o.getSocketPath())
+	if err != nil {
+		fmt.Println("daemon: not running")
+		fmt.Println()
+		return printLocalStatus(o)
+	}
+	defer controlClient.Close()
+	return printDaemonStatus(controlClient)
+}
+
+// printDaemonStatus prints what running daemon tracks
+func printDaemonStatus(controlClient *alarm.ControlClient) int {
+	patternList, err := controlClient.ListPatterns()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get patterns of daemon: %v\n", err)
+		return 1
+	}
+	trackedProcessList, err := controlClient.ListProcesses()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get processes of daemon: %v\n", err)
+		return 1
+	}
+	alarmCountMap, err := controlClient.GetAlarmCounts()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get alarm counts of daemon: %v\n", err)
+		return 1
+	}
+	muteState, err := controlClient.GetMuteState()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to get mute state of daemon: %v\n", err)
+		return 1
+	}
+
+	fmt.Println("daemon: running")
+	if muteState.IsMuted {
+		fmt.Println("alarms: muted")
+	} else if len(muteState.MuteRuleList) != 0 {
+		fmt.Printf("alarms: partially muted by %d rules, see \"alarm mute list\"\n", len(muteState.MuteRuleList))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATTERN\tALARMS")
+	namePatternList := append([]string{}, patternList.Tracked...)
+	for namePattern := range alarmCountMap {
+		if !findString(namePattern, namePatternList) {
+			namePatternList = append(namePatternList, namePattern)
+		}
+	}
+	sort.Strings(namePatternList)
+	for _, namePattern := range namePatternList {
+		fmt.Fprintf(w, "%s\t%d\n", namePattern, alarmCountMap[namePattern])
+	}
+	w.Flush()
+	fmt.Println()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATTERN\tPID\tRUNNING\tETA\tCOMMAND")
+	for _, trackedProcess := range trackedProcessList {
+		fmt.Fprintf(
+			w, "%s\t%d\t%s\t%s\t%s\n",
+			trackedProcess.MonitoringCommand, trackedProcess.Pid,
+			time.Since(trackedProcess.StartedAt).Round(time.Second), formatExpectedFinish(trackedProcess),
+			shortenCommand(commandOf(trackedProcess)),
+		)
+	}
+	w.Flush()
+	return 0
+}
+
+// printLocalStatus prints processes matched by patterns of config when daemon is not running
+func printLocalStatus(o *options) int {
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	processInfoList, err := monitor.GetProcessInfoList()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read processes: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("config: %s\n", o.getConfigPath())
+	fmt.Printf("monitoring period: %s\n", config.MonitoringPeriod)
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PATTERN\tPID\tCOMMAND")
+	for _, namePattern := range config.MonitoringCommandList {
+		found := false
+		for _, processInfo := range processInfoList {
+			if !strings.Contains(processInfo.Cmd(), namePattern) || processInfo.Pid() == os.Getpid() {
+				continue
+			}
+			if _, isIgnored := monitor.FindIgnoredReasonOfProcess(processInfo, processInfoList, namePattern, config.IgnoreCommandList); isIgnored {
+				continue
+			}
+			found = true
+			fmt.Fprintf(w, "%s\t%d\t%s\n", namePattern, processInfo.Pid(), shortenCommand(processInfo.Cmd()))
+		}
+		if !found {
+			fmt.Fprintf(w, "%s\t-\t\n", namePattern)
+		}
+	}
+	w.Flush()
+	return 0
+}
+
+// formatExpectedFinish is the time when the process is expected to finish from durations of its former runs
+func formatExpectedFinish(trackedProcess alarm.TrackedProcess) string {
+	if trackedProcess.ExpectedDuration == 0 {
+		return "-"
+	}
+	return alarm.DescribeExpectedFinish(trackedProcess.StartedAt, trackedProcess.ExpectedDuration, time.Now())
+}
+
+// commandOf is the job key of the process, daemons of former versions don't report it
+func commandOf(trackedProcess alarm.TrackedProcess) string {
+	if trackedProcess.JobKey == "" {
+		return trackedProcess.Cmd
+	}
+	return trackedProcess.JobKey
+}
+
+func shortenCommand(cmd string) string {
+	cmd = strings.Join(strings.Fields(cmd), " ")
+	if len(cmd) <= maxCommandLength {
+		return cmd
+	}
+	return cmd[:maxCommandLength-3] + "..."
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version is set on release builds, e.g. go build -ldflags "-X main.version=v1.2.3"
+var version = ""
+
+func printVersion(o *options, args []string) int {
+	flagSet := newFlagSet(o, "version", "version")
+	flagSet.Parse(args)
+
+	fmt.Printf("alarm-for-programmer %s %s %s/%s\n", getVersion(), runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return 0
+}
+
+// getVersion falls back to module version, which is known when it is installed by "go install"
+func getVersion() string {
+	if version != "" {
+		return version
+	}
+	buildInfo, ok := debug.ReadBuildInfo()
+	if ok && buildInfo.Main.Version != "" {
+		return buildInfo.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	monitor "github.com/goodahn/alarm-for-programmer"
+	alarm "github.com/goodahn/alarm-for-programmer/alarm"
+)
+
+func watchProcess(o *options, args []string) int {
+	flagSet := newFlagSet(o, "watch", "watch --pid pid | --pidfile path")
+	pid := flagSet.Int("pid", 0, "pid of the running process")
+	pidFilePath := flagSet.String("pidfile", "", "file which contains pid of the running process")
+	destinationName := flagSet.String("destination", "", "name of destination in config, alarmConfig is used by default")
+	flagSet.Parse(args)
+	if (*pid <= 0) == (*pidFilePath == "") || flagSet.NArg() != 0 {
+		flagSet.Usage()
+		return 2
+	}
+	if *pidFilePath != "" {
+		var err error
+		*pid, err = readPidFile(*pidFilePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	config, ok := o.readConfig()
+	if !ok {
+		return 1
+	}
+	destination, err := alarm.FindDestination(config, *destinationName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	processInfoMonitor := monitor.NewProcessInfoMonitor([]string{})
+	defer processInfoMonitor.Stop()
+	processInfoMonitor.SetPeriod(config.MonitoringPeriod)
+	monitoringCommand, err := processInfoMonitor.WatchPid(*pid)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	processStatusHistory := processInfoMonitor.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[*pid]
+	startedProcessStatus := processStatusHistory[0]
+	processInfo := startedProcessStatus.ProcessInfo()
+	fmt.Printf("watching %d: %s\n", *pid, processInfo.Cmd())
+	for {
+		processStatusHistory = processInfoMonitor.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[*pid]
+		if processStatusHistory[len(processStatusHistory)-1].Status() == monitor.ProcessFinished {
+			break
+		}
+		time.Sleep(config.MonitoringPeriod)
+	}
+	finishedProcessStatus := processStatusHistory[len(processStatusHistory)-1]
+
+	msg := fmt.Sprintf(
+		"Command=%s | PID=%d | STATUS=%s | DURATION=%s",
+		processInfo.Cmd(), *pid, finishedProcessStatus.Status(),
+		finishedProcessStatus.TimeStamp().Sub(startedProcessStatus.TimeStamp()).Round(time.Second),
+	) + alarm.FormatProject(monitor.NewProjectFinder().Find(processInfo.BinaryLocation()))
+	msg += alarm.FormatGitContext(monitor.FindGitContext(processInfo.BinaryLocation()))
+	msg += alarm.FormatProcessTreeSummary(finishedProcessStatus.ProcessTreeSummary())
+	if !sendMessage(config, destination, msg) {
+		return 1
+	}
+	return 0
+}
+
+func readPidFile(pidFilePath string) (int, error) {
+	data, err := ioutil.ReadFile(pidFilePath)
+	if err != nil {
+		return 0, err
+	}
+	// some daemons write more than pid, e.g. pid and port in separate lines
+	fieldList := strings.Fields(string(data))
+	if len(fieldList) == 0 {
+		return 0, fmt.Errorf("pidfile %s is empty", pidFilePath)
+	}
+	pid, err := strconv.Atoi(fieldList[0])
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("pidfile %s doesn't start with pid", pidFilePath)
+	}
+	return pid, nil
+}
@@ -0,0 +1,462 @@
+package alarm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	shortCommitLength = 7
+	// maxSymbolicRefDepth is the same limit as git, symbolic refs deeper than it are broken
+	maxSymbolicRefDepth = 5
+	// dirty state of big repositories is not checked, because alarm waits for it
+	maxDirtyCheckEntryCount = 50000
+	dirtyCheckTimeout       = 300 * time.Millisecond
+)
+
+// errDirtyCheckSkipped is returned when index has too many entries or files are not compared in time
+var errDirtyCheckSkipped = errors.New("dirty state is not checked")
+
+// GitContext is the state of git repository which contains working directory of a process.
+// it is read from ".git" directly, without running git
+type GitContext struct {
+	// Repository is the name of repository, which is the name of main working tree for linked worktrees
+	Repository string
+	// Branch is empty when HEAD is detached
+	Branch string
+	// Commit is the full hash of HEAD, it is empty before the first commit
+	Commit string
+	// IsDirty tells whether tracked files are modified or deleted in working tree like "git diff --quiet",
+	// changes which are staged already are not found because the commit is not read from objects
+	IsDirty bool
+	// IsDirtyUnknown tells that dirty state is not checked, because the repository is too big or index can not be read
+	IsDirtyUnknown bool
+	WorkTree       string
+}
+
+func (gc GitContext) ShortCommit() string {
+	if len(gc.Commit) > shortCommitLength {
+		return gc.Commit[:shortCommitLength]
+	}
+	return gc.Commit
+}
+
+// String describes git context in alarm, e.g. "alarm (main@3a82784, dirty)"
+func (gc GitContext) String() string {
+	branch := gc.Branch
+	if branch == "" {
+		branch = "detached"
+	}
+	commit := gc.ShortCommit()
+	if commit == "" {
+		commit = "no commit"
+	}
+	str := fmt.Sprintf("%s (%s@%s", gc.Repository, branch, commit)
+	if gc.IsDirty {
+		str += ", dirty"
+	} else if gc.IsDirtyUnknown {
+		str += ", dirty unknown"
+	}
+	return str + ")"
+}
+
+// gitDirectory is locations of a repository, gitDir and commonDir are different for linked worktrees
+type gitDirectory struct {
+	workTree  string
+	gitDir    string
+	commonDir string
+}
+
+// FindGitContext finds the repository of directory or its parents and reads its state
+func FindGitContext(directory string) (GitContext, bool) {
+	if directory == "" {
+		return GitContext{}, false
+	}
+	gd, err := findGitDirectory(filepath.Clean(directory))
+	if err != nil {
+		return GitContext{}, false
+	}
+
+	gitContext := GitContext{
+		Repository: gd.repositoryName(),
+		WorkTree:   gd.workTree,
+	}
+	gitContext.Branch, gitContext.Commit, err = gd.readHead()
+	if err != nil {
+		return GitContext{}, false
+	}
+	gitContext.IsDirty, err = gd.isDirty(maxDirtyCheckEntryCount, dirtyCheckTimeout)
+	if err != nil {
+		gitContext.IsDirtyUnknown = true
+		if err != errDirtyCheckSkipped {
+			// stdout is the output of command which alarm runs
+			errMsg := fmt.Sprintf("error occured during reading index of %s: %v", gd.gitDir, err)
+			fmt.Fprintln(os.Stderr, errMsg)
+		}
+	}
+	return gitContext, true
+}
+
+// findGitDirectory handles ".git" file of linked worktrees and submodules, which is "gitdir: path"
+func findGitDirectory(directory string) (gitDirectory, error) {
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		dotGit := filepath.Join(dir, ".git")
+		fileInfo, err := os.Stat(dotGit)
+		if err == nil {
+			gd := gitDirectory{
+				workTree: dir,
+				gitDir:   dotGit,
+			}
+			if !fileInfo.IsDir() {
+				data, err := ioutil.ReadFile(dotGit)
+				if err != nil {
+					return gitDirectory{}, err
+				}
+				line := strings.TrimSpace(string(data))
+				if !strings.HasPrefix(line, "gitdir:") {
+					return gitDirectory{}, fmt.Errorf("%s is not a gitdir file", dotGit)
+				}
+				gd.gitDir = resolvePath(dir, strings.TrimSpace(strings.TrimPrefix(line, "gitdir:")))
+			}
+			gd.commonDir = gd.gitDir
+			if data, err := ioutil.ReadFile(filepath.Join(gd.gitDir, "commondir")); err == nil {
+				gd.commonDir = resolvePath(gd.gitDir, strings.TrimSpace(string(data)))
+			}
+			return gd, nil
+		}
+		if dir == filepath.Dir(dir) {
+			return gitDirectory{}, errors.New("not a git repository")
+		}
+	}
+}
+
+func resolvePath(base string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(base, path)
+}
+
+// repositoryName is the name of main working tree, so every worktree of a repository has the same name
+func (gd gitDirectory) repositoryName() string {
+	if gd.commonDir == gd.gitDir {
+		return filepath.Base(gd.workTree)
+	}
+	if filepath.Base(gd.commonDir) == ".git" {
+		return filepath.Base(filepath.Dir(gd.commonDir))
+	}
+	// bare repository, e.g. "project.git"
+	return strings.TrimSuffix(filepath.Base(gd.commonDir), ".git")
+}
+
+func (gd gitDirectory) readHead() (branch string, commit string, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(gd.gitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(head, "ref:") {
+		return "", head, nil
+	}
+	ref := strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+	branch = strings.TrimPrefix(ref, "refs/heads/")
+	commit, err = gd.resolveRef(ref)
+	if os.IsNotExist(err) {
+		// branch is not born yet
+		return branch, "", nil
+	}
+	return branch, commit, err
+}
+
+// resolveRef finds hash of ref from loose ref files and packed-refs,
+// refs are shared among worktrees except HEAD and a few others, which are in gitDir
+func (gd gitDirectory) resolveRef(ref string) (string, error) {
+	for depth := 0; depth < maxSymbolicRefDepth; depth++ {
+		value, err := gd.readLooseRef(ref)
+		if os.IsNotExist(err) {
+			return gd.readPackedRef(ref)
+		}
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(value, "ref:") {
+			return value, nil
+		}
+		ref = strings.TrimSpace(strings.TrimPrefix(value, "ref:"))
+	}
+	return "", fmt.Errorf("symbolic ref %s is too deep", ref)
+}
+
+func (gd gitDirectory) readLooseRef(ref string) (string, error) {
+	for _, dir := range []string{gd.gitDir, gd.commonDir} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref)))
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// readPackedRef reads packed-refs, whose line is "<hash> <ref>",
+// lines starting with "^" are peeled tags and "#" is the header
+func (gd gitDirectory) readPackedRef(ref string) (string, error) {
+	f, err := os.Open(filepath.Join(gd.commonDir, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		fieldList := strings.Fields(line)
+		if len(fieldList) == 2 && fieldList[1] == ref {
+			return fieldList[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", os.ErrNotExist
+}
+
+// mode of index entries
+const (
+	gitFileTypeMask = 0170000
+	gitRegularFile  = 0100000
+	gitSymbolicLink = 0120000
+	gitLink         = 0160000
+)
+
+// flags of index entries
+const (
+	gitAssumeValidFlag = 0x8000
+	gitExtendedFlag    = 0x4000
+	gitStageMask       = 0x3000
+	gitSkipWorkTree    = 0x4000
+)
+
+type gitIndexEntry struct {
+	path        string
+	mtime       int64
+	mtimeNano   int64
+	mode        uint32
+	size        uint32
+	hash        []byte
+	flags       uint16
+	isConflict  bool
+	isUnchecked bool
+}
+
+// isDirty compares working tree with index like "git diff --quiet".
+// files whose size and modification time are same as index are not read,
+// errDirtyCheckSkipped is returned when index has more entries than maxEntryCount or comparing takes longer than timeout
+func (gd gitDirectory) isDirty(maxEntryCount int, timeout time.Duration) (bool, error) {
+	startedAt := time.Now()
+	f, err := os.Open(filepath.Join(gd.gitDir, "index"))
+	if os.IsNotExist(err) {
+		// nothing is added yet
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// the number of entries in header is checked before the whole index is read
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, err
+	}
+	if binary.BigEndian.Uint32(header[8:12]) > uint32(maxEntryCount) {
+		return false, errDirtyCheckSkipped
+	}
+	rest, err := ioutil.ReadAll(f)
+	if err != nil {
+		return false, err
+	}
+	data := append(header, rest...)
+	newHash := sha1.New
+	if gd.isSha256Repository() {
+		newHash = sha256.New
+	}
+	entryList, err := parseGitIndex(data, newHash().Size())
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entryList {
+		if entry.isConflict {
+			return true, nil
+		}
+		if entry.isUnchecked {
+			continue
+		}
+		if time.Since(startedAt) > timeout {
+			return false, errDirtyCheckSkipped
+		}
+		isModified, err := gd.isModified(entry, newHash)
+		if err != nil {
+			return false, err
+		}
+		if isModified {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (gd gitDirectory) isSha256Repository() bool {
+	data, err := ioutil.ReadFile(filepath.Join(gd.commonDir, "config"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		nameAndValue := strings.SplitN(line, "=", 2)
+		if len(nameAndValue) == 2 &&
+			strings.EqualFold(strings.TrimSpace(nameAndValue[0]), "objectformat") &&
+			strings.TrimSpace(nameAndValue[1]) == "sha256" {
+			return true
+		}
+	}
+	return false
+}
+
+func (gd gitDirectory) isModified(entry gitIndexEntry, newHash func() hash.Hash) (bool, error) {
+	path := filepath.Join(gd.workTree, filepath.FromSlash(entry.path))
+	fileInfo, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch entry.mode & gitFileTypeMask {
+	case gitSymbolicLink:
+		if fileInfo.Mode()&os.ModeSymlink == 0 {
+			return true, nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(hashGitBlob(newHash, []byte(target)), entry.hash), nil
+	case gitRegularFile:
+		if !fileInfo.Mode().IsRegular() {
+			return true, nil
+		}
+		isExecutable := fileInfo.Mode()&0100 != 0
+		if isExecutable != (entry.mode&0100 != 0) {
+			return true, nil
+		}
+		if uint32(fileInfo.Size()) == entry.size &&
+			fileInfo.ModTime().Unix() == entry.mtime && int64(fileInfo.ModTime().Nanosecond()) == entry.mtimeNano {
+			return false, nil
+		}
+		// modification time is changed by touch or checkout, so the content is compared
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		return !bytes.Equal(hashGitBlob(newHash, data), entry.hash), nil
+	}
+	return false, nil
+}
+
+// hashGitBlob is the object id of blob, which is hash of "blob <size>\0<content>"
+func hashGitBlob(newHash func() hash.Hash, data []byte) []byte {
+	h := newHash()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// parseGitIndex parses entries of index file version 2, 3 and 4, extensions after entries are ignored.
+// see https://git-scm.com/docs/index-format
+func parseGitIndex(data []byte, hashSize int) ([]gitIndexEntry, error) {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("invalid signature of index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported version %d of index", version)
+	}
+	entryCount := binary.BigEndian.Uint32(data[8:12])
+
+	entryList := []gitIndexEntry{}
+	offset := 12
+	previousPath := ""
+	for i := uint32(0); i < entryCount; i++ {
+		entryStart := offset
+		if len(data) < offset+40+hashSize+2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		entry := gitIndexEntry{
+			mtime:     int64(binary.BigEndian.Uint32(data[offset+8:])),
+			mtimeNano: int64(binary.BigEndian.Uint32(data[offset+12:])),
+			mode:      binary.BigEndian.Uint32(data[offset+24:]),
+			size:      binary.BigEndian.Uint32(data[offset+36:]),
+			hash:      data[offset+40 : offset+40+hashSize],
+		}
+		offset += 40 + hashSize
+		entry.flags = binary.BigEndian.Uint16(data[offset:])
+		offset += 2
+		extendedFlags := uint16(0)
+		if version >= 3 && entry.flags&gitExtendedFlag != 0 {
+			if len(data) < offset+2 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			extendedFlags = binary.BigEndian.Uint16(data[offset:])
+			offset += 2
+		}
+
+		if version == 4 {
+			// path is compressed, the number of bytes removed from previous path and the rest
+			removedLength, n := binary.Uvarint(data[offset:])
+			if n <= 0 || int(removedLength) > len(previousPath) {
+				return nil, errors.New("invalid path of index entry")
+			}
+			offset += n
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			entry.path = previousPath[:len(previousPath)-int(removedLength)] + string(data[offset:offset+end])
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			entry.path = string(data[offset : offset+end])
+			// entries are padded with 1 to 8 NUL bytes to keep the size multiple of 8
+			offset = entryStart + (offset+end-entryStart+8)/8*8
+		}
+		previousPath = entry.path
+
+		entry.isConflict = entry.flags&gitStageMask != 0
+		fileType := entry.mode & gitFileTypeMask
+		entry.isUnchecked = entry.flags&gitAssumeValidFlag != 0 || extendedFlags&gitSkipWorkTree != 0 ||
+			fileType == gitLink || (fileType != gitRegularFile && fileType != gitSymbolicLink)
+		entryList = append(entryList, entry)
+	}
+	return entryList, nil
+}
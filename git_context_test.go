@@ -0,0 +1,219 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitContext(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Run("BranchAndCommit", CheckBranchAndCommit())
+	t.Run("PackedRefs", CheckPackedRefs())
+	t.Run("DirtyState", CheckDirtyState())
+	t.Run("IndexVersion4", CheckIndexVersion4())
+	t.Run("Worktree", CheckWorktree())
+	t.Run("NotRepository", CheckNotRepository())
+}
+
+// prepareGitRepository creates a repository named "billing" with a commit on branch main
+func prepareGitRepository(t *testing.T) string {
+	parentDir, err := ioutil.TempDir("", "git")
+	require.NoError(t, err)
+	dir := filepath.Join(parentDir, "billing")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "cmd"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("billing\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cmd", "main.go"), []byte("package main\n"), 0644))
+	require.NoError(t, os.Symlink("README.md", filepath.Join(dir, "README")))
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "initial commit")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir,
+	)
+	output, err := c.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+func CheckBranchAndCommit() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareGitRepository(t)
+		defer os.RemoveAll(filepath.Dir(dir))
+
+		gitContext, ok := FindGitContext(filepath.Join(dir, "cmd"))
+		require.True(t, ok)
+		commit := runGit(t, dir, "rev-parse", "HEAD")
+		require.Equal(
+			t,
+			GitContext{
+				Repository: "billing",
+				Branch:     "main",
+				Commit:     commit,
+				WorkTree:   dir,
+			},
+			gitContext,
+		)
+		require.Equal(t, "billing (main@"+commit[:7]+")", gitContext.String())
+
+		runGit(t, dir, "checkout", "-q", "--detach")
+		gitContext, ok = FindGitContext(dir)
+		require.True(t, ok)
+		require.Equal(t, "", gitContext.Branch)
+		require.Equal(t, commit, gitContext.Commit)
+
+		runGit(t, dir, "checkout", "-q", "--orphan", "unborn")
+		gitContext, ok = FindGitContext(dir)
+		require.True(t, ok)
+		require.Equal(t, "unborn", gitContext.Branch)
+		require.Equal(t, "", gitContext.Commit)
+	}
+}
+
+func CheckPackedRefs() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareGitRepository(t)
+		defer os.RemoveAll(filepath.Dir(dir))
+
+		runGit(t, dir, "tag", "-a", "-m", "release", "v1.0.0")
+		runGit(t, dir, "pack-refs", "--all")
+		_, err := os.Stat(filepath.Join(dir, ".git", "refs", "heads", "main"))
+		require.True(t, os.IsNotExist(err))
+
+		gitContext, ok := FindGitContext(dir)
+		require.True(t, ok)
+		require.Equal(t, "main", gitContext.Branch)
+		require.Equal(t, runGit(t, dir, "rev-parse", "HEAD"), gitContext.Commit)
+	}
+}
+
+func CheckDirtyState() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareGitRepository(t)
+		defer os.RemoveAll(filepath.Dir(dir))
+		requireDirty := func(isDirty bool) {
+			gitContext, ok := FindGitContext(dir)
+			require.True(t, ok)
+			require.Equal(t, isDirty, gitContext.IsDirty)
+		}
+		requireDirty(false)
+
+		// untracked files are not changes
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0644))
+		requireDirty(false)
+
+		// content is compared when modification time is changed
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "README.md"), future, future))
+		requireDirty(false)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("billing service\n"), 0644))
+		requireDirty(true)
+		runGit(t, dir, "checkout", "-q", "README.md")
+		requireDirty(false)
+
+		require.NoError(t, os.Chmod(filepath.Join(dir, "cmd", "main.go"), 0755))
+		requireDirty(true)
+		require.NoError(t, os.Chmod(filepath.Join(dir, "cmd", "main.go"), 0644))
+		requireDirty(false)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "README")))
+		require.NoError(t, os.Symlink("cmd", filepath.Join(dir, "README")))
+		requireDirty(true)
+		runGit(t, dir, "checkout", "-q", "README")
+		requireDirty(false)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "cmd", "main.go")))
+		requireDirty(true)
+
+		// big repositories are not checked
+		gd, err := findGitDirectory(dir)
+		require.NoError(t, err)
+		isDirty, err := gd.isDirty(maxDirtyCheckEntryCount, dirtyCheckTimeout)
+		require.NoError(t, err)
+		require.True(t, isDirty)
+		_, err = gd.isDirty(2, dirtyCheckTimeout)
+		require.Equal(t, errDirtyCheckSkipped, err)
+		_, err = gd.isDirty(maxDirtyCheckEntryCount, 0)
+		require.Equal(t, errDirtyCheckSkipped, err)
+
+		// dirty state is unknown when index can not be read
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".git", "index"), []byte("broken"), 0644))
+		gitContext, ok := FindGitContext(dir)
+		require.True(t, ok)
+		require.False(t, gitContext.IsDirty)
+		require.True(t, gitContext.IsDirtyUnknown)
+		require.Equal(t, "billing (main@"+gitContext.ShortCommit()+", dirty unknown)", gitContext.String())
+	}
+}
+
+func CheckIndexVersion4() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareGitRepository(t)
+		defer os.RemoveAll(filepath.Dir(dir))
+
+		runGit(t, dir, "update-index", "--index-version", "4")
+		gitContext, ok := FindGitContext(dir)
+		require.True(t, ok)
+		require.False(t, gitContext.IsDirty)
+
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cmd", "main.go"), []byte("package cmd\n"), 0644))
+		gitContext, ok = FindGitContext(dir)
+		require.True(t, ok)
+		require.True(t, gitContext.IsDirty)
+	}
+}
+
+func CheckWorktree() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareGitRepository(t)
+		defer os.RemoveAll(filepath.Dir(dir))
+
+		worktree := filepath.Join(filepath.Dir(dir), "billing-feature")
+		runGit(t, dir, "worktree", "add", "-q", "-b", "feature", worktree)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(worktree, "README.md"), []byte("feature\n"), 0644))
+
+		gitContext, ok := FindGitContext(filepath.Join(worktree, "cmd"))
+		require.True(t, ok)
+		require.Equal(t, "billing", gitContext.Repository)
+		require.Equal(t, "feature", gitContext.Branch)
+		require.Equal(t, runGit(t, dir, "rev-parse", "feature"), gitContext.Commit)
+		require.Equal(t, worktree, gitContext.WorkTree)
+		require.True(t, gitContext.IsDirty)
+
+		// main working tree is not affected by changes in worktree
+		gitContext, ok = FindGitContext(dir)
+		require.True(t, ok)
+		require.Equal(t, "main", gitContext.Branch)
+		require.False(t, gitContext.IsDirty)
+	}
+}
+
+func CheckNotRepository() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "git")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		_, ok := FindGitContext(dir)
+		require.False(t, ok)
+		_, ok = FindGitContext("")
+		require.False(t, ok)
+	}
+}
@@ -3,6 +3,9 @@ module github.com/goodahn/alarm-for-programmer
 go 1.15
 
 require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/mitchellh/go-ps v1.0.0
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,330 @@
+package alarm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GolangTestPassed  = "pass"
+	GolangTestFailed  = "fail"
+	GolangTestSkipped = "skip"
+
+	// golangTestFailureLineCount is the number of output lines of failed test which are reported,
+	// testify writes error message at the third line
+	golangTestFailureLineCount = 4
+)
+
+// GolangTestEvent is an event of "go test -json", which is converted from test output by test2json
+type GolangTestEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+	// ImportPath and FailedBuild are set for build errors since go 1.24,
+	// build output is reported with ImportPath of the build and package fails with FailedBuild
+	ImportPath  string `json:"ImportPath"`
+	FailedBuild string `json:"FailedBuild"`
+}
+
+type GolangTestResult struct {
+	Package string
+	Name    string
+	Action  string
+	Elapsed time.Duration
+	// OutputLineList is output of the test except lines written by testing package, e.g. "=== RUN"
+	OutputLineList []string
+}
+
+// FailureLineList returns the first lines of output of failed test
+func (r GolangTestResult) FailureLineList() []string {
+	if len(r.OutputLineList) > golangTestFailureLineCount {
+		return r.OutputLineList[:golangTestFailureLineCount]
+	}
+	return r.OutputLineList
+}
+
+type GolangTestPackageResult struct {
+	ImportPath string
+	// Name is the name in package clause, which is found by go list
+	Name    string
+	Action  string
+	Elapsed time.Duration
+	// OutputLineList is output which doesn't belong to any test, e.g. build errors
+	OutputLineList []string
+
+	testResultByName map[string]*GolangTestResult
+	testNameList     []string
+}
+
+// LeafTestResultList returns results of tests which have no subtests,
+// parent test fails whenever its subtest fails, so it is not counted
+func (p *GolangTestPackageResult) LeafTestResultList() []GolangTestResult {
+	testResultList := []GolangTestResult{}
+	for _, name := range p.testNameList {
+		if p.hasSubtest(name) {
+			continue
+		}
+		testResultList = append(testResultList, *p.testResultByName[name])
+	}
+	return testResultList
+}
+
+func (p *GolangTestPackageResult) hasSubtest(name string) bool {
+	for _, _name := range p.testNameList {
+		if strings.HasPrefix(_name, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CountByAction counts leaf tests by their result, e.g. counts[GolangTestPassed]
+func (p *GolangTestPackageResult) CountByAction() map[string]int {
+	countByAction := map[string]int{}
+	for _, testResult := range p.LeafTestResultList() {
+		countByAction[testResult.Action] += 1
+	}
+	return countByAction
+}
+
+// GolangTestReport aggregates events of "go test -json" per package
+type GolangTestReport struct {
+	// ModulePath is the module in go.mod, which is trimmed from import paths of packages
+	ModulePath string
+
+	packageResultByImportPath map[string]*GolangTestPackageResult
+	importPathList            []string
+	// buildOutputLineListByBuild is output of builds which is not attached to packages yet
+	buildOutputLineListByBuild map[string][]string
+}
+
+func NewGolangTestReport(modulePath string) *GolangTestReport {
+	return &GolangTestReport{
+		ModulePath:                 modulePath,
+		packageResultByImportPath:  map[string]*GolangTestPackageResult{},
+		buildOutputLineListByBuild: map[string][]string{},
+	}
+}
+
+func (r *GolangTestReport) AddEvent(event GolangTestEvent) {
+	if event.Package == "" {
+		if event.Action == "build-output" && event.ImportPath != "" {
+			if line := strings.TrimRight(event.Output, "\n"); isGolangPackageOutputLine(line) {
+				r.buildOutputLineListByBuild[event.ImportPath] = append(r.buildOutputLineListByBuild[event.ImportPath], line)
+			}
+		}
+		return
+	}
+	packageResult := r.getPackageResult(event.Package)
+	if event.Test == "" {
+		switch event.Action {
+		case GolangTestPassed, GolangTestFailed, GolangTestSkipped:
+			packageResult.Action = event.Action
+			packageResult.Elapsed = secondsToDuration(event.Elapsed)
+			if event.FailedBuild != "" {
+				packageResult.OutputLineList = append(packageResult.OutputLineList, r.buildOutputLineListByBuild[event.FailedBuild]...)
+			}
+		case "output", "build-output":
+			if line := strings.TrimRight(event.Output, "\n"); isGolangPackageOutputLine(line) {
+				packageResult.OutputLineList = append(packageResult.OutputLineList, line)
+			}
+		}
+		return
+	}
+
+	testResult, ok := packageResult.testResultByName[event.Test]
+	if !ok {
+		testResult = &GolangTestResult{
+			Package: event.Package,
+			Name:    event.Test,
+		}
+		packageResult.testResultByName[event.Test] = testResult
+		packageResult.testNameList = append(packageResult.testNameList, event.Test)
+	}
+	switch event.Action {
+	case GolangTestPassed, GolangTestFailed, GolangTestSkipped:
+		testResult.Action = event.Action
+		testResult.Elapsed = secondsToDuration(event.Elapsed)
+	case "output":
+		if line := strings.TrimSpace(event.Output); isGolangTestOutputLine(line) {
+			testResult.OutputLineList = append(testResult.OutputLineList, line)
+		}
+	}
+}
+
+func (r *GolangTestReport) getPackageResult(importPath string) *GolangTestPackageResult {
+	packageResult, ok := r.packageResultByImportPath[importPath]
+	if !ok {
+		packageResult = &GolangTestPackageResult{
+			ImportPath:       importPath,
+			testResultByName: map[string]*GolangTestResult{},
+		}
+		r.packageResultByImportPath[importPath] = packageResult
+		r.importPathList = append(r.importPathList, importPath)
+	}
+	return packageResult
+}
+
+// isGolangTestOutputLine drops lines which are written by testing package for every test
+func isGolangTestOutputLine(line string) bool {
+	return line != "" && !strings.HasPrefix(line, "=== ") && !strings.HasPrefix(line, "--- ")
+}
+
+// isGolangPackageOutputLine drops summary lines of package, e.g. "ok  pkg 0.1s" and "PASS",
+// and headers of build output, e.g. "# pkg"
+func isGolangPackageOutputLine(line string) bool {
+	if line == "" || line == "PASS" || line == "FAIL" || strings.HasPrefix(line, "ok  ") || strings.HasPrefix(line, "# ") ||
+		strings.HasPrefix(line, "FAIL\t") || strings.HasPrefix(line, "?   ") || strings.HasPrefix(line, "coverage: ") {
+		return false
+	}
+	return isGolangTestOutputLine(line)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// SetPackageName sets the name in package clause of the package, which is found by go list
+func (r *GolangTestReport) SetPackageName(importPath string, name string) {
+	if packageResult, ok := r.packageResultByImportPath[importPath]; ok {
+		packageResult.Name = name
+	}
+}
+
+// GetImportPathList returns import paths of packages in the order they are reported
+func (r *GolangTestReport) GetImportPathList() []string {
+	return append([]string{}, r.importPathList...)
+}
+
+func (r *GolangTestReport) GetPackageResultList() []GolangTestPackageResult {
+	packageResultList := []GolangTestPackageResult{}
+	for _, importPath := range r.importPathList {
+		packageResultList = append(packageResultList, *r.packageResultByImportPath[importPath])
+	}
+	return packageResultList
+}
+
+// GetFailedTestResultList returns failed leaf tests in the order they are reported
+func (r *GolangTestReport) GetFailedTestResultList() []GolangTestResult {
+	failedTestResultList := []GolangTestResult{}
+	for _, importPath := range r.importPathList {
+		for _, testResult := range r.packageResultByImportPath[importPath].LeafTestResultList() {
+			if testResult.Action == GolangTestFailed {
+				failedTestResultList = append(failedTestResultList, testResult)
+			}
+		}
+	}
+	return failedTestResultList
+}
+
+// GetSlowestTestResultList returns top-level tests which took the longest, elapsed time of test includes its subtests
+func (r *GolangTestReport) GetSlowestTestResultList(count int) []GolangTestResult {
+	testResultList := []GolangTestResult{}
+	for _, importPath := range r.importPathList {
+		packageResult := r.packageResultByImportPath[importPath]
+		for _, name := range packageResult.testNameList {
+			testResult := packageResult.testResultByName[name]
+			if !strings.Contains(name, "/") && testResult.Action != GolangTestSkipped && testResult.Elapsed > 0 {
+				testResultList = append(testResultList, *testResult)
+			}
+		}
+	}
+	sort.SliceStable(testResultList, func(i, j int) bool {
+		return testResultList[i].Elapsed > testResultList[j].Elapsed
+	})
+	if len(testResultList) > count {
+		testResultList = testResultList[:count]
+	}
+	return testResultList
+}
+
+// RelativeImportPath trims module path from import path, e.g. "./alarm" for "github.com/goodahn/alarm-for-programmer/alarm"
+func (r *GolangTestReport) RelativeImportPath(importPath string) string {
+	if r.ModulePath == "" {
+		return importPath
+	}
+	if importPath == r.ModulePath {
+		return "."
+	}
+	if strings.HasPrefix(importPath, r.ModulePath+"/") {
+		return "./" + strings.TrimPrefix(importPath, r.ModulePath+"/")
+	}
+	return importPath
+}
+
+// FindGolangModulePath reads module path of go.mod in directory or its parents
+func FindGolangModulePath(directory string) (string, error) {
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return "", err
+	}
+	for {
+		data, err := ioutil.ReadFile(filepath.Join(directory, "go.mod"))
+		if err == nil {
+			return parseGolangModulePath(data)
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parentDirectory := filepath.Dir(directory)
+		if parentDirectory == directory {
+			return "", errors.New("go.mod is not found")
+		}
+		directory = parentDirectory
+	}
+}
+
+func parseGolangModulePath(data []byte) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		modulePath := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if unquoted, err := strconv.Unquote(modulePath); err == nil {
+			modulePath = unquoted
+		}
+		if modulePath != "" {
+			return modulePath, nil
+		}
+	}
+	return "", errors.New("module is not declared in go.mod")
+}
+
+// ListGolangPackageNames runs go list to find names in package clause of packages,
+// which can't be told from import path, e.g. package main
+func ListGolangPackageNames(directory string, importPathList []string) (map[string]string, error) {
+	packageNameByImportPath := map[string]string{}
+	if len(importPathList) == 0 {
+		return packageNameByImportPath, nil
+	}
+	c := exec.Command("go", append([]string{"list", "-e", "-f", "{{.ImportPath}} {{.Name}}"}, importPathList...)...)
+	c.Dir = directory
+	output, err := c.Output()
+	if err != nil {
+		return packageNameByImportPath, err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fieldList := strings.Fields(line)
+		if len(fieldList) == 2 {
+			packageNameByImportPath[fieldList[0]] = fieldList[1]
+		}
+	}
+	return packageNameByImportPath, nil
+}
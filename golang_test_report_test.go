@@ -0,0 +1,157 @@
+package alarm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const golangTestOutput = `{"Action":"start","Package":"example.com/sample"}
+{"Action":"run","Package":"example.com/sample","Test":"TestSlow"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSlow","Output":"=== RUN   TestSlow\n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSlow","Output":"--- PASS: TestSlow (0.30s)\n"}
+{"Action":"pass","Package":"example.com/sample","Test":"TestSlow","Elapsed":0.3}
+{"Action":"run","Package":"example.com/sample","Test":"TestSub"}
+{"Action":"run","Package":"example.com/sample","Test":"TestSub/ok"}
+{"Action":"pass","Package":"example.com/sample","Test":"TestSub/ok","Elapsed":0}
+{"Action":"run","Package":"example.com/sample","Test":"TestSub/bad"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"=== RUN   TestSub/bad\n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"    a_test.go:8: \n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"        \tError Trace:\ta_test.go:8\n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"        \tError:      \tNot equal: \n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"        \t            \texpected: 1\n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"        \t            \tactual  : 2\n"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSub/bad","Output":"--- FAIL: TestSub/bad (0.00s)\n"}
+{"Action":"fail","Package":"example.com/sample","Test":"TestSub/bad","Elapsed":0}
+{"Action":"fail","Package":"example.com/sample","Test":"TestSub","Elapsed":0.5}
+{"Action":"run","Package":"example.com/sample","Test":"TestSkip"}
+{"Action":"output","Package":"example.com/sample","Test":"TestSkip","Output":"    a_test.go:10: later\n"}
+{"Action":"skip","Package":"example.com/sample","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example.com/sample","Output":"FAIL\n"}
+{"Action":"output","Package":"example.com/sample","Output":"FAIL\texample.com/sample\t0.804s\n"}
+{"Action":"fail","Package":"example.com/sample","Elapsed":0.804}
+{"ImportPath":"example.com/sample/sub [example.com/sample/sub.test]","Action":"build-output","Output":"# example.com/sample/sub [example.com/sample/sub.test]\n"}
+{"ImportPath":"example.com/sample/sub [example.com/sample/sub.test]","Action":"build-output","Output":"sub/b_test.go:3:33: undefined: undefinedCall\n"}
+{"ImportPath":"example.com/sample/sub [example.com/sample/sub.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/sample/sub"}
+{"Action":"output","Package":"example.com/sample/sub","Output":"FAIL\texample.com/sample/sub [build failed]\n"}
+{"Action":"fail","Package":"example.com/sample/sub","Elapsed":0,"FailedBuild":"example.com/sample/sub [example.com/sample/sub.test]"}
+{"Action":"output","Package":"example.com/sample/cmd","Output":"?   \texample.com/sample/cmd\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/sample/cmd","Elapsed":0}
+`
+
+func TestGolangTestReport(t *testing.T) {
+	t.Run("CountByAction", CheckCountByAction())
+	t.Run("FailedTestResultList", CheckFailedTestResultList())
+	t.Run("SlowestTestResultList", CheckSlowestTestResultList())
+	t.Run("BuildFailure", CheckBuildFailure())
+	t.Run("RelativeImportPath", CheckRelativeImportPath())
+	t.Run("ParseGolangModulePath", CheckParseGolangModulePath())
+}
+
+func newSampleGolangTestReport(t *testing.T) *GolangTestReport {
+	report := NewGolangTestReport("example.com/sample")
+	for _, line := range strings.Split(strings.TrimSpace(golangTestOutput), "\n") {
+		event := GolangTestEvent{}
+		require.NoError(t, json.Unmarshal([]byte(line), &event))
+		report.AddEvent(event)
+	}
+	return report
+}
+
+func CheckCountByAction() func(*testing.T) {
+	return func(t *testing.T) {
+		report := newSampleGolangTestReport(t)
+		require.Equal(
+			t,
+			[]string{"example.com/sample", "example.com/sample/sub", "example.com/sample/cmd"},
+			report.GetImportPathList(),
+		)
+
+		packageResultList := report.GetPackageResultList()
+		require.Equal(t, GolangTestFailed, packageResultList[0].Action)
+		require.Equal(t, 804*time.Millisecond, packageResultList[0].Elapsed)
+		// TestSub is not counted because it has subtests
+		require.Equal(
+			t,
+			map[string]int{GolangTestPassed: 2, GolangTestFailed: 1, GolangTestSkipped: 1},
+			packageResultList[0].CountByAction(),
+		)
+		require.Equal(t, GolangTestSkipped, packageResultList[2].Action)
+		require.Empty(t, packageResultList[2].CountByAction())
+	}
+}
+
+func CheckFailedTestResultList() func(*testing.T) {
+	return func(t *testing.T) {
+		report := newSampleGolangTestReport(t)
+		failedTestResultList := report.GetFailedTestResultList()
+		require.Len(t, failedTestResultList, 1)
+		require.Equal(t, "TestSub/bad", failedTestResultList[0].Name)
+		require.Equal(
+			t,
+			[]string{
+				"a_test.go:8:",
+				"Error Trace:\ta_test.go:8",
+				"Error:      \tNot equal:",
+				"expected: 1",
+			},
+			failedTestResultList[0].FailureLineList(),
+		)
+	}
+}
+
+func CheckSlowestTestResultList() func(*testing.T) {
+	return func(t *testing.T) {
+		report := newSampleGolangTestReport(t)
+		slowestTestResultList := report.GetSlowestTestResultList(3)
+		// subtests and skipped tests are excluded
+		require.Len(t, slowestTestResultList, 2)
+		require.Equal(t, "TestSub", slowestTestResultList[0].Name)
+		require.Equal(t, 500*time.Millisecond, slowestTestResultList[0].Elapsed)
+		require.Equal(t, "TestSlow", slowestTestResultList[1].Name)
+
+		require.Len(t, report.GetSlowestTestResultList(1), 1)
+	}
+}
+
+func CheckBuildFailure() func(*testing.T) {
+	return func(t *testing.T) {
+		report := newSampleGolangTestReport(t)
+		packageResult := report.GetPackageResultList()[1]
+		require.Equal(t, GolangTestFailed, packageResult.Action)
+		require.Equal(t, []string{"sub/b_test.go:3:33: undefined: undefinedCall"}, packageResult.OutputLineList)
+	}
+}
+
+func CheckRelativeImportPath() func(*testing.T) {
+	return func(t *testing.T) {
+		report := NewGolangTestReport("example.com/sample")
+		require.Equal(t, ".", report.RelativeImportPath("example.com/sample"))
+		require.Equal(t, "./sub", report.RelativeImportPath("example.com/sample/sub"))
+		require.Equal(t, "example.com/sample2", report.RelativeImportPath("example.com/sample2"))
+
+		report = NewGolangTestReport("")
+		require.Equal(t, "example.com/sample/sub", report.RelativeImportPath("example.com/sample/sub"))
+	}
+}
+
+func CheckParseGolangModulePath() func(*testing.T) {
+	return func(t *testing.T) {
+		for data, modulePath := range map[string]string{
+			"module example.com/sample\n\ngo 1.15\n":             "example.com/sample",
+			"// comment\nmodule example.com/sample // comment\n": "example.com/sample",
+			"module \"example.com/sample\"\n":                    "example.com/sample",
+		} {
+			_modulePath, err := parseGolangModulePath([]byte(data))
+			require.NoError(t, err)
+			require.Equal(t, modulePath, _modulePath)
+		}
+
+		_, err := parseGolangModulePath([]byte("go 1.15\n"))
+		require.Error(t, err)
+	}
+}
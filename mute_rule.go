@@ -0,0 +1,245 @@
+package alarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const MuteRuleFileName = "mute.json"
+
+// MuteRule stops sending alarms which it matches until it expires, they are still recorded and counted.
+// rule without Pattern and Destination mutes every alarm
+type MuteRule struct {
+	Id int `json:"id"`
+	// Pattern matches alarms of the monitoringCommand, or of commands which contain it, e.g. "go test"
+	Pattern string `json:"pattern,omitempty"`
+	// Destination matches alarms sent to the named destination of config
+	Destination string    `json:"destination,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// ExpiresAt is zero for rule which doesn't expire
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// IsGlobal reports whether the rule mutes every alarm
+func (mr MuteRule) IsGlobal() bool {
+	return mr.Pattern == "" && mr.Destination == ""
+}
+
+func (mr MuteRule) IsExpired(now time.Time) bool {
+	return !mr.ExpiresAt.IsZero() && !now.Before(mr.ExpiresAt)
+}
+
+// Matches reports whether the rule mutes the alarm of monitoringCommand and command sent to the named destination
+func (mr MuteRule) Matches(monitoringCommand string, command string, destination string, now time.Time) bool {
+	if mr.IsExpired(now) {
+		return false
+	}
+	if mr.Pattern != "" && mr.Pattern != monitoringCommand && !strings.Contains(command, mr.Pattern) {
+		return false
+	}
+	return mr.Destination == "" || mr.Destination == destination
+}
+
+// muteRuleFile is the content of mute rule file, NextId is kept so that ids of removed rules are not reused
+type muteRuleFile struct {
+	NextId   int        `json:"nextId"`
+	RuleList []MuteRule `json:"ruleList"`
+}
+
+// MuteRuleStore keeps mute rules in a json file so that they survive restart,
+// store without path keeps them only in memory
+type MuteRuleStore struct {
+	path     string
+	ruleList []MuteRule
+	nextId   int
+
+	mutexForRuleList sync.Mutex
+}
+
+// MuteRulePath is mute.json in $XDG_STATE_HOME/alarm-for-programmer
+func MuteRulePath() string {
+	return filepath.Join(StateDirectory(), MuteRuleFileName)
+}
+
+func NewMuteRuleStore() *MuteRuleStore {
+	return &MuteRuleStore{
+		ruleList: []MuteRule{},
+		nextId:   1,
+	}
+}
+
+// OpenMuteRuleStore reads mute rules of the file, the file is created when a rule is added
+func OpenMuteRuleStore(path string) (*MuteRuleStore, error) {
+	mrs := NewMuteRuleStore()
+	mrs.path = path
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return mrs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file := muteRuleFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s is broken: %v", path, err)
+	}
+	if file.RuleList != nil {
+		mrs.ruleList = file.RuleList
+	}
+	if file.NextId > mrs.nextId {
+		mrs.nextId = file.NextId
+	}
+	for _, muteRule := range mrs.ruleList {
+		if muteRule.Id >= mrs.nextId {
+			mrs.nextId = muteRule.Id + 1
+		}
+	}
+	return mrs, nil
+}
+
+// AddRule gives the rule an id and saves it
+func (mrs *MuteRuleStore) AddRule(muteRule MuteRule) (MuteRule, error) {
+	mrs.mutexForRuleList.Lock()
+	defer mrs.mutexForRuleList.Unlock()
+	muteRule.Id = mrs.nextId
+	mrs.nextId += 1
+	mrs.ruleList = append(mrs.ruleList, muteRule)
+	return muteRule, mrs.save()
+}
+
+// RemoveRule removes the rule of id, expired rule can be removed too
+func (mrs *MuteRuleStore) RemoveRule(id int) (MuteRule, error) {
+	mrs.mutexForRuleList.Lock()
+	defer mrs.mutexForRuleList.Unlock()
+	for i, muteRule := range mrs.ruleList {
+		if muteRule.Id == id {
+			mrs.ruleList = append(mrs.ruleList[:i], mrs.ruleList[i+1:]...)
+			return muteRule, mrs.save()
+		}
+	}
+	return MuteRule{}, fmt.Errorf("mute rule %d is not found", id)
+}
+
+// RemoveRuleListOf removes rules of exactly the pattern and the destination, e.g. global rules for empty ones
+func (mrs *MuteRuleStore) RemoveRuleListOf(pattern string, destination string) ([]MuteRule, error) {
+	mrs.mutexForRuleList.Lock()
+	defer mrs.mutexForRuleList.Unlock()
+	removedRuleList := []MuteRule{}
+	ruleList := []MuteRule{}
+	for _, muteRule := range mrs.ruleList {
+		if muteRule.Pattern == pattern && muteRule.Destination == destination {
+			removedRuleList = append(removedRuleList, muteRule)
+			continue
+		}
+		ruleList = append(ruleList, muteRule)
+	}
+	if len(removedRuleList) == 0 {
+		return removedRuleList, nil
+	}
+	mrs.ruleList = ruleList
+	return removedRuleList, mrs.save()
+}
+
+// GetRuleList returns rules which are not expired, expired ones are dropped from the file when it is saved next time
+func (mrs *MuteRuleStore) GetRuleList(now time.Time) []MuteRule {
+	mrs.mutexForRuleList.Lock()
+	defer mrs.mutexForRuleList.Unlock()
+	ruleList := []MuteRule{}
+	for _, muteRule := range mrs.ruleList {
+		if !muteRule.IsExpired(now) {
+			ruleList = append(ruleList, muteRule)
+		}
+	}
+	return ruleList
+}
+
+// FindMatchedRule returns the first rule which mutes the alarm
+func (mrs *MuteRuleStore) FindMatchedRule(monitoringCommand string, command string, destination string, now time.Time) (MuteRule, bool) {
+	mrs.mutexForRuleList.Lock()
+	defer mrs.mutexForRuleList.Unlock()
+	for _, muteRule := range mrs.ruleList {
+		if muteRule.Matches(monitoringCommand, command, destination, now) {
+			return muteRule, true
+		}
+	}
+	return MuteRule{}, false
+}
+
+// save writes rules which are not expired into a temporary file and renames it, so that the file is never half written
+func (mrs *MuteRuleStore) save() error {
+	now := time.Now()
+	ruleList := []MuteRule{}
+	for _, muteRule := range mrs.ruleList {
+		if !muteRule.IsExpired(now) {
+			ruleList = append(ruleList, muteRule)
+		}
+	}
+	mrs.ruleList = ruleList
+	if mrs.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(muteRuleFile{NextId: mrs.nextId, RuleList: ruleList}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(mrs.path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(mrs.path), MuteRuleFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), mrs.path)
+}
+
+// ParseMuteDuration reads how long a rule mutes alarms, which is a duration like "2h" or days like "3d"
+func ParseMuteDuration(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days > 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration > 0 {
+		return duration, nil
+	}
+	return 0, fmt.Errorf("%q is not a positive duration like 2h or 3d", value)
+}
+
+// ParseMuteUntil reads when a rule expires, which is RFC 3339, a date and time in local time zone like "2006-01-02 15:04",
+// or a time of day like "09:00" which is the next one after now
+func ParseMuteUntil(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04", value, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("15:04", value, now.Location()); err == nil {
+		t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !t.After(now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither RFC 3339 time, time like 2006-01-02 15:04 nor time of day like 09:00", value)
+}
@@ -0,0 +1,108 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteRuleStore(t *testing.T) {
+	t.Run("MuteRuleMatch", CheckMuteRuleMatch())
+	t.Run("PersistentMuteRule", CheckPersistentMuteRule())
+	t.Run("ParseMuteTime", CheckParseMuteTime())
+}
+
+func CheckMuteRuleMatch() func(*testing.T) {
+	return func(t *testing.T) {
+		now := time.Now()
+		global := MuteRule{}
+		require.True(t, global.IsGlobal())
+		require.True(t, global.Matches("make", "make build", "alarmConfig", now))
+
+		// pattern matches monitoringCommand or a part of command, e.g. of shell commands
+		pattern := MuteRule{Pattern: "go test", ExpiresAt: now.Add(time.Hour)}
+		require.True(t, pattern.Matches("go test", "go test ./...", "alarmConfig", now))
+		require.True(t, pattern.Matches("shell", "go test ./...", "alarmConfig", now))
+		require.False(t, pattern.Matches("make", "make build", "alarmConfig", now))
+		require.False(t, pattern.Matches("go test", "go test ./...", "alarmConfig", now.Add(time.Hour)))
+
+		destination := MuteRule{Destination: "team"}
+		require.True(t, destination.Matches("make", "make build", "team", now))
+		require.False(t, destination.Matches("make", "make build", "alarmConfig", now))
+	}
+}
+
+func CheckPersistentMuteRule() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "mute")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, MuteRuleFileName)
+
+		now := time.Now().UTC().Round(0)
+		mrs, err := OpenMuteRuleStore(path)
+		require.NoError(t, err)
+		muteRule, err := mrs.AddRule(MuteRule{Pattern: "make", CreatedAt: now})
+		require.NoError(t, err)
+		require.Equal(t, 1, muteRule.Id)
+		_, err = mrs.AddRule(MuteRule{Destination: "team", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+		require.NoError(t, err)
+		_, err = mrs.AddRule(MuteRule{CreatedAt: now})
+		require.NoError(t, err)
+		removedRuleList, err := mrs.RemoveRuleListOf("", "")
+		require.NoError(t, err)
+		require.Equal(t, 1, len(removedRuleList))
+
+		// rules survive restart, and ids are not reused
+		mrs, err = OpenMuteRuleStore(path)
+		require.NoError(t, err)
+		ruleList := mrs.GetRuleList(now)
+		require.Equal(t, 2, len(ruleList))
+		require.Equal(t, muteRule, ruleList[0])
+		require.Equal(t, 1, len(mrs.GetRuleList(now.Add(time.Hour))))
+		muteRule, err = mrs.AddRule(MuteRule{Pattern: "go test"})
+		require.NoError(t, err)
+		require.Equal(t, 4, muteRule.Id)
+
+		_, err = mrs.RemoveRule(1)
+		require.NoError(t, err)
+		_, err = mrs.RemoveRule(1)
+		require.EqualError(t, err, "mute rule 1 is not found")
+		_, ok := mrs.FindMatchedRule("make", "make build", "alarmConfig", now)
+		require.False(t, ok)
+
+		require.NoError(t, ioutil.WriteFile(path, []byte("["), 0600))
+		_, err = OpenMuteRuleStore(path)
+		require.Error(t, err)
+	}
+}
+
+func CheckParseMuteTime() func(*testing.T) {
+	return func(t *testing.T) {
+		duration, err := ParseMuteDuration("2h")
+		require.NoError(t, err)
+		require.Equal(t, 2*time.Hour, duration)
+		duration, err = ParseMuteDuration("3d")
+		require.NoError(t, err)
+		require.Equal(t, 72*time.Hour, duration)
+		_, err = ParseMuteDuration("-1h")
+		require.Error(t, err)
+
+		now := time.Date(2024, 5, 1, 10, 30, 0, 0, time.Local)
+		until, err := ParseMuteUntil("09:00", now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2024, 5, 2, 9, 0, 0, 0, time.Local), until)
+		until, err = ParseMuteUntil("18:00", now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2024, 5, 1, 18, 0, 0, 0, time.Local), until)
+		until, err = ParseMuteUntil("2024-05-03 08:00", now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2024, 5, 3, 8, 0, 0, 0, time.Local), until)
+		_, err = ParseMuteUntil("tomorrow", now)
+		require.Error(t, err)
+	}
+}
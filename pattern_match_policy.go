@@ -0,0 +1,95 @@
+package alarm
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// FirstPatternMatchPolicy tracks a process only by the first monitoringCommand which matches it,
+	// in the order of monitoringCommandList
+	FirstPatternMatchPolicy = "first"
+	// MostSpecificPatternMatchPolicy tracks a process only by the longest namePattern which matches it,
+	// environment marker is less specific than any namePattern
+	MostSpecificPatternMatchPolicy = "mostSpecific"
+	// AllPatternMatchPolicy tracks a process by every monitoringCommand which matches it,
+	// and only the first one alarms it
+	AllPatternMatchPolicy = "all"
+)
+
+var PatternMatchPolicyList = []string{FirstPatternMatchPolicy, MostSpecificPatternMatchPolicy, AllPatternMatchPolicy}
+
+// SetPatternMatchPolicy chooses which monitoringCommand tracks a process matched by several ones.
+// processes which are tracked already are kept tracking
+func (pim *ProcessInfoMonitor) SetPatternMatchPolicy(patternMatchPolicy string) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	pim.patternMatchPolicy = patternMatchPolicy
+}
+
+// findMatchedPatternList returns tracked monitoringCommands which match the process, in the order of priority.
+// watched process is matched by its pid first because it is chosen by the user.
+// markedPidList is processes opted in by environment marker
+func (pim *ProcessInfoMonitor) findMatchedPatternList(processInfo ProcessInfo, markedPidList []int) []string {
+	watchedPatternList := []string{}
+	matchedPatternList := []string{}
+	for _, monitoringCommand := range pim.trackedMonitoringCommandList() {
+		if watchedProcessInfo, ok := pim.watchedProcessByMonitoringCommand[monitoringCommand]; ok {
+			if watchedProcessInfo.Pid() == processInfo.Pid() && watchedProcessInfo.StartTime().Equal(processInfo.StartTime()) {
+				watchedPatternList = append(watchedPatternList, monitoringCommand)
+			}
+			continue
+		}
+		if pim.isEnvironmentMarkerMonitoringCommand(monitoringCommand) {
+			if findPidInPidList(processInfo.Pid(), markedPidList) {
+				matchedPatternList = append(matchedPatternList, monitoringCommand)
+			}
+			continue
+		}
+		if !strings.Contains(processInfo.Cmd(), monitoringCommand) {
+			continue
+		}
+		if _, isIgnored := pim.findIgnoredReasonByNamePattern(processInfo, monitoringCommand); !isIgnored && pim.isMonitoredByNamePattern(processInfo.Pid(), monitoringCommand) {
+			matchedPatternList = append(matchedPatternList, monitoringCommand)
+		}
+	}
+	if pim.patternMatchPolicy == MostSpecificPatternMatchPolicy {
+		sort.SliceStable(matchedPatternList, func(i, j int) bool {
+			return pim.specificityOf(matchedPatternList[i]) > pim.specificityOf(matchedPatternList[j])
+		})
+	}
+	return append(watchedPatternList, matchedPatternList...)
+}
+
+// specificityOf is the length of namePattern, environment marker matches a process without its command
+func (pim *ProcessInfoMonitor) specificityOf(monitoringCommand string) int {
+	if pim.isEnvironmentMarkerMonitoringCommand(monitoringCommand) {
+		return 0
+	}
+	return len(monitoringCommand)
+}
+
+func (pim *ProcessInfoMonitor) isEnvironmentMarkerMonitoringCommand(monitoringCommand string) bool {
+	return pim.environmentMarker != "" && monitoringCommand == EnvironmentMarkerMonitoringCommand(pim.environmentMarker)
+}
+
+// isTrackedByNamePattern reports whether namePattern should track the process which it matches.
+// process which is tracked by another monitoringCommand already is not tracked again,
+// e.g. when a more specific namePattern is added while the process is running
+func (pim *ProcessInfoMonitor) isTrackedByNamePattern(namePattern string, processInfo ProcessInfo, matchedPatternList []string) bool {
+	if pim.patternMatchPolicy == AllPatternMatchPolicy {
+		return true
+	}
+	for monitoringCommand, processStatusHistory := range pim.processStatusHistoryByMonitoringCommand {
+		history := processStatusHistory[processInfo.Pid()]
+		if monitoringCommand == namePattern || len(history) == 0 {
+			continue
+		}
+		latestProcessStatus := history[len(history)-1]
+		latestProcessInfo := latestProcessStatus.ProcessInfo()
+		if latestProcessStatus.Status() == ProcessStarted && latestProcessInfo.StartTime().Equal(processInfo.StartTime()) {
+			return false
+		}
+	}
+	return len(matchedPatternList) == 0 || matchedPatternList[0] == namePattern
+}
@@ -0,0 +1,307 @@
+package alarm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// constants of linux/connector.h and linux/cn_proc.h
+	connectorProcIndex   = 1
+	connectorProcValue   = 1
+	procMulticastListen  = 1
+	procEventNone        = 0
+	procEventExec        = 0x00000002
+	procEventExit        = 0x80000000
+	connectorMessageSize = 20
+	procEventHeaderSize  = 16
+
+	// exitedProcessRetention is how long exit codes are kept, they are looked up in the next monitoring periods
+	exitedProcessRetention = time.Minute
+)
+
+// nativeEndian is byte order of netlink messages, which are written in host byte order
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// ProcessExitListener receives exit events of every process from the proc connector of netlink,
+// so exit code of a process is known even if its parent waits it before /proc is read,
+// and children which finish before they are found in /proc are known by the parent of their exit.
+// listening needs CAP_NET_ADMIN, so it is not available for daemon of normal user
+type ProcessExitListener struct {
+	fd       int
+	isClosed bool
+
+	exitedProcessListByPid       map[int][]ExitedProcess
+	exitedProcessListByParentPid map[int][]ExitedProcess
+	// executedCommandByPid is command lines read when processes execute binaries, they are kept until the processes exit
+	executedCommandByPid map[int]executedCommand
+	prunedAt             time.Time
+
+	mutexForExitedProcess sync.Mutex
+}
+
+// ExitedProcess is a process found by its exit event
+type ExitedProcess struct {
+	Pid       int
+	ParentPid int
+	// Cmd is the command line when it executed the binary, it is empty if it is not read
+	Cmd string
+	// WaitStatus is exit_code of the event, which is in the same form as wait status
+	WaitStatus int
+	ExitedAt   time.Time
+}
+
+func (ep ExitedProcess) ExitCode() int {
+	return exitCodeOfWaitStatus(ep.WaitStatus)
+}
+
+type executedCommand struct {
+	cmd        string
+	executedAt time.Time
+}
+
+// NewProcessExitListener subscribes exit events, it returns error if they can not be received
+func NewProcessExitListener() (*ProcessExitListener, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_CONNECTOR)
+	if err != nil {
+		return nil, err
+	}
+	pel := &ProcessExitListener{
+		fd:                           fd,
+		exitedProcessListByPid:       map[int][]ExitedProcess{},
+		exitedProcessListByParentPid: map[int][]ExitedProcess{},
+		executedCommandByPid:         map[int]executedCommand{},
+	}
+	if err := pel.subscribe(); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	go pel.receive()
+	return pel, nil
+}
+
+// subscribe sends PROC_CN_MCAST_LISTEN and waits its acknowledgement, which has error of the request
+func (pel *ProcessExitListener) subscribe() error {
+	if err := syscall.Bind(pel.fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: connectorProcIndex}); err != nil {
+		return err
+	}
+	// socket is read again after timeout so that Close stops receiving
+	if err := syscall.SetsockoptTimeval(pel.fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &syscall.Timeval{Sec: 1}); err != nil {
+		return err
+	}
+	syscall.SetsockoptInt(pel.fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, 1024*1024)
+
+	// acknowledgement is told from ones of other listeners by ack, which is increased by one
+	ack := uint32(os.Getpid())
+	request := make([]byte, syscall.NLMSG_HDRLEN+connectorMessageSize+4)
+	nativeEndian.PutUint32(request[0:], uint32(len(request)))
+	nativeEndian.PutUint16(request[4:], syscall.NLMSG_DONE)
+	connectorMessage := request[syscall.NLMSG_HDRLEN:]
+	nativeEndian.PutUint32(connectorMessage[0:], connectorProcIndex)
+	nativeEndian.PutUint32(connectorMessage[4:], connectorProcValue)
+	nativeEndian.PutUint32(connectorMessage[12:], ack)
+	nativeEndian.PutUint16(connectorMessage[16:], 4)
+	nativeEndian.PutUint32(connectorMessage[connectorMessageSize:], procMulticastListen)
+	if err := syscall.Sendto(pel.fd, request, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	buf := make([]byte, 64*1024)
+	for time.Now().Before(deadline) {
+		n, _, err := syscall.Recvfrom(pel.fd, buf, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR || err == syscall.ENOBUFS {
+				continue
+			}
+			return err
+		}
+		receivedAck, ackErr, ok := pel.handleMessageList(buf[:n])
+		if ok && receivedAck == ack+1 {
+			if ackErr != 0 {
+				return fmt.Errorf("proc connector refused to listen: %v", syscall.Errno(ackErr))
+			}
+			return nil
+		}
+	}
+	return errors.New("proc connector didn't acknowledge listening")
+}
+
+func (pel *ProcessExitListener) receive() {
+	buf := make([]byte, 64*1024)
+	for !pel.isClosedNow() {
+		n, _, err := syscall.Recvfrom(pel.fd, buf, 0)
+		if err != nil {
+			// ENOBUFS means that events are dropped because they are not read fast enough
+			continue
+		}
+		pel.handleMessageList(buf[:n])
+	}
+	syscall.Close(pel.fd)
+}
+
+// handleMessageList keeps exit codes of exit events, and returns ack and error of acknowledgement if there is one
+func (pel *ProcessExitListener) handleMessageList(data []byte) (uint32, uint32, bool) {
+	netlinkMessageList, err := syscall.ParseNetlinkMessage(data)
+	if err != nil {
+		return 0, 0, false
+	}
+	receivedAck, ackErr, isAcknowledged := uint32(0), uint32(0), false
+	now := time.Now()
+	for _, netlinkMessage := range netlinkMessageList {
+		msg := netlinkMessage.Data
+		if len(msg) < connectorMessageSize+procEventHeaderSize+4 {
+			continue
+		}
+		if nativeEndian.Uint32(msg[0:]) != connectorProcIndex || nativeEndian.Uint32(msg[4:]) != connectorProcValue {
+			continue
+		}
+		event := msg[connectorMessageSize:]
+		eventData := event[procEventHeaderSize:]
+		switch nativeEndian.Uint32(event[0:]) {
+		case procEventNone:
+			receivedAck, ackErr, isAcknowledged = nativeEndian.Uint32(msg[12:]), nativeEndian.Uint32(eventData[0:]), true
+		case procEventExec:
+			pid := int(nativeEndian.Uint32(eventData[0:]))
+			tgid := int(nativeEndian.Uint32(eventData[4:]))
+			if pid != tgid {
+				continue
+			}
+			// process can finish before its command line is read, then it is kept empty
+			cmd, err := getCmdOfProcessByPid(pid)
+			if err == nil {
+				pel.addExecutedCommand(pid, executedCommand{cmd: cmd, executedAt: now})
+			}
+		case procEventExit:
+			if len(eventData) < 16 {
+				continue
+			}
+			pid := int(nativeEndian.Uint32(eventData[0:]))
+			tgid := int(nativeEndian.Uint32(eventData[4:]))
+			// every thread exits, but only the exit of the main thread is the exit of the process
+			if pid != tgid {
+				continue
+			}
+			exitedProcess := ExitedProcess{
+				Pid:        pid,
+				WaitStatus: int(nativeEndian.Uint32(eventData[8:])),
+				ExitedAt:   now,
+			}
+			// parent_tgid is added in Linux 4.18
+			if len(eventData) >= 24 {
+				exitedProcess.ParentPid = int(nativeEndian.Uint32(eventData[20:]))
+			}
+			pel.addExitedProcess(exitedProcess)
+		}
+	}
+	return receivedAck, ackErr, isAcknowledged
+}
+
+func (pel *ProcessExitListener) addExecutedCommand(pid int, executedCommand executedCommand) {
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	pel.executedCommandByPid[pid] = executedCommand
+}
+
+func (pel *ProcessExitListener) addExitedProcess(exitedProcess ExitedProcess) {
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	exitedProcess.Cmd = pel.executedCommandByPid[exitedProcess.Pid].cmd
+	delete(pel.executedCommandByPid, exitedProcess.Pid)
+	pel.exitedProcessListByPid[exitedProcess.Pid] = append(pel.exitedProcessListByPid[exitedProcess.Pid], exitedProcess)
+	pel.exitedProcessListByParentPid[exitedProcess.ParentPid] = append(pel.exitedProcessListByParentPid[exitedProcess.ParentPid], exitedProcess)
+	if exitedProcess.ExitedAt.Sub(pel.prunedAt) >= exitedProcessRetention {
+		pel.prune(exitedProcess.ExitedAt)
+	}
+}
+
+// prune drops exits older than exitedProcessRetention. command lines of processes running longer than it are dropped too,
+// because such processes are found in /proc with their command lines
+func (pel *ProcessExitListener) prune(now time.Time) {
+	pruneExitedProcessMap := func(exitedProcessListByPid map[int][]ExitedProcess) {
+		for pid, exitedProcessList := range exitedProcessListByPid {
+			keptExitedProcessList := []ExitedProcess{}
+			for _, exitedProcess := range exitedProcessList {
+				if now.Sub(exitedProcess.ExitedAt) < exitedProcessRetention {
+					keptExitedProcessList = append(keptExitedProcessList, exitedProcess)
+				}
+			}
+			if len(keptExitedProcessList) == 0 {
+				delete(exitedProcessListByPid, pid)
+				continue
+			}
+			exitedProcessListByPid[pid] = keptExitedProcessList
+		}
+	}
+	pruneExitedProcessMap(pel.exitedProcessListByPid)
+	pruneExitedProcessMap(pel.exitedProcessListByParentPid)
+	for pid, executedCommand := range pel.executedCommandByPid {
+		if now.Sub(executedCommand.executedAt) >= exitedProcessRetention {
+			delete(pel.executedCommandByPid, pid)
+		}
+	}
+	pel.prunedAt = now
+}
+
+// FindWaitStatus returns wait status of the process which started at startTime, if it exited within exitedProcessRetention.
+// nil listener finds nothing
+func (pel *ProcessExitListener) FindWaitStatus(pid int, startTime time.Time) (int, bool) {
+	if pel == nil {
+		return 0, false
+	}
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	// the first exit after start is the exit of the process, later ones are of processes which reuse the pid.
+	// start time read from /proc is accurate only to a second because boot time is written in seconds
+	for _, exitedProcess := range pel.exitedProcessListByPid[pid] {
+		if !exitedProcess.ExitedAt.Before(startTime.Add(-time.Second)) {
+			return exitedProcess.WaitStatus, true
+		}
+	}
+	return 0, false
+}
+
+// FindExitedChildList returns children of the parent which exited after since, within exitedProcessRetention.
+// nil listener finds nothing
+func (pel *ProcessExitListener) FindExitedChildList(parentPid int, since time.Time) []ExitedProcess {
+	exitedChildList := []ExitedProcess{}
+	if pel == nil {
+		return exitedChildList
+	}
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	for _, exitedProcess := range pel.exitedProcessListByParentPid[parentPid] {
+		if !exitedProcess.ExitedAt.Before(since.Add(-time.Second)) {
+			exitedChildList = append(exitedChildList, exitedProcess)
+		}
+	}
+	return exitedChildList
+}
+
+func (pel *ProcessExitListener) isClosedNow() bool {
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	return pel.isClosed
+}
+
+// Close stops receiving events, socket is closed by the receiving goroutine within a second
+func (pel *ProcessExitListener) Close() {
+	if pel == nil {
+		return
+	}
+	pel.mutexForExitedProcess.Lock()
+	defer pel.mutexForExitedProcess.Unlock()
+	pel.isClosed = true
+}
@@ -0,0 +1,61 @@
+package alarm
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessExitListener(t *testing.T) {
+	t.Run("WaitStatus", CheckWaitStatus())
+	t.Run("ExitedChildList", CheckExitedChildList())
+}
+
+// newProcessExitListenerForTest skips the test if proc connector is not available, e.g. for normal user
+func newProcessExitListenerForTest(t *testing.T) *ProcessExitListener {
+	processExitListener, err := NewProcessExitListener()
+	if err != nil {
+		t.Skipf("proc connector is not available: %v", err)
+	}
+	return processExitListener
+}
+
+// exit code of process which is waited at once is found
+func CheckWaitStatus() func(*testing.T) {
+	return func(t *testing.T) {
+		processExitListener := newProcessExitListenerForTest(t)
+		defer processExitListener.Close()
+
+		c := exec.Command("sh", "-c", "exit 3")
+		require.Error(t, c.Run())
+		startTime := time.Now().Add(-time.Second)
+		time.Sleep(100 * time.Millisecond)
+		waitStatus, ok := processExitListener.FindWaitStatus(c.Process.Pid, startTime)
+		require.True(t, ok)
+		require.Equal(t, 3, exitCodeOfWaitStatus(waitStatus))
+
+		// exit before start is of another process which used the pid
+		_, ok = processExitListener.FindWaitStatus(c.Process.Pid, time.Now().Add(time.Minute))
+		require.False(t, ok)
+	}
+}
+
+func CheckExitedChildList() func(*testing.T) {
+	return func(t *testing.T) {
+		processExitListener := newProcessExitListenerForTest(t)
+		defer processExitListener.Close()
+
+		startTime := time.Now()
+		c := exec.Command("sh", "-c", "sleep 0.1; sh -c 'sleep 0.1; exit 5'; true")
+		require.NoError(t, c.Run())
+		time.Sleep(100 * time.Millisecond)
+		exitedChildList := processExitListener.FindExitedChildList(c.Process.Pid, startTime)
+		require.Equal(t, 2, len(exitedChildList))
+		require.Equal(t, "sleep 0.1", exitedChildList[0].Cmd)
+		require.Equal(t, 0, exitedChildList[0].ExitCode())
+		require.Equal(t, "sh -c sleep 0.1; exit 5", exitedChildList[1].Cmd)
+		require.Equal(t, 5, exitedChildList[1].ExitCode())
+	}
+}
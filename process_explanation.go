@@ -0,0 +1,123 @@
+package alarm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatternExplanation tells why a monitoringCommand tracks the process or not
+type PatternExplanation struct {
+	MonitoringCommand string `json:"monitoringCommand"`
+	IsTracked         bool   `json:"isTracked"`
+	Reason            string `json:"reason"`
+}
+
+// ProcessExplanation tells why monitoringCommands track the running process or not
+type ProcessExplanation struct {
+	Pid       int    `json:"pid"`
+	Cmd       string `json:"cmd"`
+	Directory string `json:"directory"`
+	// IgnoredReason is why namePatterns never track the process, it is empty if they can track it
+	IgnoredReason          string               `json:"ignoredReason,omitempty"`
+	PatternExplanationList []PatternExplanation `json:"patternExplanationList"`
+}
+
+// ExplainPid tells why each tracked monitoringCommand tracks the running process or not.
+// processes watched by other pids are not related, so they are not explained
+func (pim *ProcessInfoMonitor) ExplainPid(pid int) (ProcessExplanation, error) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	processInfo := pim.processInfoReader.findProcessInfoByPid(pid)
+	if processInfo.Pid() == 0 {
+		return ProcessExplanation{}, fmt.Errorf("process %d is not running", pid)
+	}
+
+	processExplanation := ProcessExplanation{
+		Pid:                    pid,
+		Cmd:                    processInfo.Cmd(),
+		Directory:              processInfo.BinaryLocation(),
+		PatternExplanationList: []PatternExplanation{},
+	}
+	processExplanation.IgnoredReason, _ = pim.findIgnoredReason(processInfo)
+	markedPidList := pim.getPidListMarkedByEnvironment()
+	matchedPatternList := pim.findMatchedPatternList(processInfo, markedPidList)
+	for _, monitoringCommand := range pim.trackedMonitoringCommandList() {
+		if watchedProcessInfo, ok := pim.watchedProcessByMonitoringCommand[monitoringCommand]; ok && watchedProcessInfo.Pid() != pid {
+			continue
+		}
+		processExplanation.PatternExplanationList = append(
+			processExplanation.PatternExplanationList,
+			pim.explainMonitoringCommand(monitoringCommand, processInfo, markedPidList, matchedPatternList),
+		)
+	}
+	return processExplanation, nil
+}
+
+func (pim *ProcessInfoMonitor) explainMonitoringCommand(monitoringCommand string, processInfo ProcessInfo, markedPidList []int, matchedPatternList []string) PatternExplanation {
+	patternExplanation := PatternExplanation{
+		MonitoringCommand: monitoringCommand,
+	}
+	processStatusHistory := pim.processStatusHistoryByMonitoringCommand[monitoringCommand]
+	if history := processStatusHistory[processInfo.Pid()]; len(history) != 0 {
+		latestProcessStatus := history[len(history)-1]
+		latestProcessInfo := latestProcessStatus.ProcessInfo()
+		if latestProcessStatus.Status() == ProcessStarted && latestProcessInfo.StartTime().Equal(processInfo.StartTime()) {
+			patternExplanation.IsTracked = true
+			patternExplanation.Reason = fmt.Sprintf("tracked since %s", latestProcessStatus.TimeStamp().Format("15:04:05"))
+			return patternExplanation
+		}
+	}
+	if rootPid, ok := pim.findRootPidOfTrackedProcess(processInfo, processStatusHistory); ok {
+		patternExplanation.Reason = fmt.Sprintf("it is a part of the run of %d", rootPid)
+		return patternExplanation
+	}
+
+	if pim.isEnvironmentMarkerMonitoringCommand(monitoringCommand) {
+		if reason, ok := pim.findExcludedReason(processInfo); ok {
+			patternExplanation.Reason = reason
+			return patternExplanation
+		}
+		if !processInfo.IsMarkedByEnvironment(pim.environmentMarker) {
+			patternExplanation.Reason = fmt.Sprintf("it is not opted in by %s", pim.environmentMarker)
+			return patternExplanation
+		}
+		if !findPidInPidList(processInfo.Pid(), markedPidList) {
+			patternExplanation.Reason = fmt.Sprintf("its parent %d is opted in by %s already", processInfo.Ppid(), pim.environmentMarker)
+			return patternExplanation
+		}
+	} else if _, ok := pim.watchedProcessByMonitoringCommand[monitoringCommand]; !ok {
+		if !strings.Contains(processInfo.Cmd(), monitoringCommand) {
+			patternExplanation.Reason = "its command doesn't contain the pattern"
+			return patternExplanation
+		}
+		if reason, ok := pim.findIgnoredReasonByNamePattern(processInfo, monitoringCommand); ok {
+			patternExplanation.Reason = reason
+			return patternExplanation
+		}
+		if !pim.isMonitoredByNamePattern(processInfo.Pid(), monitoringCommand) {
+			patternExplanation.Reason = "the pattern of project config doesn't apply to its directory"
+			return patternExplanation
+		}
+	}
+
+	if !pim.isTrackedByNamePattern(monitoringCommand, processInfo, matchedPatternList) {
+		patternExplanation.Reason = fmt.Sprintf("it is tracked by %q by %s patternMatchPolicy", pim.findTrackingMonitoringCommand(processInfo, matchedPatternList), pim.patternMatchPolicy)
+		return patternExplanation
+	}
+	patternExplanation.Reason = "it matches, and it is tracked in the next monitoring period"
+	return patternExplanation
+}
+
+// findTrackingMonitoringCommand returns the monitoringCommand which tracks the process, or which would track it
+func (pim *ProcessInfoMonitor) findTrackingMonitoringCommand(processInfo ProcessInfo, matchedPatternList []string) string {
+	for _, monitoringCommand := range pim.trackedMonitoringCommandList() {
+		history := pim.processStatusHistoryByMonitoringCommand[monitoringCommand][processInfo.Pid()]
+		if len(history) != 0 && history[len(history)-1].Status() == ProcessStarted {
+			return monitoringCommand
+		}
+	}
+	if len(matchedPatternList) == 0 {
+		return ""
+	}
+	return matchedPatternList[0]
+}
@@ -0,0 +1,167 @@
+package alarm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnoreCommandList is argv[0] of commands which have patterns in their arguments without running them,
+// e.g. "grep go test", "vim go_test.go" or "alarm history --pattern make".
+// ignoreCommandList of config is added to them
+var DefaultIgnoreCommandList = []string{
+	"grep", "egrep", "fgrep", "zgrep", "rg", "ag", "ack",
+	"pgrep", "pkill", "killall", "ps", "top", "htop", "watch",
+	"less", "more", "tail", "head", "cat", "man",
+	"vi", "vim", "nvim", "view", "emacs", "emacsclient", "nano", "code",
+	"alarm",
+}
+
+// shellCommandList is shells, which run a command string given by -c in children or replace themselves by it
+var shellCommandList = []string{"sh", "bash", "zsh", "fish", "dash", "ksh", "tcsh", "csh"}
+
+// CommandNameOf is the base name of argv[0] of cmd, "-" of login shells is removed
+func CommandNameOf(cmd string) string {
+	fieldList := strings.Fields(cmd)
+	if len(fieldList) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(filepath.Base(fieldList[0]), "-")
+}
+
+// FindIgnoredReasonOfCommand returns why processes of cmd are never tracked by namePatterns,
+// because they only have namePatterns in their arguments
+func FindIgnoredReasonOfCommand(cmd string, ignoreCommandList []string) (string, bool) {
+	commandName := CommandNameOf(cmd)
+	if findNamePattern(commandName, DefaultIgnoreCommandList) || findNamePattern(commandName, ignoreCommandList) {
+		return fmt.Sprintf("%s is in ignoreCommandList", commandName), true
+	}
+	return "", false
+}
+
+// FindIgnoredReasonOfProcess returns why the process is never tracked by namePattern.
+// shell which only mentions namePattern in its command string is not tracked, e.g. sh -c "grep -q make Makefile",
+// but it is the root of the run when its descendant matches namePattern, e.g. sh -c "make build; make test"
+func FindIgnoredReasonOfProcess(processInfo ProcessInfo, processInfoList []ProcessInfo, namePattern string, ignoreCommandList []string) (string, bool) {
+	if reason, ok := FindIgnoredReasonOfCommand(processInfo.Cmd(), ignoreCommandList); ok {
+		return reason, true
+	}
+	commandString, ok := CommandStringOfShell(processInfo.Cmd())
+	if !ok || !strings.Contains(commandString, namePattern) {
+		return "", false
+	}
+	if hasMatchedDescendant(processInfo.Pid(), processInfoList, namePattern, ignoreCommandList) {
+		return "", false
+	}
+	return fmt.Sprintf("%s only mentions the pattern in its command string, and none of its descendants matches it", CommandNameOf(processInfo.Cmd())), true
+}
+
+// CommandStringOfShell returns the command string given by -c among the options of a shell.
+// options end at the script or the command string, e.g. "bash run.sh -c cfg" runs a script
+func CommandStringOfShell(cmd string) (string, bool) {
+	if !findNamePattern(CommandNameOf(cmd), shellCommandList) {
+		return "", false
+	}
+	fieldList := strings.Fields(cmd)
+	hasCommandStringOption := false
+	for i := 1; i < len(fieldList); i++ {
+		field := fieldList[i]
+		if field == "-" || field == "--" {
+			if hasCommandStringOption && i+1 < len(fieldList) {
+				return strings.Join(fieldList[i+1:], " "), true
+			}
+			return "", false
+		}
+		if strings.HasPrefix(field, "--") {
+			// long options of bash which take an argument
+			if field == "--rcfile" || field == "--init-file" {
+				i++
+			}
+			continue
+		}
+		if !strings.HasPrefix(field, "-") && !strings.HasPrefix(field, "+") {
+			if hasCommandStringOption {
+				return strings.Join(fieldList[i:], " "), true
+			}
+			return "", false
+		}
+		if strings.HasPrefix(field, "-") && strings.Contains(field, "c") {
+			hasCommandStringOption = true
+		}
+		// -o and -O take the name of an option
+		if strings.ContainsAny(field, "oO") {
+			i++
+		}
+	}
+	return "", false
+}
+
+// hasMatchedDescendant reports whether a descendant of pid matches namePattern and it is not ignored
+func hasMatchedDescendant(pid int, processInfoList []ProcessInfo, namePattern string, ignoreCommandList []string) bool {
+	for i := range processInfoList {
+		processInfo := processInfoList[i]
+		if processInfo.Ppid() != pid || processInfo.Pid() == pid {
+			continue
+		}
+		if strings.Contains(processInfo.Cmd(), namePattern) {
+			if _, ok := FindIgnoredReasonOfProcess(processInfo, processInfoList, namePattern, ignoreCommandList); !ok {
+				return true
+			}
+		}
+		if hasMatchedDescendant(processInfo.Pid(), processInfoList, namePattern, ignoreCommandList) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDaemonPid excludes the daemon and its descendants, which have namePatterns in their arguments or environment.
+// 0 disables it, e.g. for tests which run processes from the monitoring process
+func (pim *ProcessInfoMonitor) SetDaemonPid(daemonPid int) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	pim.daemonPid = daemonPid
+}
+
+// SetIgnoreCommandList adds argv[0] of commands which are never tracked by namePatterns to DefaultIgnoreCommandList
+func (pim *ProcessInfoMonitor) SetIgnoreCommandList(ignoreCommandList []string) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	pim.ignoreCommandList = append([]string{}, ignoreCommandList...)
+}
+
+// findExcludedReason returns why the process is never tracked by any monitoringCommand
+func (pim *ProcessInfoMonitor) findExcludedReason(processInfo ProcessInfo) (string, bool) {
+	if pim.daemonPid == 0 {
+		return "", false
+	}
+	if processInfo.Pid() == pim.daemonPid {
+		return "it is the daemon", true
+	}
+	isVisitedByPid := map[int]bool{}
+	for ppid := processInfo.Ppid(); ppid > 1 && !isVisitedByPid[ppid]; {
+		if ppid == pim.daemonPid {
+			return "it is a descendant of the daemon", true
+		}
+		isVisitedByPid[ppid] = true
+		parentProcessInfo := pim.processInfoReader.findProcessInfoByPid(ppid)
+		ppid = parentProcessInfo.Ppid()
+	}
+	return "", false
+}
+
+// findIgnoredReason returns why the process is never tracked by namePatterns
+func (pim *ProcessInfoMonitor) findIgnoredReason(processInfo ProcessInfo) (string, bool) {
+	if reason, ok := pim.findExcludedReason(processInfo); ok {
+		return reason, true
+	}
+	return FindIgnoredReasonOfCommand(processInfo.Cmd(), pim.ignoreCommandList)
+}
+
+// findIgnoredReasonByNamePattern returns why the process is never tracked by namePattern
+func (pim *ProcessInfoMonitor) findIgnoredReasonByNamePattern(processInfo ProcessInfo, namePattern string) (string, bool) {
+	if reason, ok := pim.findExcludedReason(processInfo); ok {
+		return reason, true
+	}
+	return FindIgnoredReasonOfProcess(processInfo, pim.processInfoReader.GetProcessInfoList(), namePattern, pim.ignoreCommandList)
+}
@@ -0,0 +1,104 @@
+package alarm
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessFilter(t *testing.T) {
+	t.Run("IgnoredReasonOfCommand", CheckIgnoredReasonOfCommand())
+	t.Run("CommandStringOfShell", CheckCommandStringOfShell())
+	t.Run("IgnoredProcess", CheckIgnoredProcess())
+}
+
+func CheckIgnoredReasonOfCommand() func(*testing.T) {
+	return func(t *testing.T) {
+		reason, ok := FindIgnoredReasonOfCommand("/usr/bin/grep go test", []string{})
+		require.True(t, ok)
+		require.Equal(t, "grep is in ignoreCommandList", reason)
+
+		_, ok = FindIgnoredReasonOfCommand("jq .make config.json", []string{})
+		require.False(t, ok)
+		_, ok = FindIgnoredReasonOfCommand("jq .make config.json", []string{"jq"})
+		require.True(t, ok)
+
+		// scripts run by shells are tracked
+		_, ok = FindIgnoredReasonOfCommand("bash build.sh", []string{})
+		require.False(t, ok)
+	}
+}
+
+func CheckCommandStringOfShell() func(*testing.T) {
+	return func(t *testing.T) {
+		commandString, ok := CommandStringOfShell("-bash -c make build")
+		require.True(t, ok)
+		require.Equal(t, "make build", commandString)
+
+		commandString, ok = CommandStringOfShell("/bin/bash -o pipefail -ec make build")
+		require.True(t, ok)
+		require.Equal(t, "make build", commandString)
+
+		// -c after the script is an argument of the script
+		_, ok = CommandStringOfShell("bash run.sh -c cfg")
+		require.False(t, ok)
+		_, ok = CommandStringOfShell("bash build.sh")
+		require.False(t, ok)
+		_, ok = CommandStringOfShell("python3 -c print(1)")
+		require.False(t, ok)
+	}
+}
+
+func CheckIgnoredProcess() func(*testing.T) {
+	return func(t *testing.T) {
+		pim := NewProcessInfoMonitor(
+			[]string{"sleep 1.7531"},
+		)
+		defer pim.Stop()
+
+		// the shell only mentions the pattern in its command string, so it is not tracked
+		c := exec.Command("sh", "-c", "echo sleep 1.7531 > /dev/null; sleep 1.5; true")
+		require.NoError(t, c.Start())
+		time.Sleep(3 * defaultPeriod)
+		require.Empty(t, pim.GetProcessStatusLogByMonitoringCommand("sleep 1.7531"))
+
+		processExplanation, err := pim.ExplainPid(c.Process.Pid)
+		require.NoError(t, err)
+		require.Empty(t, processExplanation.IgnoredReason)
+		require.Equal(t, 1, len(processExplanation.PatternExplanationList))
+		require.False(t, processExplanation.PatternExplanationList[0].IsTracked)
+		require.Equal(t, "sh only mentions the pattern in its command string, and none of its descendants matches it", processExplanation.PatternExplanationList[0].Reason)
+		c.Wait()
+
+		// the shell runs the matched command in a child, so the shell is the root of the run
+		c = exec.Command("sh", "-c", "sleep 1.7531; true")
+		require.NoError(t, c.Start())
+		time.Sleep(3 * defaultPeriod)
+		wholeProcessStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("sleep 1.7531")
+		require.Equal(t, 1, len(wholeProcessStatusHistory))
+		require.Contains(t, wholeProcessStatusHistory, c.Process.Pid)
+
+		processExplanation, err = pim.ExplainPid(c.Process.Pid)
+		require.NoError(t, err)
+		require.True(t, processExplanation.PatternExplanationList[0].IsTracked)
+		c.Wait()
+
+		// processes run by the daemon are excluded
+		pim.SetDaemonPid(os.Getpid())
+		c = exec.Command("sleep", "1.7531")
+		require.NoError(t, c.Start())
+		time.Sleep(3 * defaultPeriod)
+		require.NotContains(t, pim.GetProcessStatusLogByMonitoringCommand("sleep 1.7531"), c.Process.Pid)
+		processExplanation, err = pim.ExplainPid(c.Process.Pid)
+		require.NoError(t, err)
+		require.Equal(t, "it is a descendant of the daemon", processExplanation.PatternExplanationList[0].Reason)
+		c.Wait()
+
+		time.Sleep(3 * defaultPeriod)
+		_, err = pim.ExplainPid(c.Process.Pid)
+		require.Error(t, err)
+	}
+}
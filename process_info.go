@@ -8,12 +8,28 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type ProcessInfo struct {
 	cmd            string
 	pid            int
+	ppid           int
 	binaryLocation string
+	startTime      time.Time
+	state          string
+	// cpuTime is user and system time spent by the process itself, without its children
+	cpuTime time.Duration
+	// memory is resident set size in bytes
+	memory uint64
+	// exitCode is wait status of zombie process
+	exitCode int
+	// alarmEnvironment is environment variables starting with EnvironmentMarkerPrefix
+	alarmEnvironment map[string]string
+	// matchedPatternList is monitoringCommands which matched the process when it was started, in the order of priority
+	matchedPatternList []string
 }
 
 func (pi *ProcessInfo) Cmd() (cmd string) {
@@ -28,6 +44,71 @@ func (pi *ProcessInfo) BinaryLocation() (binaryLocation string) {
 	return pi.binaryLocation
 }
 
+func (pi *ProcessInfo) Ppid() (ppid int) {
+	return pi.ppid
+}
+
+// MatchedPatternList returns monitoringCommands which matched the process when it was started.
+// the first one has the highest priority by patternMatchPolicy
+func (pi *ProcessInfo) MatchedPatternList() []string {
+	return pi.matchedPatternList
+}
+
+// AlarmEnvironmentVariable returns environment variable of the process starting with EnvironmentMarkerPrefix,
+// e.g. ALARM_ME or ALARM_LABEL
+func (pi *ProcessInfo) AlarmEnvironmentVariable(name string) (string, bool) {
+	val, ok := pi.alarmEnvironment[name]
+	return val, ok
+}
+
+// IsMarkedByEnvironment reports whether the process opts in by environment marker, e.g. ALARM_ME=1.
+// "0", "false" and "no" opt out
+func (pi *ProcessInfo) IsMarkedByEnvironment(marker string) bool {
+	if marker == "" {
+		return false
+	}
+	val, ok := pi.alarmEnvironment[marker]
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(val) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// StartTime is when the process started, it tells a process from another one which reuses its pid
+func (pi *ProcessInfo) StartTime() (startTime time.Time) {
+	return pi.startTime
+}
+
+// IsZombie reports whether the process is finished but not waited by its parent yet
+func (pi *ProcessInfo) IsZombie() bool {
+	return pi.state == zombieProcessState
+}
+
+func (pi *ProcessInfo) CpuTime() (cpuTime time.Duration) {
+	return pi.cpuTime
+}
+
+func (pi *ProcessInfo) Memory() (memory uint64) {
+	return pi.memory
+}
+
+// ExitCode is exit code of zombie process, signaled process has 128 + signal number like shells
+func (pi *ProcessInfo) ExitCode() (exitCode int) {
+	return exitCodeOfWaitStatus(pi.exitCode)
+}
+
+func exitCodeOfWaitStatus(waitStatus int) int {
+	status := syscall.WaitStatus(waitStatus)
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
 func GetProcessInfoList() ([]ProcessInfo, error) {
 	d, err := os.Open("/proc")
 	if err != nil {
@@ -62,15 +143,23 @@ func GetProcessInfoList() ([]ProcessInfo, error) {
 				continue
 			}
 
-			binaryLocation, err := getBinaryLocation(pid)
+			stat, err := getStatOfProcessByPid(pid)
 			if err != nil {
 				continue
 			}
 
-			p, err := newProcessInfo(cmd, pid, binaryLocation)
+			// environment of zombie can not be read, but its exit code is needed
+			environ, err := getEnvironOfProcessByPid(pid)
+			if err != nil && stat.state != zombieProcessState {
+				continue
+			}
+
+			p, err := newProcessInfo(cmd, pid, environ[binaryLocationEnvironmentVariable])
 			if err != nil {
 				continue
 			}
+			p.setStat(stat)
+			p.setAlarmEnvironment(environ)
 
 			results = append(results, p)
 		}
@@ -92,19 +181,171 @@ func getCmdOfProcessByPid(pid int) (string, error) {
 	return cmd, nil
 }
 
+// GetStartTimeOfProcessByPid reads start time of process from /proc/<pid>/stat
+func GetStartTimeOfProcessByPid(pid int) (time.Time, error) {
+	stat, err := getStatOfProcessByPid(pid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.startTime, nil
+}
+
+type processStat struct {
+	state     string
+	ppid      int
+	startTime time.Time
+	cpuTime   time.Duration
+	memory    uint64
+	exitCode  int
+}
+
+// zombieProcessState is state of process which finished but is not waited by its parent yet
+const zombieProcessState = "Z"
+
+func getStatOfProcessByPid(pid int) (processStat, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return processStat{}, err
+	}
+	// command name in parentheses can contain spaces and parentheses
+	fieldList := strings.Fields(string(data[bytes.LastIndexByte(data, ')')+1:]))
+	// fieldList starts from 3rd field, state. starttime is 22nd field, which is written in clock ticks after boot
+	if len(fieldList) < 20 {
+		return processStat{}, fmt.Errorf("stat of process %d is too short", pid)
+	}
+	ppid, err := strconv.Atoi(fieldList[1])
+	if err != nil {
+		return processStat{}, err
+	}
+	startTicks, err := strconv.ParseInt(fieldList[19], 10, 64)
+	if err != nil {
+		return processStat{}, err
+	}
+	bootTime, err := getBootTime()
+	if err != nil {
+		return processStat{}, err
+	}
+	// utime and stime are 14th and 15th fields. cutime and cstime of waited children are not added,
+	// otherwise time of a process would be counted again in every ancestor
+	cpuTicks := int64(0)
+	for _, field := range fieldList[11:13] {
+		ticks, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return processStat{}, err
+		}
+		cpuTicks += ticks
+	}
+	// rss is 24th field, which is written in pages
+	rssPages, err := strconv.ParseUint(fieldList[21], 10, 64)
+	if err != nil {
+		return processStat{}, err
+	}
+	// exit_code is 52nd field, which is added in Linux 3.5. it can be read only while the process is a zombie
+	exitCode := 0
+	if len(fieldList) > 49 {
+		exitCode, _ = strconv.Atoi(fieldList[49])
+	}
+	return processStat{
+		state:     fieldList[0],
+		ppid:      ppid,
+		startTime: bootTime.Add(time.Duration(startTicks) * time.Second / clockTicksPerSecond),
+		cpuTime:   time.Duration(cpuTicks) * time.Second / clockTicksPerSecond,
+		memory:    rssPages * uint64(os.Getpagesize()),
+		exitCode:  exitCode,
+	}, nil
+}
+
+// clockTicksPerSecond is USER_HZ, which is 100 on every architecture Linux supports
+const clockTicksPerSecond = 100
+
+var (
+	bootTime         time.Time
+	mutexForBootTime sync.Mutex
+)
+
+// getBootTime reads btime of /proc/stat once, it doesn't change until reboot
+func getBootTime() (time.Time, error) {
+	mutexForBootTime.Lock()
+	defer mutexForBootTime.Unlock()
+	if !bootTime.IsZero() {
+		return bootTime, nil
+	}
+	data, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "btime ") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "btime ")), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		bootTime = time.Unix(seconds, 0)
+		return bootTime, nil
+	}
+	return time.Time{}, fmt.Errorf("btime is not found in /proc/stat")
+}
+
+// binaryLocationEnvironmentVariable is working directory of shell which started the process
+const binaryLocationEnvironmentVariable = "PWD"
+
 func getBinaryLocation(pid int) (string, error) {
-	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	environ, err := getEnvironOfProcessByPid(pid)
 	if err != nil {
 		return "", err
 	}
-	environ := strings.Split(string(data), string(byte(0)))
-	binaryLocation := ""
-	for _, env := range environ {
-		if strings.Contains(env, "PWD") {
-			binaryLocation = strings.Split(env, "=")[1]
+	return environ[binaryLocationEnvironmentVariable], nil
+}
+
+// getEnvironOfProcessByPid reads only environment variables which are used,
+// PWD and variables starting with EnvironmentMarkerPrefix
+func getEnvironOfProcessByPid(pid int) (map[string]string, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, err
+	}
+	environ := map[string]string{}
+	for _, env := range strings.Split(string(data), string(byte(0))) {
+		nameAndValue := strings.SplitN(env, "=", 2)
+		if len(nameAndValue) != 2 {
+			continue
+		}
+		name := nameAndValue[0]
+		if name == binaryLocationEnvironmentVariable || strings.HasPrefix(name, EnvironmentMarkerPrefix) {
+			environ[name] = nameAndValue[1]
+		}
+	}
+	return environ, nil
+}
+
+func (pi *ProcessInfo) setStat(stat processStat) {
+	pi.ppid = stat.ppid
+	pi.startTime = stat.startTime
+	pi.state = stat.state
+	pi.cpuTime = stat.cpuTime
+	pi.memory = stat.memory
+	pi.exitCode = stat.exitCode
+}
+
+func (pi *ProcessInfo) setMatchedPatternList(matchedPatternList []string) {
+	pi.matchedPatternList = nil
+	if len(matchedPatternList) != 0 {
+		pi.matchedPatternList = append([]string{}, matchedPatternList...)
+	}
+}
+
+func (pi *ProcessInfo) setAlarmEnvironment(environ map[string]string) {
+	for name, val := range environ {
+		if !strings.HasPrefix(name, EnvironmentMarkerPrefix) {
+			continue
+		}
+		if pi.alarmEnvironment == nil {
+			pi.alarmEnvironment = map[string]string{}
 		}
+		pi.alarmEnvironment[name] = val
 	}
-	return binaryLocation, nil
 }
 
 func newProcessInfo(cmd string, pid int, binaryLocation string) (newProcessInfo ProcessInfo, err error) {
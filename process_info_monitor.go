@@ -1,12 +1,34 @@
 package alarm
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 type ProcessInfoMonitor struct {
-	monitoringCommandList                   []string
+	monitoringCommandList []string
+	// projectMonitoringCommandList is namePatterns of project configs, which are found in working directory of processes.
+	// they are applied only to processes in the project, and are kept tracking while running processes have them
+	projectMonitoringCommandList []string
+	// watchedProcessByMonitoringCommand is processes watched by pid instead of namePattern,
+	// their history is kept under WatchedPidMonitoringCommand(pid)
+	watchedProcessByMonitoringCommand map[string]ProcessInfo
+	// environmentMarker opts processes in, only the root of processes which have it is tracked
+	// because children inherit environment variables
+	environmentMarker string
+	// patternMatchPolicy chooses which monitoringCommand tracks a process matched by several ones
+	patternMatchPolicy string
+	// daemonPid is the process of the daemon, it and its descendants are never tracked
+	daemonPid int
+	// ignoreCommandList is argv[0] of commands which are never tracked by namePatterns, in addition to DefaultIgnoreCommandList
+	ignoreCommandList []string
+	// processTreeByRootPid is jobs of started processes, a process and its descendants are one run
+	processTreeByRootPid map[int]*ProcessTree
+	// processExitListener observes exit codes of processes, it is nil if proc connector is not available
+	processExitListener                     *ProcessExitListener
 	processStatusHistoryByMonitoringCommand map[string](map[int]([]ProcessStatus))
 	monitoringPeriod                        time.Duration
 	start                                   bool
@@ -16,7 +38,8 @@ type ProcessInfoMonitor struct {
 
 	now time.Time
 
-	processInfoReader *ProcessInfoReader
+	processInfoReader   *ProcessInfoReader
+	projectConfigFinder *ProjectConfigFinder
 }
 
 func NewProcessInfoMonitor(monitoringCommandList []string) *ProcessInfoMonitor {
@@ -29,8 +52,11 @@ func NewProcessInfoMonitor(monitoringCommandList []string) *ProcessInfoMonitor {
 }
 
 func (pim *ProcessInfoMonitor) Init() {
+	pim.watchedProcessByMonitoringCommand = map[string]ProcessInfo{}
+	pim.processTreeByRootPid = map[int]*ProcessTree{}
 	pim.processStatusHistoryByMonitoringCommand = map[string](map[int]([]ProcessStatus)){}
 	pim.processInfoReader = NewProcessInfoReader()
+	pim.patternMatchPolicy = MostSpecificPatternMatchPolicy
 	pim.SetPeriod(defaultPeriod)
 }
 
@@ -46,6 +72,14 @@ func (pim *ProcessInfoMonitor) Start() {
 	pim.start = true
 
 	pim.processInfoReader.Start()
+	processExitListener, err := NewProcessExitListener()
+	if err != nil {
+		errMsg := fmt.Sprintf("error occured during listening exits of processes, exit codes are found only from zombie processes: %v", err)
+		fmt.Println(errMsg)
+	}
+	pim.mutexForProcessStatusHistory.Lock()
+	pim.processExitListener = processExitListener
+	pim.mutexForProcessStatusHistory.Unlock()
 	go func() {
 		for {
 			if !pim.start {
@@ -54,16 +88,157 @@ func (pim *ProcessInfoMonitor) Start() {
 
 			pim.now = time.Now()
 			pim.updateTargetProcessStatusHistory()
+			time.Sleep(pim.monitoringPeriod)
 		}
 	}()
 }
 
 func (pim *ProcessInfoMonitor) updateTargetProcessStatusHistory() {
-	for _, namePattern := range pim.monitoringCommandList {
+	pim.updateProjectMonitoringCommandList()
+	pim.updateProcessTreeMap()
+	for _, namePattern := range pim.GetTrackedMonitoringCommandList() {
 		pim.updateProcessStatusHistoryByMonitoringCommand(namePattern)
 	}
 }
 
+// updateProjectMonitoringCommandList finds namePatterns of project configs again, so edited or removed ones are dropped.
+// dropped namePattern is kept until its processes are finished and released, then its history is released too
+func (pim *ProcessInfoMonitor) updateProjectMonitoringCommandList() {
+	if pim.projectConfigFinder == nil {
+		return
+	}
+	foundNamePatternList := []string{}
+	for _, processInfo := range pim.processInfoReader.GetProcessInfoList() {
+		projectConfig, ok := pim.projectConfigFinder.Find(processInfo.BinaryLocation())
+		if !ok {
+			continue
+		}
+		for _, namePattern := range projectConfig.MonitoringCommandList {
+			if !findNamePattern(namePattern, foundNamePatternList) {
+				foundNamePatternList = append(foundNamePatternList, namePattern)
+			}
+		}
+	}
+
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	projectMonitoringCommandList := []string{}
+	for _, namePattern := range pim.projectMonitoringCommandList {
+		if findNamePattern(namePattern, foundNamePatternList) || len(pim.processStatusHistoryByMonitoringCommand[namePattern]) != 0 {
+			projectMonitoringCommandList = append(projectMonitoringCommandList, namePattern)
+			continue
+		}
+		if !findNamePattern(namePattern, pim.monitoringCommandList) {
+			delete(pim.processStatusHistoryByMonitoringCommand, namePattern)
+		}
+	}
+	pim.projectMonitoringCommandList = projectMonitoringCommandList
+	for _, namePattern := range foundNamePatternList {
+		pim.addProjectNamePattern(namePattern)
+	}
+}
+
+// addProjectNamePattern tracks namePattern of project config, history is shared with monitoringCommandList
+func (pim *ProcessInfoMonitor) addProjectNamePattern(namePattern string) {
+	if findNamePattern(namePattern, pim.projectMonitoringCommandList) {
+		return
+	}
+	pim.projectMonitoringCommandList = append(pim.projectMonitoringCommandList, namePattern)
+	if _, ok := pim.processStatusHistoryByMonitoringCommand[namePattern]; !ok {
+		pim.processStatusHistoryByMonitoringCommand[namePattern] = map[int]([]ProcessStatus){}
+	}
+}
+
+// updateProcessTreeMap follows descendants of started processes.
+// trees finished in the previous period are released because every monitoringCommand has reported them
+func (pim *ProcessInfoMonitor) updateProcessTreeMap() {
+	processInfoList := pim.processInfoReader.GetProcessInfoList()
+
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	for rootPid, processTree := range pim.processTreeByRootPid {
+		if !processTree.IsRunning() {
+			delete(pim.processTreeByRootPid, rootPid)
+		}
+	}
+	for _, processStatusHistory := range pim.processStatusHistoryByMonitoringCommand {
+		for _, history := range processStatusHistory {
+			latestProcessStatus := history[len(history)-1]
+			if latestProcessStatus.Status() == ProcessStarted {
+				pim.addProcessTree(latestProcessStatus.ProcessInfo())
+			}
+		}
+	}
+	for _, processTree := range pim.processTreeByRootPid {
+		processTree.update(processInfoList, pim.processExitListener)
+	}
+}
+
+// addProcessTree starts following descendants of the started process
+func (pim *ProcessInfoMonitor) addProcessTree(rootProcessInfo ProcessInfo) *ProcessTree {
+	processTree, ok := pim.processTreeByRootPid[rootProcessInfo.Pid()]
+	if ok && processTree.rootStartTime.Equal(rootProcessInfo.StartTime()) {
+		return processTree
+	}
+	processTree = newProcessTree(rootProcessInfo)
+	pim.processTreeByRootPid[rootProcessInfo.Pid()] = processTree
+	return processTree
+}
+
+// isDescendantOfTrackedProcess reports whether the process is a part of a job which is already tracked by the namePattern,
+// or a descendant of another process in pidList which is found at the same time.
+// ancestors are followed by ppid, so forked child which has the same command as its tracked parent is not tracked again
+func (pim *ProcessInfoMonitor) isDescendantOfTrackedProcess(processInfo ProcessInfo, processStatusHistory map[int]([]ProcessStatus), pidList []int) bool {
+	if _, ok := pim.findRootPidOfTrackedProcess(processInfo, processStatusHistory); ok {
+		return true
+	}
+	isVisitedByPid := map[int]bool{}
+	for ppid := processInfo.Ppid(); ppid > 1 && !isVisitedByPid[ppid]; {
+		if findPidInPidList(ppid, pidList) {
+			return true
+		}
+		isVisitedByPid[ppid] = true
+		parentProcessInfo := pim.processInfoReader.findProcessInfoByPid(ppid)
+		if history, ok := processStatusHistory[ppid]; ok {
+			latestProcessStatus := history[len(history)-1]
+			trackedProcessInfo := latestProcessStatus.ProcessInfo()
+			if latestProcessStatus.Status() == ProcessStarted && trackedProcessInfo.StartTime().Equal(parentProcessInfo.StartTime()) {
+				return true
+			}
+		}
+		ppid = parentProcessInfo.Ppid()
+	}
+	return false
+}
+
+// findRootPidOfTrackedProcess finds the root of the job which the process is a part of, among the processes tracked by a namePattern
+func (pim *ProcessInfoMonitor) findRootPidOfTrackedProcess(processInfo ProcessInfo, processStatusHistory map[int]([]ProcessStatus)) (int, bool) {
+	for rootPid, history := range processStatusHistory {
+		if rootPid == processInfo.Pid() || history[len(history)-1].Status() != ProcessStarted {
+			continue
+		}
+		processTree, ok := pim.processTreeByRootPid[rootPid]
+		if ok && processTree.IsRunning() && processTree.HasMember(processInfo) {
+			return rootPid, true
+		}
+	}
+	return 0, false
+}
+
+// isMonitoredByNamePattern reports whether process is monitored by namePattern.
+// namePattern of project config is applied only to processes in the project
+func (pim *ProcessInfoMonitor) isMonitoredByNamePattern(pid int, namePattern string) bool {
+	if findNamePattern(namePattern, pim.monitoringCommandList) {
+		return true
+	}
+	if pim.projectConfigFinder == nil {
+		return false
+	}
+	processInfo := pim.processInfoReader.findProcessInfoByPid(pid)
+	projectConfig, ok := pim.projectConfigFinder.Find(processInfo.BinaryLocation())
+	return ok && findNamePattern(namePattern, projectConfig.MonitoringCommandList)
+}
+
 func (pim *ProcessInfoMonitor) updateProcessStatusHistoryByMonitoringCommand(namePattern string) {
 	changedProcessStatusMap := pim.getChangedProcessStatus(namePattern)
 	if len(changedProcessStatusMap) == 0 {
@@ -71,11 +246,21 @@ func (pim *ProcessInfoMonitor) updateProcessStatusHistoryByMonitoringCommand(nam
 	}
 	pim.mutexForProcessStatusHistory.Lock()
 	defer pim.mutexForProcessStatusHistory.Unlock()
+	processStatusHistory, ok := pim.processStatusHistoryByMonitoringCommand[namePattern]
+	if !ok {
+		// namePattern is removed from monitoringCommandList in the meantime
+		return
+	}
+	processInfoList := pim.processInfoReader.GetProcessInfoList()
 	for pid, changedProcessStatus := range changedProcessStatusMap {
-		pim.processStatusHistoryByMonitoringCommand[namePattern][pid] = append(
-			pim.processStatusHistoryByMonitoringCommand[namePattern][pid],
+		processStatusHistory[pid] = append(
+			processStatusHistory[pid],
 			changedProcessStatus,
 		)
+		if changedProcessStatus.Status() == ProcessStarted {
+			// descendants which are found at the same time belong to the process from the first
+			pim.addProcessTree(changedProcessStatus.ProcessInfo()).update(processInfoList, pim.processExitListener)
+		}
 	}
 }
 
@@ -86,17 +271,94 @@ func (pim *ProcessInfoMonitor) getChangedProcessStatus(namePattern string) map[i
 	defer pim.mutexForProcessStatusHistory.Unlock()
 	processStatusHistory := pim.processStatusHistoryByMonitoringCommand[namePattern]
 
-	pidList := pim.processInfoReader.GetPidListByName(namePattern)
+	if watchedProcessInfo, ok := pim.watchedProcessByMonitoringCommand[namePattern]; ok {
+		latestProcessStatus := pim.getUpdatedProcessStatusOfWatchedProcess(processStatusHistory, watchedProcessInfo)
+		if latestProcessStatus.Status() != "" {
+			changedProcessStatusHistory[watchedProcessInfo.Pid()] = latestProcessStatus
+		}
+		return changedProcessStatusHistory
+	}
+
+	// processes which already finished are not found by name any more,
+	// so started processes in history are checked too
+	matchedPidList := []int{}
+	markedPidList := []int{}
+	if pim.environmentMarker != "" {
+		markedPidList = pim.getPidListMarkedByEnvironment()
+	}
+	if pim.isEnvironmentMarkerMonitoringCommand(namePattern) {
+		matchedPidList = markedPidList
+	} else {
+		for _, pid := range pim.processInfoReader.GetPidListByName(namePattern) {
+			if _, ok := pim.findIgnoredReasonByNamePattern(pim.processInfoReader.findProcessInfoByPid(pid), namePattern); ok {
+				continue
+			}
+			if pim.isMonitoredByNamePattern(pid, namePattern) {
+				matchedPidList = append(matchedPidList, pid)
+			}
+		}
+	}
+	// descendants are parts of the run of their root, e.g. compilers spawned by make,
+	// and process matched by several monitoringCommands is tracked by the one chosen by patternMatchPolicy
+	pidList := []int{}
+	matchedPatternListByPid := map[int][]string{}
+	for _, pid := range matchedPidList {
+		processInfo := pim.processInfoReader.findProcessInfoByPid(pid)
+		if pim.isDescendantOfTrackedProcess(processInfo, processStatusHistory, matchedPidList) {
+			continue
+		}
+		matchedPatternListByPid[pid] = pim.findMatchedPatternList(processInfo, markedPidList)
+		mostRecentProcessStatus := findProcessStatusInHistory(processStatusHistory, pid)
+		if mostRecentProcessStatus.Status() != ProcessStarted &&
+			!pim.isTrackedByNamePattern(namePattern, processInfo, matchedPatternListByPid[pid]) {
+			continue
+		}
+		pidList = append(pidList, pid)
+	}
+	for pid, history := range processStatusHistory {
+		if history[len(history)-1].Status() != ProcessStarted || findPidInPidList(pid, pidList) {
+			continue
+		}
+		pidList = append(pidList, pid)
+	}
 	for _, pid := range pidList {
 		latestProcessStatus := pim.getUpdatedProcessStatusInLogWithTimestamp(processStatusHistory, pid)
-		if (latestProcessStatus == ProcessStatus{}) {
+		if latestProcessStatus.Status() == "" {
 			continue
 		}
+		if latestProcessStatus.Status() == ProcessStarted {
+			processInfo := latestProcessStatus.ProcessInfo()
+			processInfo.setMatchedPatternList(matchedPatternListByPid[pid])
+			latestProcessStatus.SetProcessInfo(processInfo)
+		}
 		changedProcessStatusHistory[pid] = latestProcessStatus
 	}
 	return changedProcessStatusHistory
 }
 
+// getPidListMarkedByEnvironment finds processes which have environment marker while their parents don't have it.
+// children of a marked process are part of its run, and the daemon is not tracked even if it is marked
+func (pim *ProcessInfoMonitor) getPidListMarkedByEnvironment() []int {
+	processInfoList := pim.processInfoReader.GetProcessInfoList()
+	isMarkedByPid := map[int]bool{}
+	for _, processInfo := range processInfoList {
+		if processInfo.IsMarkedByEnvironment(pim.environmentMarker) {
+			isMarkedByPid[processInfo.Pid()] = true
+		}
+	}
+	pidList := []int{}
+	for _, processInfo := range processInfoList {
+		if !isMarkedByPid[processInfo.Pid()] || isMarkedByPid[processInfo.Ppid()] {
+			continue
+		}
+		if _, ok := pim.findExcludedReason(processInfo); ok {
+			continue
+		}
+		pidList = append(pidList, processInfo.Pid())
+	}
+	return pidList
+}
+
 func (pim *ProcessInfoMonitor) getUpdatedProcessStatusInLogWithTimestamp(processStatusHistory map[int]([]ProcessStatus), pid int) ProcessStatus {
 	isExecuting := pim.processInfoReader.IsExecuting(pid)
 	if isExecuting {
@@ -104,21 +366,203 @@ func (pim *ProcessInfoMonitor) getUpdatedProcessStatusInLogWithTimestamp(process
 		mostRecentProcessStatus := findProcessStatusInHistory(processStatusHistory, pid)
 		if mostRecentProcessStatus.Status() == ProcessFinished ||
 			mostRecentProcessStatus.Status() == ProcessNeverStarted {
-			return NewProcessStatus(pid, ProcessStarted)
+			processStatus := NewProcessStatus(pid, ProcessStarted)
+			processStatus.SetProcessInfo(pim.processInfoReader.findProcessInfoByPid(pid))
+			return processStatus
 		}
 	} else {
 		// process is finshed after start
 		mostRecentProcessStatus := findProcessStatusInHistory(processStatusHistory, pid)
 		if mostRecentProcessStatus.Status() == ProcessStarted {
-			return NewProcessStatus(pid, ProcessFinished)
+			return pim.getFinishedProcessStatus(pid, mostRecentProcessStatus.ProcessInfo())
 		}
 	}
 	return ProcessStatus{}
 }
 
+// getUpdatedProcessStatusOfWatchedProcess finds out whether watched process is finished.
+// process which has same pid but different start time is another process which reuses the pid,
+// and zombie process is finished already
+func (pim *ProcessInfoMonitor) getUpdatedProcessStatusOfWatchedProcess(processStatusHistory map[int]([]ProcessStatus), watchedProcessInfo ProcessInfo) ProcessStatus {
+	pid := watchedProcessInfo.Pid()
+	mostRecentProcessStatus := findProcessStatusInHistory(processStatusHistory, pid)
+	if mostRecentProcessStatus.Status() != ProcessStarted {
+		return ProcessStatus{}
+	}
+	stat, err := getStatOfProcessByPid(pid)
+	if err == nil && stat.state != zombieProcessState && stat.startTime.Equal(watchedProcessInfo.StartTime()) {
+		return ProcessStatus{}
+	}
+	return pim.getFinishedProcessStatus(pid, watchedProcessInfo)
+}
+
+// getFinishedProcessStatus returns ProcessFinished with summary of the tree when all descendants are finished too.
+// process is not found any more, so info of started process is used
+func (pim *ProcessInfoMonitor) getFinishedProcessStatus(pid int, processInfo ProcessInfo) ProcessStatus {
+	processTree, ok := pim.processTreeByRootPid[pid]
+	if ok && processTree.IsRunning() {
+		return ProcessStatus{}
+	}
+	processStatus := NewProcessStatus(pid, ProcessFinished)
+	processStatus.SetProcessInfo(processInfo)
+	if ok {
+		processStatus.SetProcessTreeSummary(processTree.Summary())
+	}
+	return processStatus
+}
+
+// WatchPid tracks the running process by its pid instead of namePattern.
+// its history is kept under the returned monitoringCommand until UnwatchPid is called,
+// start time of the process is used as timestamp of ProcessStarted
+func (pim *ProcessInfoMonitor) WatchPid(pid int) (string, error) {
+	stat, err := getStatOfProcessByPid(pid)
+	if err != nil || stat.state == zombieProcessState {
+		return "", fmt.Errorf("process %d is not running", pid)
+	}
+	cmd, err := getCmdOfProcessByPid(pid)
+	if err != nil {
+		return "", fmt.Errorf("process %d is not running", pid)
+	}
+	// environment can not be read for processes of other users
+	environ, _ := getEnvironOfProcessByPid(pid)
+	watchedProcessInfo, _ := newProcessInfo(cmd, pid, environ[binaryLocationEnvironmentVariable])
+	watchedProcessInfo.setStat(stat)
+	watchedProcessInfo.setAlarmEnvironment(environ)
+
+	processStatus := NewProcessStatus(pid, ProcessStarted)
+	processStatus.SetTimestamp(stat.startTime)
+	processStatus.SetProcessInfo(watchedProcessInfo)
+
+	monitoringCommand := WatchedPidMonitoringCommand(pid)
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	pim.watchedProcessByMonitoringCommand[monitoringCommand] = watchedProcessInfo
+	watchedProcessInfo.setMatchedPatternList(pim.findMatchedPatternList(watchedProcessInfo, pim.getPidListMarkedByEnvironment()))
+	processStatus.SetProcessInfo(watchedProcessInfo)
+	pim.addProcessTree(watchedProcessInfo).update(pim.processInfoReader.GetProcessInfoList(), pim.processExitListener)
+	pim.processStatusHistoryByMonitoringCommand[monitoringCommand] = map[int]([]ProcessStatus){
+		pid: []ProcessStatus{processStatus},
+	}
+	return monitoringCommand, nil
+}
+
+// RestoreRun tracks again the run which was running when the daemon stopped, history of the daemon before restart.
+// the run is finished at once if its process is not running any more,
+// it returns false if monitoringCommand of the run is not tracked
+func (pim *ProcessInfoMonitor) RestoreRun(run Run) bool {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	processStatusHistory, ok := pim.processStatusHistoryByMonitoringCommand[run.MonitoringCommand]
+	if !ok && pim.projectConfigFinder != nil {
+		// namePatterns of project configs are tracked after processes in the project are found
+		projectConfig, found := pim.projectConfigFinder.Find(run.Directory)
+		if found && findNamePattern(run.MonitoringCommand, projectConfig.MonitoringCommandList) {
+			pim.addProjectNamePattern(run.MonitoringCommand)
+			processStatusHistory = pim.processStatusHistoryByMonitoringCommand[run.MonitoringCommand]
+			ok = true
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	processInfo, _ := newProcessInfo(run.Command, run.Pid, run.Directory)
+	processInfo.startTime = run.ProcessStartTime
+	processInfo.setAlarmEnvironment(run.AlarmEnvironment)
+	processInfo.setMatchedPatternList(run.MatchedPatternList)
+	startedProcessStatus := NewProcessStatus(run.Pid, ProcessStarted)
+	startedProcessStatus.SetTimestamp(run.StartedAt)
+
+	history := processStatusHistory[run.Pid]
+	stat, err := getStatOfProcessByPid(run.Pid)
+	if err == nil && stat.state != zombieProcessState && stat.startTime.Equal(run.ProcessStartTime) {
+		// the process can be found again after restart, its start is the one before restart
+		if len(history) != 0 && history[len(history)-1].Status() == ProcessStarted {
+			processInfo = history[len(history)-1].ProcessInfo()
+			history = history[:len(history)-1]
+		}
+		startedProcessStatus.SetProcessInfo(processInfo)
+		processStatusHistory[run.Pid] = append(history, startedProcessStatus)
+		return true
+	}
+	// the process finished while the daemon was stopped, so it is finished when the daemon is restarted
+	startedProcessStatus.SetProcessInfo(processInfo)
+	finishedProcessStatus := NewProcessStatus(run.Pid, ProcessFinished)
+	finishedProcessStatus.SetProcessInfo(processInfo)
+	processStatusHistory[run.Pid] = append(history, startedProcessStatus, finishedProcessStatus)
+	return true
+}
+
+// ReleaseProcessStatusHistory forgets the process which is finished, e.g. after it is alarmed and recorded in run history.
+// history of the pid is kept if another process with the pid is started in the meantime
+func (pim *ProcessInfoMonitor) ReleaseProcessStatusHistory(namePattern string, pid int) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	processStatusHistory, ok := pim.processStatusHistoryByMonitoringCommand[namePattern]
+	if !ok {
+		return
+	}
+	history, ok := processStatusHistory[pid]
+	if ok && history[len(history)-1].Status() == ProcessFinished {
+		delete(processStatusHistory, pid)
+	}
+}
+
+// UnwatchPid releases history of the process watched by WatchPid
+func (pim *ProcessInfoMonitor) UnwatchPid(pid int) {
+	monitoringCommand := WatchedPidMonitoringCommand(pid)
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	delete(pim.watchedProcessByMonitoringCommand, monitoringCommand)
+	delete(pim.processStatusHistoryByMonitoringCommand, monitoringCommand)
+}
+
+// SetEnvironmentMarker tracks processes which have the environment variable, e.g. ALARM_ME=1.
+// they are kept under EnvironmentMarkerMonitoringCommand(environmentMarker), empty marker disables it
+func (pim *ProcessInfoMonitor) SetEnvironmentMarker(environmentMarker string) {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	if pim.environmentMarker == environmentMarker {
+		return
+	}
+	if pim.environmentMarker != "" {
+		delete(pim.processStatusHistoryByMonitoringCommand, EnvironmentMarkerMonitoringCommand(pim.environmentMarker))
+	}
+	pim.environmentMarker = environmentMarker
+	if environmentMarker != "" {
+		pim.processStatusHistoryByMonitoringCommand[EnvironmentMarkerMonitoringCommand(environmentMarker)] = map[int]([]ProcessStatus){}
+	}
+}
+
+// ReservedMonitoringCommandPrefix starts keys of history which are not namePatterns,
+// namePatterns can not start with it so that they never share history with processes watched by pid or opted in by environment marker
+const ReservedMonitoringCommandPrefix = "@"
+
+// EnvironmentMarkerMonitoringCommand is the key of history of processes opted in by environment marker
+func EnvironmentMarkerMonitoringCommand(environmentMarker string) string {
+	return fmt.Sprintf("%senv %s", ReservedMonitoringCommandPrefix, environmentMarker)
+}
+
+// WatchedPidMonitoringCommand is the key of history of process watched by pid
+func WatchedPidMonitoringCommand(pid int) string {
+	return fmt.Sprintf("%spid %d", ReservedMonitoringCommandPrefix, pid)
+}
+
+// ValidateNamePattern checks that namePattern doesn't start with ReservedMonitoringCommandPrefix
+func ValidateNamePattern(namePattern string) error {
+	if strings.HasPrefix(namePattern, ReservedMonitoringCommandPrefix) {
+		return fmt.Errorf("pattern can not start with %q, it is reserved for processes watched by pid or opted in by environment marker", ReservedMonitoringCommandPrefix)
+	}
+	return nil
+}
+
 func (pim *ProcessInfoMonitor) Stop() {
 	pim.start = false
 	pim.processInfoReader.Stop()
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	pim.processExitListener.Close()
+	pim.processExitListener = nil
 }
 
 func (pim *ProcessInfoMonitor) SetPeriod(period time.Duration) {
@@ -126,11 +570,84 @@ func (pim *ProcessInfoMonitor) SetPeriod(period time.Duration) {
 	pim.processInfoReader.SetPeriod(period)
 }
 
+// SetMonitoringCommandList replaces monitoringCommandList at once.
+// history of removed namePattern is released and history of remaining namePattern is kept,
+// added namePattern is tracked immediately without waiting next monitoring period
 func (pim *ProcessInfoMonitor) SetMonitoringCommandList(monitoringCommandList []string) {
-	pim.monitoringCommandList = monitoringCommandList
+	addedNamePatternList := []string{}
+
+	pim.mutexForProcessStatusHistory.Lock()
+	pim.monitoringCommandList = append([]string{}, monitoringCommandList...)
+	processStatusHistoryByMonitoringCommand := map[string](map[int]([]ProcessStatus)){}
+	for _, namePattern := range pim.projectMonitoringCommandList {
+		processStatusHistoryByMonitoringCommand[namePattern] = pim.processStatusHistoryByMonitoringCommand[namePattern]
+	}
+	for monitoringCommand := range pim.watchedProcessByMonitoringCommand {
+		processStatusHistoryByMonitoringCommand[monitoringCommand] = pim.processStatusHistoryByMonitoringCommand[monitoringCommand]
+	}
+	if pim.environmentMarker != "" {
+		monitoringCommand := EnvironmentMarkerMonitoringCommand(pim.environmentMarker)
+		processStatusHistoryByMonitoringCommand[monitoringCommand] = pim.processStatusHistoryByMonitoringCommand[monitoringCommand]
+	}
 	for _, namePattern := range monitoringCommandList {
-		pim.processStatusHistoryByMonitoringCommand[namePattern] = map[int]([]ProcessStatus){}
+		processStatusHistory, ok := pim.processStatusHistoryByMonitoringCommand[namePattern]
+		if !ok {
+			processStatusHistory = map[int]([]ProcessStatus){}
+			addedNamePatternList = append(addedNamePatternList, namePattern)
+		}
+		processStatusHistoryByMonitoringCommand[namePattern] = processStatusHistory
+	}
+	pim.processStatusHistoryByMonitoringCommand = processStatusHistoryByMonitoringCommand
+	pim.mutexForProcessStatusHistory.Unlock()
+
+	if !pim.start {
+		return
+	}
+	for _, namePattern := range addedNamePatternList {
+		pim.updateProcessStatusHistoryByMonitoringCommand(namePattern)
+	}
+}
+
+// SetProjectConfigFinder enables namePatterns of project configs
+func (pim *ProcessInfoMonitor) SetProjectConfigFinder(projectConfigFinder *ProjectConfigFinder) {
+	pim.projectConfigFinder = projectConfigFinder
+}
+
+func (pim *ProcessInfoMonitor) GetMonitoringCommandList() []string {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	return append([]string{}, pim.monitoringCommandList...)
+}
+
+// GetTrackedMonitoringCommandList returns monitoringCommandList, namePatterns found in project configs
+// and monitoringCommands of processes opted in by environment marker and watched processes
+func (pim *ProcessInfoMonitor) GetTrackedMonitoringCommandList() []string {
+	pim.mutexForProcessStatusHistory.Lock()
+	defer pim.mutexForProcessStatusHistory.Unlock()
+	return pim.trackedMonitoringCommandList()
+}
+
+func (pim *ProcessInfoMonitor) trackedMonitoringCommandList() []string {
+	trackedMonitoringCommandList := append([]string{}, pim.monitoringCommandList...)
+	for _, namePattern := range pim.projectMonitoringCommandList {
+		if !findNamePattern(namePattern, trackedMonitoringCommandList) {
+			trackedMonitoringCommandList = append(trackedMonitoringCommandList, namePattern)
+		}
 	}
+	if pim.environmentMarker != "" {
+		monitoringCommand := EnvironmentMarkerMonitoringCommand(pim.environmentMarker)
+		if !findNamePattern(monitoringCommand, trackedMonitoringCommandList) {
+			trackedMonitoringCommandList = append(trackedMonitoringCommandList, monitoringCommand)
+		}
+	}
+	watchedMonitoringCommandList := []string{}
+	for monitoringCommand := range pim.watchedProcessByMonitoringCommand {
+		if !findNamePattern(monitoringCommand, trackedMonitoringCommandList) {
+			watchedMonitoringCommandList = append(watchedMonitoringCommandList, monitoringCommand)
+		}
+	}
+	sort.Strings(watchedMonitoringCommandList)
+	return append(trackedMonitoringCommandList, watchedMonitoringCommandList...)
 }
 
 func (pim *ProcessInfoMonitor) GetProcessStatusLogByMonitoringCommand(namePattern string) map[int]([]ProcessStatus) {
@@ -164,3 +681,21 @@ func findProcessStatusInHistory(wholeProcessStatusHistory map[int]([]ProcessStat
 	}
 	return mostRecentProcessStatus
 }
+
+func findPidInPidList(pid int, pidList []int) bool {
+	for _, _pid := range pidList {
+		if pid == _pid {
+			return true
+		}
+	}
+	return false
+}
+
+func findNamePattern(namePattern string, monitoringCommandList []string) bool {
+	for _, _namePattern := range monitoringCommandList {
+		if namePattern == _namePattern {
+			return true
+		}
+	}
+	return false
+}
@@ -1,13 +1,26 @@
 package alarm
 
 import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
 func TestProcessInfoMonitor(t *testing.T) {
 	t.Run("MonitoringCommandList", CheckMonitoringCommandList())
+	t.Run("SetMonitoringCommandList", CheckSetMonitoringCommandList())
+	t.Run("ExecutedProcess", CheckExecutedProcess())
+	t.Run("ProjectMonitoringCommandList", CheckProjectMonitoringCommandList())
+	t.Run("WatchPid", CheckWatchPid())
+	t.Run("EnvironmentMarker", CheckEnvironmentMarker())
+	t.Run("ProcessTree", CheckProcessTree())
+	t.Run("RestoreRun", CheckRestoreRun())
+	t.Run("PatternMatchPolicy", CheckPatternMatchPolicy())
 }
 
 func CheckMonitoringCommandList() func(*testing.T) {
@@ -29,3 +42,346 @@ func CheckMonitoringCommandList() func(*testing.T) {
 		require.Zero(t, len(wholeProcessStatusHistory))
 	}
 }
+
+func CheckSetMonitoringCommandList() func(*testing.T) {
+	return func(t *testing.T) {
+		c := exec.Command("sleep", "1.2345")
+		require.NoError(t, c.Start())
+		pim := NewProcessInfoMonitor(
+			[]string{"go test"},
+		)
+		defer pim.Stop()
+
+		// added namePattern is tracked without waiting monitoring period
+		pim.SetMonitoringCommandList([]string{"go test", "sleep 1.2345"})
+		require.Equal(t, []string{"go test", "sleep 1.2345"}, pim.GetMonitoringCommandList())
+		wholeProcessStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("sleep 1.2345")
+		require.Equal(t, 1, len(wholeProcessStatusHistory[c.Process.Pid]))
+		require.Equal(t,
+			ProcessStarted,
+			wholeProcessStatusHistory[c.Process.Pid][0].Status())
+
+		// history of removed namePattern is released
+		pim.SetMonitoringCommandList([]string{"sleep 1.2345"})
+		require.Equal(t, []string{"sleep 1.2345"}, pim.GetMonitoringCommandList())
+		require.Zero(t, len(pim.GetProcessStatusLogByMonitoringCommand("go test")))
+
+		c.Wait()
+		time.Sleep(2 * defaultPeriod)
+		wholeProcessStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("sleep 1.2345")
+		require.Equal(t, 2, len(wholeProcessStatusHistory[c.Process.Pid]))
+		require.Equal(t,
+			ProcessFinished,
+			wholeProcessStatusHistory[c.Process.Pid][1].Status())
+	}
+}
+
+// process which executes another command is tracked until it finishes, though it doesn't match namePattern any more
+func CheckExecutedProcess() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "executed")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		scriptPath := filepath.Join(dir, "alarm_executed_test.sh")
+		require.NoError(t, ioutil.WriteFile(scriptPath, []byte("sleep 0.8; exec sleep 0.8\n"), 0644))
+
+		pim := NewProcessInfoMonitor(
+			[]string{"alarm_executed_test.sh"},
+		)
+		defer pim.Stop()
+
+		c := exec.Command("sh", scriptPath)
+		require.NoError(t, c.Start())
+		time.Sleep(1200 * time.Millisecond)
+		processStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("alarm_executed_test.sh")[c.Process.Pid]
+		require.Equal(t, 1, len(processStatusHistory))
+		require.Equal(t, ProcessStarted, processStatusHistory[0].Status())
+
+		c.Wait()
+		time.Sleep(3 * defaultPeriod)
+		processStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("alarm_executed_test.sh")[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		require.Equal(t, ProcessFinished, processStatusHistory[1].Status())
+	}
+}
+
+// namePattern of project config is applied only to processes in the project
+func CheckProjectMonitoringCommandList() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			".alarm.json": `{"monitoringCommandList": ["sleep 1.3456"]}`,
+		})
+		defer os.RemoveAll(dir)
+
+		inProject := exec.Command("sleep", "1.3456")
+		inProject.Dir = filepath.Join(dir, "service")
+		require.NoError(t, inProject.Start())
+		outOfProject := exec.Command("sleep", "1.3456")
+		outOfProject.Dir = os.TempDir()
+		require.NoError(t, outOfProject.Start())
+
+		pim := NewProcessInfoMonitor(
+			[]string{},
+		)
+		defer pim.Stop()
+		pim.SetProjectConfigFinder(NewProjectConfigFinder())
+		time.Sleep(2 * defaultPeriod)
+
+		require.Equal(t, []string{}, pim.GetMonitoringCommandList())
+		require.Equal(t, []string{"sleep 1.3456"}, pim.GetTrackedMonitoringCommandList())
+		wholeProcessStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3456")
+		require.Equal(t, 1, len(wholeProcessStatusHistory))
+		require.Equal(t, 1, len(wholeProcessStatusHistory[inProject.Process.Pid]))
+
+		inProject.Wait()
+		outOfProject.Wait()
+		time.Sleep(2 * defaultPeriod)
+		wholeProcessStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3456")
+		require.Equal(t,
+			ProcessFinished,
+			wholeProcessStatusHistory[inProject.Process.Pid][1].Status())
+		processInfo := wholeProcessStatusHistory[inProject.Process.Pid][1].ProcessInfo()
+		require.Equal(t, filepath.Join(dir, "service"), processInfo.BinaryLocation())
+		pim.ReleaseProcessStatusHistory("sleep 1.3456", inProject.Process.Pid)
+
+		// namePattern of edited project config is dropped with its history
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".alarm.json"), []byte(`{"monitoringCommandList": ["sleep 1.3457"]}`), 0644))
+		pim.SetProjectConfigFinder(NewProjectConfigFinder())
+		inProject = exec.Command("sleep", "1.3457")
+		inProject.Dir = filepath.Join(dir, "service")
+		require.NoError(t, inProject.Start())
+		time.Sleep(2 * defaultPeriod)
+		require.Equal(t, []string{"sleep 1.3457"}, pim.GetTrackedMonitoringCommandList())
+		require.Equal(t, 1, len(pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3457")))
+		inProject.Wait()
+	}
+}
+
+// process is tracked by pid without any namePattern
+func CheckWatchPid() func(*testing.T) {
+	return func(t *testing.T) {
+		pim := NewProcessInfoMonitor(
+			[]string{},
+		)
+		defer pim.Stop()
+		c := exec.Command("sleep", "1")
+		require.NoError(t, c.Start())
+
+		monitoringCommand, err := pim.WatchPid(c.Process.Pid)
+		require.NoError(t, err)
+		require.Equal(t, []string{monitoringCommand}, pim.GetTrackedMonitoringCommandList())
+		processStatusHistory := pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[c.Process.Pid]
+		require.Equal(t, 1, len(processStatusHistory))
+		processInfo := processStatusHistory[0].ProcessInfo()
+		require.Equal(t, "sleep 1", processInfo.Cmd())
+		require.WithinDuration(t, time.Now(), processStatusHistory[0].TimeStamp(), 2*time.Second)
+
+		// zombie process which is not waited yet is finished
+		time.Sleep(1500 * time.Millisecond)
+		processStatusHistory = pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		require.Equal(t, ProcessFinished, processStatusHistory[1].Status())
+		c.Wait()
+
+		pim.UnwatchPid(c.Process.Pid)
+		require.Equal(t, []string{}, pim.GetTrackedMonitoringCommandList())
+
+		_, err = pim.WatchPid(c.Process.Pid)
+		require.Error(t, err)
+	}
+}
+
+// only the root of processes which have environment marker is tracked
+func CheckEnvironmentMarker() func(*testing.T) {
+	return func(t *testing.T) {
+		pim := NewProcessInfoMonitor(
+			[]string{},
+		)
+		defer pim.Stop()
+		pim.SetEnvironmentMarker("ALARM_TEST_MARKER")
+		monitoringCommand := EnvironmentMarkerMonitoringCommand("ALARM_TEST_MARKER")
+		require.Equal(t, []string{monitoringCommand}, pim.GetTrackedMonitoringCommandList())
+
+		marked := exec.Command("sh", "-c", "sleep 1.4567; true")
+		marked.Env = append(os.Environ(), "ALARM_TEST_MARKER=1", "ALARM_LABEL=marked")
+		require.NoError(t, marked.Start())
+		optedOut := exec.Command("sleep", "1.4567")
+		optedOut.Env = append(os.Environ(), "ALARM_TEST_MARKER=0")
+		require.NoError(t, optedOut.Start())
+		time.Sleep(3 * defaultPeriod)
+
+		wholeProcessStatusHistory := pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)
+		require.Equal(t, 1, len(wholeProcessStatusHistory))
+		require.Equal(t, 1, len(wholeProcessStatusHistory[marked.Process.Pid]))
+		processInfo := wholeProcessStatusHistory[marked.Process.Pid][0].ProcessInfo()
+		label, ok := processInfo.AlarmEnvironmentVariable("ALARM_LABEL")
+		require.True(t, ok)
+		require.Equal(t, "marked", label)
+
+		marked.Wait()
+		optedOut.Wait()
+		time.Sleep(2 * defaultPeriod)
+		wholeProcessStatusHistory = pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)
+		require.Equal(t, 2, len(wholeProcessStatusHistory[marked.Process.Pid]))
+		require.Equal(t, ProcessFinished, wholeProcessStatusHistory[marked.Process.Pid][1].Status())
+
+		pim.SetEnvironmentMarker("")
+		require.Equal(t, []string{}, pim.GetTrackedMonitoringCommandList())
+	}
+}
+
+// process and its descendants are one run
+func CheckProcessTree() func(*testing.T) {
+	return func(t *testing.T) {
+		pim := NewProcessInfoMonitor(
+			[]string{"sleep 1.6789"},
+		)
+		defer pim.Stop()
+
+		// children have the same command, but only the root is alarmed
+		c := exec.Command("sh", "-c", "sleep 1.6789 & sleep 1.6789; wait")
+		require.NoError(t, c.Start())
+		time.Sleep(3 * defaultPeriod)
+		wholeProcessStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("sleep 1.6789")
+		require.Equal(t, 1, len(wholeProcessStatusHistory))
+		require.Equal(t, 1, len(wholeProcessStatusHistory[c.Process.Pid]))
+
+		c.Wait()
+		time.Sleep(3 * defaultPeriod)
+		wholeProcessStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("sleep 1.6789")
+		require.Equal(t, 2, len(wholeProcessStatusHistory[c.Process.Pid]))
+		require.Equal(t, ProcessFinished, wholeProcessStatusHistory[c.Process.Pid][1].Status())
+		processTreeSummary := wholeProcessStatusHistory[c.Process.Pid][1].ProcessTreeSummary()
+		require.Equal(t, 3, processTreeSummary.ProcessCount)
+		require.NotZero(t, processTreeSummary.PeakMemory)
+		require.Zero(t, processTreeSummary.FailedProcess.Pid)
+
+		// root doesn't wait its child after exec, so the failed child is left as a zombie
+		c = exec.Command("sh", "-c", "(sleep 0.3; exit 3) & exec sleep 1")
+		require.NoError(t, c.Start())
+		monitoringCommand, err := pim.WatchPid(c.Process.Pid)
+		require.NoError(t, err)
+		time.Sleep(1500 * time.Millisecond)
+		processStatusHistory := pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		require.Equal(t, ProcessFinished, processStatusHistory[1].Status())
+		failedProcess := processStatusHistory[1].ProcessTreeSummary().FailedProcess
+		require.NotZero(t, failedProcess.Pid)
+		require.NotEqual(t, c.Process.Pid, failedProcess.Pid)
+		require.Equal(t, 3, failedProcess.ExitCode)
+		c.Wait()
+
+		// failed child which is waited at once is found by its exit event, as well as exit code of the root
+		if pim.processExitListener == nil {
+			t.Skip("proc connector is not available")
+		}
+		// the child lives a little, because command line is read from /proc when its exec event is received
+		c = exec.Command("sh", "-c", "sleep 0.3; sh -c 'sleep 0.1; exit 4'; sleep 0.5; exit 2")
+		require.NoError(t, c.Start())
+		monitoringCommand, err = pim.WatchPid(c.Process.Pid)
+		require.NoError(t, err)
+		c.Wait()
+		time.Sleep(3 * defaultPeriod)
+		processStatusHistory = pim.GetProcessStatusLogByMonitoringCommand(monitoringCommand)[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		processTreeSummary = processStatusHistory[1].ProcessTreeSummary()
+		require.Equal(t, 2, *processTreeSummary.ExitCode)
+		require.Equal(t, "sh -c sleep 0.1; exit 4", processTreeSummary.FailedProcess.Cmd)
+		require.Equal(t, 4, processTreeSummary.FailedProcess.ExitCode)
+	}
+}
+
+// runs which are running when the daemon is stopped are restored after restart
+func CheckRestoreRun() func(*testing.T) {
+	return func(t *testing.T) {
+		c := exec.Command("sleep", "1.3579")
+		require.NoError(t, c.Start())
+		pim := NewProcessInfoMonitor(
+			[]string{"sleep 1.3579"},
+		)
+		defer pim.Stop()
+		processStartTime, err := GetStartTimeOfProcessByPid(c.Process.Pid)
+		require.NoError(t, err)
+
+		// the process is still running, so it is started before restart
+		startedAt := time.Now().Add(-time.Hour).Round(0)
+		run := Run{
+			MonitoringCommand: "sleep 1.3579",
+			Pid:               c.Process.Pid,
+			ProcessStartTime:  processStartTime,
+			Command:           "sleep 1.3579",
+			StartedAt:         startedAt,
+		}
+		require.True(t, pim.RestoreRun(run))
+		processStatusHistory := pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3579")[c.Process.Pid]
+		require.Equal(t, 1, len(processStatusHistory))
+		require.Equal(t, ProcessStarted, processStatusHistory[0].Status())
+		require.True(t, startedAt.Equal(processStatusHistory[0].TimeStamp()))
+
+		c.Wait()
+		time.Sleep(2 * defaultPeriod)
+		processStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3579")[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		require.Equal(t, ProcessFinished, processStatusHistory[1].Status())
+		pim.ReleaseProcessStatusHistory("sleep 1.3579", c.Process.Pid)
+		require.Zero(t, len(pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3579")))
+
+		// the process finished while the daemon was stopped
+		require.True(t, pim.RestoreRun(run))
+		processStatusHistory = pim.GetProcessStatusLogByMonitoringCommand("sleep 1.3579")[c.Process.Pid]
+		require.Equal(t, 2, len(processStatusHistory))
+		require.Equal(t, ProcessStarted, processStatusHistory[0].Status())
+		require.Equal(t, ProcessFinished, processStatusHistory[1].Status())
+
+		run.MonitoringCommand = "THERE WILL BE NO PROCESS WHOSE NAME LIKE THIS"
+		require.False(t, pim.RestoreRun(run))
+	}
+}
+
+// process matched by several namePatterns is tracked by the ones chosen by patternMatchPolicy
+func CheckPatternMatchPolicy() func(*testing.T) {
+	return func(t *testing.T) {
+		for _, testCase := range []struct {
+			patternMatchPolicy        string
+			expectedMonitoringCommand []string
+		}{
+			{MostSpecificPatternMatchPolicy, []string{"sleep 1.2468"}},
+			{FirstPatternMatchPolicy, []string{"sleep 1.24"}},
+			{AllPatternMatchPolicy, []string{"sleep 1.24", "sleep 1.2468"}},
+		} {
+			c := exec.Command("sleep", "1.2468")
+			require.NoError(t, c.Start())
+			pim := NewProcessInfoMonitor(
+				[]string{},
+			)
+			pim.SetPatternMatchPolicy(testCase.patternMatchPolicy)
+			pim.SetMonitoringCommandList([]string{"sleep 1.24", "sleep 1.2468"})
+			time.Sleep(3 * defaultPeriod)
+
+			trackingMonitoringCommandList := []string{}
+			for _, namePattern := range pim.GetMonitoringCommandList() {
+				processStatusHistory, ok := pim.GetProcessStatusLogByMonitoringCommand(namePattern)[c.Process.Pid]
+				if !ok {
+					continue
+				}
+				trackingMonitoringCommandList = append(trackingMonitoringCommandList, namePattern)
+				processInfo := processStatusHistory[0].ProcessInfo()
+				if testCase.patternMatchPolicy == MostSpecificPatternMatchPolicy {
+					require.Equal(t, []string{"sleep 1.2468", "sleep 1.24"}, processInfo.MatchedPatternList())
+				} else {
+					require.Equal(t, []string{"sleep 1.24", "sleep 1.2468"}, processInfo.MatchedPatternList())
+				}
+			}
+			require.Equal(t, testCase.expectedMonitoringCommand, trackingMonitoringCommandList, testCase.patternMatchPolicy)
+
+			// more specific namePattern added later doesn't track the process which is tracked already
+			if testCase.patternMatchPolicy == FirstPatternMatchPolicy {
+				pim.SetPatternMatchPolicy(MostSpecificPatternMatchPolicy)
+				time.Sleep(2 * defaultPeriod)
+				require.Empty(t, pim.GetProcessStatusLogByMonitoringCommand("sleep 1.2468"))
+			}
+			c.Wait()
+			pim.Stop()
+		}
+	}
+}
@@ -1,10 +1,6 @@
 package alarm
 
 import (
-	"bufio"
-	"fmt"
-	"io/ioutil"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +11,8 @@ type ProcessInfoReader struct {
 	monitoringPeriod              time.Duration
 	isStarted                     bool
 	mutexForSynchronousMethodCall sync.Mutex
+
+	projectFinder *ProjectFinder
 }
 
 func NewProcessInfoReader() *ProcessInfoReader {
@@ -27,6 +25,7 @@ func NewProcessInfoReader() *ProcessInfoReader {
 
 func (pir *ProcessInfoReader) Init() {
 	pir.SetPeriod(defaultPeriod)
+	pir.projectFinder = NewProjectFinder()
 }
 
 func (pir *ProcessInfoReader) Start() {
@@ -62,6 +61,10 @@ func (pir *ProcessInfoReader) SetPeriod(period time.Duration) {
 	pir.monitoringPeriod = period
 }
 
+func (pir *ProcessInfoReader) GetProcessInfoList() []ProcessInfo {
+	return append([]ProcessInfo{}, pir.processInfoList...)
+}
+
 func (pir *ProcessInfoReader) GetPidListByName(namePattern string) []int {
 	pidList := []int{}
 	for _, processInfo := range pir.processInfoList {
@@ -73,7 +76,8 @@ func (pir *ProcessInfoReader) GetPidListByName(namePattern string) []int {
 }
 
 func (pir *ProcessInfoReader) IsExecuting(pid int) bool {
-	return pir.findProcessInfoByPid(pid) != ProcessInfo{}
+	processInfo := pir.findProcessInfoByPid(pid)
+	return processInfo.Pid() != 0 && !processInfo.IsZombie()
 }
 
 // there is no "/" at the last of file location
@@ -91,41 +95,8 @@ func (pir *ProcessInfoReader) findProcessInfoByPid(pid int) ProcessInfo {
 	return ProcessInfo{}
 }
 
-func (pir *ProcessInfoReader) GetPackageNameOfGolangProcess(pid int) string {
+// GetProjectOfProcess finds the project which the process runs in from its working directory
+func (pir *ProcessInfoReader) GetProjectOfProcess(pid int) (Project, bool) {
 	binaryLocation := pir.GetLocationOfExecutedBinary(pid)
-	packageName := getPackageNameOfGolangProcessFromDirectory(binaryLocation)
-	return packageName
-}
-
-func getPackageNameOfGolangProcessFromDirectory(directory string) (packageName string) {
-	fileList, err := ioutil.ReadDir(directory)
-	if err != nil {
-		return ""
-	}
-	for _, f := range fileList {
-		path := fmt.Sprintf("%s/%s", directory, f.Name())
-		fo, err := os.Open(path)
-		if err != nil {
-			continue
-		}
-		if packageName = getPackageNameOfGolangProcessFromFile(fo); packageName != "" {
-			break
-		}
-		fo.Close()
-	}
-	return packageName
-}
-
-func getPackageNameOfGolangProcessFromFile(file *os.File) (packageName string) {
-	reader := bufio.NewReader(file)
-	_line, _, err := reader.ReadLine()
-	if err != nil {
-		return ""
-	}
-
-	line := string(_line)
-	if strings.Contains(line, "package") {
-		packageName = strings.Split(line, " ")[1]
-	}
-	return packageName
+	return pir.projectFinder.Find(binaryLocation)
 }
@@ -11,7 +11,7 @@ import (
 func TestProcessInfoReader(t *testing.T) {
 	t.Run("ExecutingStatus", CheckExecutingStatus())
 	t.Run("DirectoryOfExecutingBinary", CheckDirectoryOfExecutingBinary())
-	t.Run("PackageNameOfGoLangProcess", CheckPackageNameOfGoLangProcess())
+	t.Run("ProjectOfGoLangProcess", CheckProjectOfGoLangProcess())
 }
 
 func CheckExecutingStatus() func(*testing.T) {
@@ -68,17 +68,17 @@ func getCurrentFileLocation() (fileLocation string) {
 	return fileLocation
 }
 
-func CheckPackageNameOfGoLangProcess() func(*testing.T) {
+func CheckProjectOfGoLangProcess() func(*testing.T) {
 	return func(t *testing.T) {
 		pir := NewProcessInfoReader()
 		defer pir.Stop()
 
 		pidList := pir.GetPidListByName("go test")
 		require.Equal(t, 1, len(pidList))
-		require.Equal(t,
-			"alarm",
-			pir.GetPackageNameOfGolangProcess(pidList[0]),
-		)
+		project, ok := pir.GetProjectOfProcess(pidList[0])
+		require.True(t, ok)
+		require.Equal(t, GolangModuleKind, project.Kind)
+		require.Equal(t, "github.com/goodahn/alarm-for-programmer", project.Identifier)
 
 		pidList = pir.GetPidListByName("THERE WILL BE NO PROCESS WHOSE NAME LIKE THIS")
 		require.Equal(t, 0, len(pidList))
@@ -19,9 +19,12 @@ var (
 )
 
 type ProcessStatus struct {
-	pid       int
-	status    string
-	timestamp time.Time
+	pid         int
+	status      string
+	timestamp   time.Time
+	processInfo ProcessInfo
+	// processTreeSummary is set when the whole tree of the process is finished
+	processTreeSummary ProcessTreeSummary
 }
 
 func NewProcessStatus(pid int, status string) ProcessStatus {
@@ -59,3 +62,22 @@ func (ps *ProcessStatus) TimeStampInString() string {
 func (ps *ProcessStatus) SetTimestamp(timestamp time.Time) {
 	ps.timestamp = timestamp
 }
+
+// ProcessInfo returns info of the process when it was found,
+// it is kept after the process is finished
+func (ps *ProcessStatus) ProcessInfo() ProcessInfo {
+	return ps.processInfo
+}
+
+func (ps *ProcessStatus) SetProcessInfo(processInfo ProcessInfo) {
+	ps.processInfo = processInfo
+}
+
+// ProcessTreeSummary returns usage of the process and its descendants, it is set only to ProcessFinished
+func (ps *ProcessStatus) ProcessTreeSummary() ProcessTreeSummary {
+	return ps.processTreeSummary
+}
+
+func (ps *ProcessStatus) SetProcessTreeSummary(processTreeSummary ProcessTreeSummary) {
+	ps.processTreeSummary = processTreeSummary
+}
@@ -0,0 +1,182 @@
+package alarm
+
+import (
+	"sort"
+	"time"
+)
+
+// ProcessTree is a job, the tracked root process and all its descendants.
+// descendants are found by ppid, and they are kept as members after they are orphaned and reparented
+type ProcessTree struct {
+	rootPid       int
+	rootStartTime time.Time
+	memberByPid   map[int]ProcessInfo
+	isRunning     bool
+	// cpuTimeByPid is the latest cpu time of each member, which is summed up as cpu time of the job
+	cpuTimeByPid  map[int]time.Duration
+	peakMemory    uint64
+	rootExitCode  *int
+	failedProcess FailedProcess
+	// isExitCheckedByPid is members whose exit is found already
+	isExitCheckedByPid map[int]bool
+}
+
+// ProcessTreeSummary is resource usage and failure of a job, which are reported when it is finished
+type ProcessTreeSummary struct {
+	// ProcessCount is the number of processes in the job including the root
+	ProcessCount int
+	// CpuTime is user and system time spent by the processes which are found while they are running,
+	// so processes shorter than the monitoring period can be missed
+	CpuTime time.Duration
+	// PeakMemory is the biggest sum of resident set size of running processes in bytes
+	PeakMemory uint64
+	// ExitCode is exit code of the root, it is nil if the exit is not observed
+	ExitCode *int
+	// FailedProcess is the first descendant which exited with non-zero code, its Pid is 0 if there is none
+	FailedProcess FailedProcess
+}
+
+type FailedProcess struct {
+	Pid      int    `json:"pid"`
+	Cmd      string `json:"cmd"`
+	ExitCode int    `json:"exitCode"`
+}
+
+func newProcessTree(rootProcessInfo ProcessInfo) *ProcessTree {
+	return &ProcessTree{
+		rootPid:       rootProcessInfo.Pid(),
+		rootStartTime: rootProcessInfo.StartTime(),
+		memberByPid: map[int]ProcessInfo{
+			rootProcessInfo.Pid(): rootProcessInfo,
+		},
+		isRunning:          true,
+		cpuTimeByPid:       map[int]time.Duration{},
+		isExitCheckedByPid: map[int]bool{},
+	}
+}
+
+// update finds new descendants and sums up usage of running members.
+// exit code of member is found from processExitListener, or from /proc while it is a zombie which is not waited by its parent yet.
+// so without processExitListener, exits of most members are not observed because their parents wait them at once
+func (pt *ProcessTree) update(processInfoList []ProcessInfo, processExitListener *ProcessExitListener) {
+	processInfoByPid := map[int]ProcessInfo{}
+	childPidListByPid := map[int][]int{}
+	for _, processInfo := range processInfoList {
+		processInfoByPid[processInfo.Pid()] = processInfo
+		childPidListByPid[processInfo.Ppid()] = append(childPidListByPid[processInfo.Ppid()], processInfo.Pid())
+	}
+
+	// members are visited as well as the root because orphaned members are not children of the tree any more
+	pidList := []int{}
+	for pid := range pt.memberByPid {
+		pidList = append(pidList, pid)
+	}
+	sort.Ints(pidList)
+	isVisitedByPid := map[int]bool{}
+	isRunning := false
+	memory := uint64(0)
+	for len(pidList) != 0 {
+		pid := pidList[0]
+		pidList = pidList[1:]
+		if isVisitedByPid[pid] {
+			continue
+		}
+		isVisitedByPid[pid] = true
+
+		processInfo, ok := processInfoByPid[pid]
+		member, isMember := pt.memberByPid[pid]
+		if !ok || isMember && !member.StartTime().Equal(processInfo.StartTime()) {
+			// member is waited already, or pid is reused by another process
+			if isMember {
+				if waitStatus, found := processExitListener.FindWaitStatus(pid, member.StartTime()); found {
+					pt.checkExit(member, exitCodeOfWaitStatus(waitStatus))
+				}
+			}
+			continue
+		}
+		if !isMember {
+			pt.memberByPid[pid] = processInfo
+			member = processInfo
+		}
+		pt.cpuTimeByPid[pid] = processInfo.CpuTime()
+		if processInfo.IsZombie() {
+			pt.checkExit(member, processInfo.ExitCode())
+			continue
+		}
+		// command of member is changed when it executes another binary after fork
+		pt.memberByPid[pid] = processInfo
+		isRunning = true
+		memory += processInfo.Memory()
+		pidList = append(pidList, childPidListByPid[pid]...)
+	}
+
+	// descendants which finish before they are found in /proc are found by parents of their exits
+	parentPidList := []int{}
+	for pid := range pt.memberByPid {
+		parentPidList = append(parentPidList, pid)
+	}
+	for len(parentPidList) != 0 {
+		parentPid := parentPidList[0]
+		parentPidList = parentPidList[1:]
+		for _, exitedChild := range processExitListener.FindExitedChildList(parentPid, pt.rootStartTime) {
+			if _, ok := pt.memberByPid[exitedChild.Pid]; ok {
+				continue
+			}
+			member, _ := newProcessInfo(exitedChild.Cmd, exitedChild.Pid, "")
+			pt.memberByPid[exitedChild.Pid] = member
+			pt.checkExit(member, exitedChild.ExitCode())
+			parentPidList = append(parentPidList, exitedChild.Pid)
+		}
+	}
+
+	pt.isRunning = isRunning
+	if memory > pt.peakMemory {
+		pt.peakMemory = memory
+	}
+}
+
+// checkExit keeps exit code of the root, or the member as failed process if it is the first failure
+func (pt *ProcessTree) checkExit(member ProcessInfo, exitCode int) {
+	if pt.isExitCheckedByPid[member.Pid()] {
+		return
+	}
+	pt.isExitCheckedByPid[member.Pid()] = true
+	if member.Pid() == pt.rootPid {
+		pt.rootExitCode = &exitCode
+		return
+	}
+	if pt.failedProcess.Pid != 0 || exitCode == 0 {
+		return
+	}
+	// command line of zombie is empty, so the command found while it was running is used
+	pt.failedProcess = FailedProcess{
+		Pid:      member.Pid(),
+		Cmd:      member.Cmd(),
+		ExitCode: exitCode,
+	}
+}
+
+// IsRunning reports whether any process of the job is running
+func (pt *ProcessTree) IsRunning() bool {
+	return pt.isRunning
+}
+
+// HasMember reports whether the process is the root or one of descendants
+func (pt *ProcessTree) HasMember(processInfo ProcessInfo) bool {
+	member, ok := pt.memberByPid[processInfo.Pid()]
+	return ok && member.StartTime().Equal(processInfo.StartTime())
+}
+
+func (pt *ProcessTree) Summary() ProcessTreeSummary {
+	cpuTime := time.Duration(0)
+	for _, memberCpuTime := range pt.cpuTimeByPid {
+		cpuTime += memberCpuTime
+	}
+	return ProcessTreeSummary{
+		ProcessCount:  len(pt.memberByPid),
+		CpuTime:       cpuTime,
+		PeakMemory:    pt.peakMemory,
+		ExitCode:      pt.rootExitCode,
+		FailedProcess: pt.failedProcess,
+	}
+}
@@ -0,0 +1,172 @@
+package alarm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	ProjectConfigFileName = ".alarm.json"
+
+	// project config files are checked again after this duration,
+	// they are parsed again only if they are modified
+	projectConfigCacheDuration = 5 * time.Second
+)
+
+// ProjectConfig is config of a project which is discovered from working directory of processes.
+// it can not contain secrets and only chooses destinations defined in global config,
+// because anyone who can commit to the repository can write it
+type ProjectConfig struct {
+	// Directory is the directory of the nearest project config file
+	Directory             string
+	MonitoringCommandList []string
+	MinimumDuration       time.Duration
+	// Destinations are names of destinations in global config, default destination is used if it is empty
+	Destinations []string
+}
+
+// ProjectConfigFinder finds project config files in working directory of a process and its parents
+// up to the root of repository, which contains ".git".
+// if the directory is not in a repository, only the directory itself is checked
+type ProjectConfigFinder struct {
+	projectConfigFileCacheByDirectory map[string]projectConfigFileCache
+	mutexForCache                     sync.Mutex
+}
+
+type projectConfigFileCache struct {
+	rawProjectConfig map[string]interface{}
+	exists           bool
+	isRepositoryRoot bool
+	modTime          time.Time
+	checkedAt        time.Time
+}
+
+func NewProjectConfigFinder() *ProjectConfigFinder {
+	pcf := &ProjectConfigFinder{}
+	pcf.Init()
+	return pcf
+}
+
+func (pcf *ProjectConfigFinder) Init() {
+	pcf.projectConfigFileCacheByDirectory = map[string]projectConfigFileCache{}
+}
+
+// Find merges every project config file from the repository root to directory.
+// same as config layers, lists are appended and other values are overridden by nearer file
+func (pcf *ProjectConfigFinder) Find(directory string) (ProjectConfig, bool) {
+	if directory == "" {
+		return ProjectConfig{}, false
+	}
+
+	// directory, its parent, ..., repository root
+	directoryList := []string{}
+	isInRepository := false
+	for dir := filepath.Clean(directory); ; dir = filepath.Dir(dir) {
+		directoryList = append(directoryList, dir)
+		if pcf.readProjectConfigFile(dir).isRepositoryRoot {
+			isInRepository = true
+			break
+		}
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	if !isInRepository {
+		directoryList = directoryList[:1]
+	}
+
+	rawProjectConfig := map[string]interface{}{}
+	projectDirectory := ""
+	for i := len(directoryList) - 1; i >= 0; i-- {
+		cache := pcf.readProjectConfigFile(directoryList[i])
+		if !cache.exists {
+			continue
+		}
+		mergeRawConfig(rawProjectConfig, cache.rawProjectConfig, "", "", map[string]string{})
+		projectDirectory = directoryList[i]
+	}
+	if projectDirectory == "" {
+		return ProjectConfig{}, false
+	}
+	projectConfig, err := DecodeProjectConfig(rawProjectConfig)
+	if err != nil {
+		return ProjectConfig{}, false
+	}
+	projectConfig.Directory = projectDirectory
+	return projectConfig, true
+}
+
+func (pcf *ProjectConfigFinder) readProjectConfigFile(directory string) projectConfigFileCache {
+	pcf.mutexForCache.Lock()
+	defer pcf.mutexForCache.Unlock()
+
+	cache, ok := pcf.projectConfigFileCacheByDirectory[directory]
+	if ok && time.Since(cache.checkedAt) < projectConfigCacheDuration {
+		return cache
+	}
+
+	projectConfigPath := filepath.Join(directory, ProjectConfigFileName)
+	newCache := projectConfigFileCache{
+		checkedAt: time.Now(),
+	}
+	if _, err := os.Stat(filepath.Join(directory, ".git")); err == nil {
+		newCache.isRepositoryRoot = true
+	}
+	fileInfo, err := os.Stat(projectConfigPath)
+	if err != nil {
+		pcf.projectConfigFileCacheByDirectory[directory] = newCache
+		return newCache
+	}
+	if ok && cache.exists && cache.modTime.Equal(fileInfo.ModTime()) {
+		cache.checkedAt = newCache.checkedAt
+		cache.isRepositoryRoot = newCache.isRepositoryRoot
+		pcf.projectConfigFileCacheByDirectory[directory] = cache
+		return cache
+	}
+
+	newCache.modTime = fileInfo.ModTime()
+	rawProjectConfig, err := readConfigFile(projectConfigPath)
+	if err == nil {
+		_, err = DecodeProjectConfig(rawProjectConfig)
+	}
+	if err != nil {
+		// error is printed only once until the file is modified
+		errMsg := fmt.Sprintf("project config file %s is ignored because it is invalid:\n%v", projectConfigPath, err)
+		fmt.Println(errMsg)
+		pcf.projectConfigFileCacheByDirectory[directory] = newCache
+		return newCache
+	}
+	newCache.exists = true
+	newCache.rawProjectConfig = rawProjectConfig
+	pcf.projectConfigFileCacheByDirectory[directory] = newCache
+	return newCache
+}
+
+// DecodeProjectConfig converts raw project config into ProjectConfig and validates it.
+// names of destinations are checked when alarms are sent because global config can be changed
+func DecodeProjectConfig(rawProjectConfig map[string]interface{}) (ProjectConfig, error) {
+	d := &configDecoder{}
+	path := "$"
+	projectConfig := ProjectConfig{
+		MonitoringCommandList: []string{},
+		Destinations:          []string{},
+	}
+	d.checkUnknownFields(rawProjectConfig, path, "monitoringCommandList", "minimumDuration", "destinations")
+
+	if val, ok := rawProjectConfig["monitoringCommandList"]; ok {
+		projectConfig.MonitoringCommandList = d.decodeMonitoringCommandList(val, path+".monitoringCommandList")
+	}
+	if val, ok := rawProjectConfig["minimumDuration"]; ok {
+		projectConfig.MinimumDuration = d.decodeNonNegativeDuration(val, path+".minimumDuration")
+	}
+	if val, ok := rawProjectConfig["destinations"]; ok {
+		projectConfig.Destinations = d.decodeStringList(val, path+".destinations")
+	}
+	if len(d.errorList) != 0 {
+		return ProjectConfig{}, d.errorList
+	}
+	return projectConfig, nil
+}
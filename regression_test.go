@@ -0,0 +1,99 @@
+package alarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegressionDetector(t *testing.T) {
+	t.Run("DurationRegression", CheckDurationRegression())
+	t.Run("PeakMemoryRegression", CheckPeakMemoryRegression())
+	t.Run("PeakMemoryStatistics", CheckPeakMemoryStatistics())
+}
+
+func newDurationStatistics(duration time.Duration, count int) DurationStatistics {
+	durationList := []time.Duration{}
+	for i := 0; i < count; i++ {
+		durationList = append(durationList, duration)
+	}
+	return NewDurationStatistics(durationList)
+}
+
+func CheckDurationRegression() func(*testing.T) {
+	return func(t *testing.T) {
+		rd := RegressionDetector{Percent: 150, MinimumSampleCount: 10}
+		now := time.Now()
+		run := finishTestRun(newTestRun(100, now.Add(-4*time.Minute)), now, 0)
+
+		regressionList := rd.FindRegressionList(run, newDurationStatistics(2*time.Minute, 10), PeakMemoryStatistics{})
+		require.Equal(
+			t,
+			[]Regression{{
+				Kind:        DurationRegressionKind,
+				Value:       uint64(4 * time.Minute),
+				Median:      uint64(2 * time.Minute),
+				SampleCount: 10,
+			}},
+			regressionList,
+		)
+		require.Equal(t, 2.0, regressionList[0].Ratio())
+
+		// 1.5 times of the median is not a regression yet
+		require.Empty(t, rd.FindRegressionList(run, newDurationStatistics(160*time.Second, 10), PeakMemoryStatistics{}))
+		// baseline needs enough runs
+		require.Empty(t, rd.FindRegressionList(run, newDurationStatistics(2*time.Minute, 9), PeakMemoryStatistics{}))
+		// failed runs are not compared
+		failedRun := finishTestRun(newTestRun(100, now.Add(-4*time.Minute)), now, 1)
+		require.Empty(t, rd.FindRegressionList(failedRun, newDurationStatistics(2*time.Minute, 10), PeakMemoryStatistics{}))
+		// 0 disables it
+		rd.Percent = 0
+		require.Empty(t, rd.FindRegressionList(run, newDurationStatistics(2*time.Minute, 10), PeakMemoryStatistics{}))
+	}
+}
+
+func CheckPeakMemoryRegression() func(*testing.T) {
+	return func(t *testing.T) {
+		rd := RegressionDetector{Percent: 150, MinimumSampleCount: 3}
+		now := time.Now()
+		run := finishTestRun(newTestRun(100, now.Add(-time.Minute)), now, 0)
+		run.PeakMemory = 3 << 30
+
+		regressionList := rd.FindRegressionList(
+			run,
+			newDurationStatistics(time.Minute, 3),
+			NewPeakMemoryStatistics([]uint64{1 << 30, 1 << 30, 2 << 30}),
+		)
+		require.Equal(
+			t,
+			[]Regression{{
+				Kind:        PeakMemoryRegressionKind,
+				Value:       3 << 30,
+				Median:      1 << 30,
+				SampleCount: 3,
+			}},
+			regressionList,
+		)
+
+		// memory of commands reported by shell hooks is not measured
+		run.PeakMemory = 0
+		require.Empty(t, rd.FindRegressionList(run, newDurationStatistics(time.Minute, 3), NewPeakMemoryStatistics([]uint64{1, 1, 1})))
+	}
+}
+
+func CheckPeakMemoryStatistics() func(*testing.T) {
+	return func(t *testing.T) {
+		pms := NewPeakMemoryStatistics([]uint64{4, 1, 3, 2})
+		require.Equal(t, 4, pms.SampleCount)
+		require.Equal(t, uint64(2), pms.Median)
+
+		peakMemoryList := []uint64{}
+		for i := 1; i <= DurationSampleCount+10; i++ {
+			peakMemoryList = append(peakMemoryList, uint64(i)<<20)
+		}
+		pms = NewPeakMemoryStatistics(peakMemoryList)
+		require.Equal(t, DurationSampleCount, pms.SampleCount)
+		require.Equal(t, uint64(41)<<19, pms.Median)
+	}
+}
@@ -0,0 +1,414 @@
+package alarm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	RunHistoryFileName = "history.jsonl"
+
+	runStartedRecord   = "start"
+	runFinishedRecord  = "finish"
+	runDiscardedRecord = "discard"
+
+	// history file is compacted when it has this many records more than runs in memory
+	runHistoryCompactionThreshold = 1000
+)
+
+// Run is a run of a command, which is a process matched by monitoringCommand or a command reported by shell hooks
+type Run struct {
+	MonitoringCommand string `json:"monitoringCommand"`
+	Pid               int    `json:"pid"`
+	// ProcessStartTime tells the process from a later one which reuses its pid
+	ProcessStartTime time.Time `json:"processStartTime"`
+	Command          string    `json:"command"`
+	Directory        string    `json:"directory"`
+	StartedAt        time.Time `json:"startedAt"`
+	// FinishedAt is zero while the run is running
+	FinishedAt time.Time `json:"finishedAt"`
+	// ExitCode is nil when it is not known, e.g. exit code of process which is waited by its parent
+	ExitCode      *int          `json:"exitCode,omitempty"`
+	ProcessCount  int           `json:"processCount,omitempty"`
+	CpuTime       time.Duration `json:"cpuTime,omitempty"`
+	PeakMemory    uint64        `json:"peakMemory,omitempty"`
+	FailedProcess FailedProcess `json:"failedProcess"`
+	// AlarmEnvironment is environment variables starting with EnvironmentMarkerPrefix, e.g. ALARM_LABEL
+	AlarmEnvironment map[string]string `json:"alarmEnvironment,omitempty"`
+	// MatchedPatternList is monitoringCommands which matched the process, the first one has the highest priority
+	MatchedPatternList []string `json:"matchedPatternList,omitempty"`
+}
+
+func (r Run) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return 0
+	}
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+func (r Run) key() runKey {
+	return runKey{
+		monitoringCommand: r.MonitoringCommand,
+		pid:               r.Pid,
+		processStartTime:  r.ProcessStartTime.UnixNano(),
+	}
+}
+
+// NewRunOfProcessStatus makes a run of the process, processStatusHistory ends with ProcessStarted or ProcessFinished
+func NewRunOfProcessStatus(monitoringCommand string, processStatusHistory []ProcessStatus) Run {
+	latestProcessStatus := processStatusHistory[len(processStatusHistory)-1]
+	processInfo := latestProcessStatus.ProcessInfo()
+	run := Run{
+		MonitoringCommand:  monitoringCommand,
+		Pid:                latestProcessStatus.Pid(),
+		ProcessStartTime:   processInfo.StartTime(),
+		Command:            processInfo.Cmd(),
+		Directory:          processInfo.BinaryLocation(),
+		StartedAt:          latestProcessStatus.TimeStamp(),
+		AlarmEnvironment:   processInfo.alarmEnvironment,
+		MatchedPatternList: processInfo.MatchedPatternList(),
+	}
+	if latestProcessStatus.Status() == ProcessFinished {
+		run.FinishedAt = latestProcessStatus.TimeStamp()
+		if len(processStatusHistory) >= 2 {
+			run.StartedAt = processStatusHistory[len(processStatusHistory)-2].TimeStamp()
+		}
+		processTreeSummary := latestProcessStatus.ProcessTreeSummary()
+		run.ProcessCount = processTreeSummary.ProcessCount
+		run.CpuTime = processTreeSummary.CpuTime
+		run.PeakMemory = processTreeSummary.PeakMemory
+		run.ExitCode = processTreeSummary.ExitCode
+		run.FailedProcess = processTreeSummary.FailedProcess
+	}
+	return run
+}
+
+// runKey is the identity of run, the process is identified by its pid and start time
+type runKey struct {
+	monitoringCommand string
+	pid               int
+	processStartTime  int64
+}
+
+type runHistoryRecord struct {
+	Type string `json:"type"`
+	Run  Run    `json:"run"`
+}
+
+// RunHistoryStore keeps runs in an append-only file, so they survive restart of the daemon.
+// started runs are recorded too, so that runs which are running during restart are not forgotten,
+// and finished runs are not alarmed again.
+// the file is rewritten without finished records of started runs and runs out of retention when it grows.
+// only one daemon writes the file, which is guaranteed by its control socket
+type RunHistoryStore struct {
+	path string
+	file *os.File
+
+	// retention is how long finished runs are kept, 0 keeps them forever
+	retention time.Duration
+	// maxRunCount is the number of finished runs which are kept, 0 keeps every run
+	maxRunCount int
+	// commandNormalizer makes job keys of runs for statistics, nil only collapses spaces of commands
+	commandNormalizer *CommandNormalizer
+
+	runningRunByKey map[runKey]Run
+	finishedRunList []Run
+	isFinishedByKey map[runKey]bool
+	// finishedRunListByJobKey indexes finishedRunList for statistics, which are read every monitoring period.
+	// it is nil until statistics are read, and after runs are dropped or job keys are changed
+	finishedRunListByJobKey map[string][]Run
+	recordCount             int
+	// brokenLineErrorList is errors of lines which are skipped when the file is read
+	brokenLineErrorList []error
+
+	mutexForRunHistory sync.Mutex
+}
+
+// RunHistoryPath is history.jsonl in $XDG_STATE_HOME/alarm-for-programmer
+func RunHistoryPath() string {
+	return filepath.Join(StateDirectory(), RunHistoryFileName)
+}
+
+// OpenRunHistoryStore reads the history file and compacts it, the file is created if it doesn't exist
+func OpenRunHistoryStore(path string, retention time.Duration, maxRunCount int) (*RunHistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	rhs := newRunHistoryStore(path, retention, maxRunCount)
+	if err := rhs.load(); err != nil {
+		return nil, err
+	}
+	for _, err := range rhs.brokenLineErrorList {
+		errMsg := fmt.Sprintf("error occured during reading run history: %v", err)
+		fmt.Println(errMsg)
+	}
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	if err := rhs.compact(); err != nil {
+		return nil, err
+	}
+	return rhs, nil
+}
+
+func newRunHistoryStore(path string, retention time.Duration, maxRunCount int) *RunHistoryStore {
+	return &RunHistoryStore{
+		path:            path,
+		retention:       retention,
+		maxRunCount:     maxRunCount,
+		runningRunByKey: map[runKey]Run{},
+		isFinishedByKey: map[runKey]bool{},
+	}
+}
+
+func (rhs *RunHistoryStore) load() error {
+	f, err := os.Open(rhs.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		record := runHistoryRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// the last line can be written partially when the daemon is killed
+			rhs.brokenLineErrorList = append(rhs.brokenLineErrorList, fmt.Errorf("line %d of %s: %v", lineNumber, rhs.path, err))
+			continue
+		}
+		rhs.apply(record)
+	}
+	return scanner.Err()
+}
+
+func (rhs *RunHistoryStore) apply(record runHistoryRecord) {
+	rhs.recordCount += 1
+	key := record.Run.key()
+	switch record.Type {
+	case runStartedRecord:
+		if !rhs.isFinishedByKey[key] {
+			rhs.runningRunByKey[key] = record.Run
+		}
+	case runFinishedRecord:
+		delete(rhs.runningRunByKey, key)
+		if !rhs.isFinishedByKey[key] {
+			rhs.isFinishedByKey[key] = true
+			rhs.finishedRunList = append(rhs.finishedRunList, record.Run)
+			if rhs.finishedRunListByJobKey != nil {
+				jobKey := rhs.commandNormalizer.JobKey(record.Run.Command)
+				rhs.finishedRunListByJobKey[jobKey] = append(rhs.finishedRunListByJobKey[jobKey], record.Run)
+			}
+		}
+	case runDiscardedRecord:
+		delete(rhs.runningRunByKey, key)
+	}
+}
+
+// SetRetention changes limits of finished runs, they are applied when the file is compacted
+func (rhs *RunHistoryStore) SetRetention(retention time.Duration, maxRunCount int) {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	rhs.retention = retention
+	rhs.maxRunCount = maxRunCount
+}
+
+// SetCommandNormalizer changes how runs of the same job are found
+func (rhs *RunHistoryStore) SetCommandNormalizer(commandNormalizer *CommandNormalizer) {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	rhs.commandNormalizer = commandNormalizer
+	rhs.finishedRunListByJobKey = nil
+}
+
+// StartRun records the run which is started, it is ignored if the run is recorded already
+func (rhs *RunHistoryStore) StartRun(run Run) error {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	key := run.key()
+	if _, ok := rhs.runningRunByKey[key]; ok || rhs.isFinishedByKey[key] {
+		return nil
+	}
+	return rhs.append(runHistoryRecord{Type: runStartedRecord, Run: run})
+}
+
+// FinishRun records the run which is finished, it returns false if the run is finished already
+func (rhs *RunHistoryStore) FinishRun(run Run) (bool, error) {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	if rhs.isFinishedByKey[run.key()] {
+		return false, nil
+	}
+	return true, rhs.append(runHistoryRecord{Type: runFinishedRecord, Run: run})
+}
+
+// DiscardRun forgets the running run whose finish can't be found, e.g. command of shell which exited
+func (rhs *RunHistoryStore) DiscardRun(run Run) error {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	if _, ok := rhs.runningRunByKey[run.key()]; !ok {
+		return nil
+	}
+	return rhs.append(runHistoryRecord{Type: runDiscardedRecord, Run: run})
+}
+
+// IsFinished reports whether the run of the process is recorded as finished
+func (rhs *RunHistoryStore) IsFinished(run Run) bool {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	return rhs.isFinishedByKey[run.key()]
+}
+
+// IsRecorded reports whether the run is recorded as started or finished, e.g. before restart
+func (rhs *RunHistoryStore) IsRecorded(run Run) bool {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	_, isRunning := rhs.runningRunByKey[run.key()]
+	return isRunning || rhs.isFinishedByKey[run.key()]
+}
+
+// GetRunningRunList returns runs which are started and not finished yet, in the order they are started
+func (rhs *RunHistoryStore) GetRunningRunList() []Run {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	return rhs.getRunningRunList()
+}
+
+func (rhs *RunHistoryStore) getRunningRunList() []Run {
+	runningRunList := []Run{}
+	for _, run := range rhs.runningRunByKey {
+		runningRunList = append(runningRunList, run)
+	}
+	sort.SliceStable(runningRunList, func(i, j int) bool {
+		return runningRunList[i].StartedAt.Before(runningRunList[j].StartedAt)
+	})
+	return runningRunList
+}
+
+// GetFinishedRunList returns finished runs in the order they are finished
+func (rhs *RunHistoryStore) GetFinishedRunList() []Run {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	return append([]Run{}, rhs.finishedRunList...)
+}
+
+func (rhs *RunHistoryStore) append(record runHistoryRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if rhs.file == nil {
+		rhs.file, err = os.OpenFile(rhs.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	// a line is written by one call, so a crash leaves at most the last line broken
+	if _, err := rhs.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	rhs.apply(record)
+	rhs.applyRetention()
+
+	if rhs.recordCount >= len(rhs.runningRunByKey)+len(rhs.finishedRunList)+runHistoryCompactionThreshold {
+		return rhs.compact()
+	}
+	return nil
+}
+
+// applyRetention drops finished runs which are out of retention from memory, the file keeps them until compaction
+func (rhs *RunHistoryStore) applyRetention() {
+	droppedCount := 0
+	if rhs.maxRunCount > 0 && len(rhs.finishedRunList) > rhs.maxRunCount {
+		droppedCount = len(rhs.finishedRunList) - rhs.maxRunCount
+	}
+	keptRunList := rhs.finishedRunList[:0]
+	for i, run := range rhs.finishedRunList {
+		// runs are not always finished in order of FinishedAt, e.g. runs restored after restart
+		if i < droppedCount || (rhs.retention > 0 && time.Since(run.FinishedAt) > rhs.retention) {
+			delete(rhs.isFinishedByKey, run.key())
+			continue
+		}
+		keptRunList = append(keptRunList, run)
+	}
+	if len(keptRunList) != len(rhs.finishedRunList) {
+		rhs.finishedRunListByJobKey = nil
+	}
+	rhs.finishedRunList = keptRunList
+
+	// runs which are started long ago are never finished, e.g. the daemon was not running when they finished
+	for key, run := range rhs.runningRunByKey {
+		if rhs.retention > 0 && time.Since(run.StartedAt) > rhs.retention {
+			delete(rhs.runningRunByKey, key)
+		}
+	}
+}
+
+// compact rewrites the file with runs in memory, it is replaced by rename so that readers never see a half of it
+func (rhs *RunHistoryStore) compact() error {
+	rhs.applyRetention()
+	f, err := ioutil.TempFile(filepath.Dir(rhs.path), RunHistoryFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	w := bufio.NewWriter(f)
+	recordList := []runHistoryRecord{}
+	for _, run := range rhs.finishedRunList {
+		recordList = append(recordList, runHistoryRecord{Type: runFinishedRecord, Run: run})
+	}
+	for _, run := range rhs.getRunningRunList() {
+		recordList = append(recordList, runHistoryRecord{Type: runStartedRecord, Run: run})
+	}
+	for _, record := range recordList {
+		data, err := json.Marshal(record)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), rhs.path); err != nil {
+		return err
+	}
+
+	// file which is opened for appending is the old one
+	if rhs.file != nil {
+		rhs.file.Close()
+		rhs.file = nil
+	}
+	rhs.recordCount = len(recordList)
+	return nil
+}
+
+func (rhs *RunHistoryStore) Close() error {
+	rhs.mutexForRunHistory.Lock()
+	defer rhs.mutexForRunHistory.Unlock()
+	if rhs.file == nil {
+		return nil
+	}
+	err := rhs.file.Close()
+	rhs.file = nil
+	return err
+}
@@ -0,0 +1,108 @@
+package alarm
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	// RunUnknown is a run whose exit code can't be read, e.g. a monitored process which is waited by its parent
+	RunUnknown = "unknown"
+)
+
+// Status tells whether the run succeeded from its exit code and failed process of the job
+func (r Run) Status() string {
+	if r.ExitCode != nil && *r.ExitCode != 0 || r.FailedProcess.Pid != 0 {
+		return RunFailed
+	}
+	if r.ExitCode != nil {
+		return RunSucceeded
+	}
+	return RunUnknown
+}
+
+// RunQuery selects finished runs, zero value of each field selects every run
+type RunQuery struct {
+	// Pattern is a monitoringCommand, or a part of command of the run
+	Pattern string
+	// Since and Until are compared with the time when the run finished
+	Since time.Time
+	Until time.Time
+	// Status is one of RunSucceeded, RunFailed and RunUnknown
+	Status string
+	// Directory selects runs in the directory and its subdirectories
+	Directory       string
+	MinimumDuration time.Duration
+}
+
+func (rq RunQuery) Match(run Run) bool {
+	if rq.Pattern != "" && run.MonitoringCommand != rq.Pattern && !strings.Contains(run.Command, rq.Pattern) {
+		return false
+	}
+	if !rq.Since.IsZero() && run.FinishedAt.Before(rq.Since) {
+		return false
+	}
+	if !rq.Until.IsZero() && !run.FinishedAt.Before(rq.Until) {
+		return false
+	}
+	if rq.Status != "" && run.Status() != rq.Status {
+		return false
+	}
+	if rq.Directory != "" && !isInDirectory(run.Directory, filepath.Clean(rq.Directory)) {
+		return false
+	}
+	return run.Duration() >= rq.MinimumDuration
+}
+
+func isInDirectory(path string, directory string) bool {
+	if path == directory || directory == string(filepath.Separator) {
+		return true
+	}
+	return strings.HasPrefix(path, directory+string(filepath.Separator))
+}
+
+// FindRunList returns runs which match the query, in the order they are given
+func (rq RunQuery) FindRunList(runList []Run) []Run {
+	foundRunList := []Run{}
+	for _, run := range runList {
+		if rq.Match(run) {
+			foundRunList = append(foundRunList, run)
+		}
+	}
+	return foundRunList
+}
+
+// ReadRunHistory reads finished runs from the history file without changing it, so it can be read while the daemon writes it.
+// broken lines are skipped silently, the last one can be being written by the daemon
+func ReadRunHistory(path string) ([]Run, error) {
+	rhs := newRunHistoryStore(path, 0, 0)
+	if err := rhs.load(); err != nil {
+		return nil, err
+	}
+	return rhs.finishedRunList, nil
+}
+
+// ParseQueryTime reads time of --since and --until flags,
+// which is RFC 3339, a date in local time zone, or how long ago it is like "36h" or "7d"
+func ParseQueryTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither RFC 3339 time, date like 2006-01-02 nor duration like 36h or 7d", value)
+}
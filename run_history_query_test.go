@@ -0,0 +1,134 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunQuery(t *testing.T) {
+	t.Run("RunStatus", CheckRunStatus())
+	t.Run("MatchRun", CheckMatchRun())
+	t.Run("ReadRunHistory", CheckReadRunHistory())
+	t.Run("ParseQueryTime", CheckParseQueryTime())
+}
+
+func CheckRunStatus() func(*testing.T) {
+	return func(t *testing.T) {
+		now := time.Now()
+		run := newTestRun(100, now)
+		require.Equal(t, RunUnknown, run.Status())
+		require.Equal(t, RunSucceeded, finishTestRun(run, now, 0).Status())
+		require.Equal(t, RunFailed, finishTestRun(run, now, 1).Status())
+
+		// failure of a descendant fails the job
+		run.FailedProcess = FailedProcess{Pid: 101, Cmd: "cc -c parser.c", ExitCode: 1}
+		require.Equal(t, RunFailed, run.Status())
+
+		// exit code observed by process tree is recorded in run of process
+		startedProcessStatus := NewProcessStatus(100, ProcessStarted)
+		finishedProcessStatus := NewProcessStatus(100, ProcessFinished)
+		succeeded, failed := 0, 2
+		for _, testCase := range []struct {
+			exitCode       *int
+			expectedStatus string
+		}{
+			{nil, RunUnknown},
+			{&succeeded, RunSucceeded},
+			{&failed, RunFailed},
+		} {
+			finishedProcessStatus.SetProcessTreeSummary(ProcessTreeSummary{ProcessCount: 1, ExitCode: testCase.exitCode})
+			run := NewRunOfProcessStatus("make build", []ProcessStatus{startedProcessStatus, finishedProcessStatus})
+			require.Equal(t, testCase.exitCode, run.ExitCode)
+			require.Equal(t, testCase.expectedStatus, run.Status())
+		}
+	}
+}
+
+func CheckMatchRun() func(*testing.T) {
+	return func(t *testing.T) {
+		now := time.Now()
+		run := finishTestRun(newTestRun(100, now.Add(-time.Minute)), now, 1)
+		run.MonitoringCommand = "shell"
+		run.Command = "go test ./..."
+		run.Directory = "/src/billing/cmd"
+
+		for name, testCase := range map[string]struct {
+			runQuery RunQuery
+			isMatch  bool
+		}{
+			"Empty":                   {RunQuery{}, true},
+			"MonitoringCommand":       {RunQuery{Pattern: "shell"}, true},
+			"PartOfCommand":           {RunQuery{Pattern: "go test"}, true},
+			"OtherCommand":            {RunQuery{Pattern: "make"}, false},
+			"Since":                   {RunQuery{Since: now}, true},
+			"SinceLater":              {RunQuery{Since: now.Add(time.Second)}, false},
+			"Until":                   {RunQuery{Until: now}, false},
+			"UntilLater":              {RunQuery{Until: now.Add(time.Second)}, true},
+			"Status":                  {RunQuery{Status: RunFailed}, true},
+			"OtherStatus":             {RunQuery{Status: RunSucceeded}, false},
+			"Directory":               {RunQuery{Directory: "/src/billing"}, true},
+			"DirectoryWithSeparator":  {RunQuery{Directory: "/src/billing/"}, true},
+			"RootDirectory":           {RunQuery{Directory: "/"}, true},
+			"DirectoryWithSamePrefix": {RunQuery{Directory: "/src/bill"}, false},
+			"MinimumDuration":         {RunQuery{MinimumDuration: time.Minute}, true},
+			"LongerMinimumDuration":   {RunQuery{MinimumDuration: time.Hour}, false},
+			"Every":                   {RunQuery{Pattern: "go test", Since: now.Add(-time.Hour), Status: RunFailed, Directory: "/src"}, true},
+		} {
+			require.Equal(t, testCase.isMatch, testCase.runQuery.Match(run), name)
+		}
+		require.Equal(t, []Run{run}, RunQuery{Pattern: "go test"}.FindRunList([]Run{newTestRun(200, now), run}))
+	}
+}
+
+func CheckReadRunHistory() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, RunHistoryFileName)
+
+		runList, err := ReadRunHistory(path)
+		require.NoError(t, err)
+		require.Empty(t, runList)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+		now := time.Now().UTC().Round(0)
+		a := newTestRun(100, now)
+		require.NoError(t, rhs.StartRun(a))
+		require.NoError(t, rhs.StartRun(newTestRun(200, now)))
+		_, err = rhs.FinishRun(finishTestRun(a, now.Add(time.Second), 0))
+		require.NoError(t, err)
+
+		// running runs are not in history yet
+		runList, err = ReadRunHistory(path)
+		require.NoError(t, err)
+		require.Equal(t, []Run{finishTestRun(a, now.Add(time.Second), 0)}, runList)
+	}
+}
+
+func CheckParseQueryTime() func(*testing.T) {
+	return func(t *testing.T) {
+		now := time.Date(2024, 5, 8, 15, 30, 0, 0, time.UTC)
+		for value, expected := range map[string]time.Time{
+			"2024-05-01T09:00:00+09:00": time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			"2024-05-01":                time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			"36h":                       time.Date(2024, 5, 7, 3, 30, 0, 0, time.UTC),
+			"7d":                        time.Date(2024, 5, 1, 15, 30, 0, 0, time.UTC),
+		} {
+			parsed, err := ParseQueryTime(value, now)
+			require.NoError(t, err, value)
+			require.True(t, expected.Equal(parsed), value)
+		}
+		for _, value := range []string{"", "yesterday", "-1h", "2024-13-01"} {
+			_, err := ParseQueryTime(value, now)
+			require.Error(t, err, value)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHistoryStore(t *testing.T) {
+	t.Run("Restart", CheckRunHistoryRestart())
+	t.Run("Retention", CheckRunHistoryRetention())
+	t.Run("BrokenLine", CheckRunHistoryBrokenLine())
+	t.Run("Compaction", CheckRunHistoryCompaction())
+	t.Run("DiscardRun", CheckDiscardRun())
+}
+
+func newTestRun(pid int, startedAt time.Time) Run {
+	return Run{
+		MonitoringCommand: "make build",
+		Pid:               pid,
+		ProcessStartTime:  startedAt.Add(-time.Millisecond),
+		Command:           "make build",
+		Directory:         "/src/billing",
+		StartedAt:         startedAt,
+	}
+}
+
+func finishTestRun(run Run, finishedAt time.Time, exitCode int) Run {
+	run.FinishedAt = finishedAt
+	run.ExitCode = &exitCode
+	return run
+}
+
+func countLines(t *testing.T, path string) int {
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Count(string(data), "\n")
+}
+
+func CheckRunHistoryRestart() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "state", RunHistoryFileName)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		now := time.Now().UTC().Round(0)
+		a := newTestRun(100, now.Add(-time.Minute))
+		b := newTestRun(200, now.Add(-time.Second))
+		require.NoError(t, rhs.StartRun(a))
+		require.NoError(t, rhs.StartRun(b))
+		isNewlyFinished, err := rhs.FinishRun(finishTestRun(a, now, 2))
+		require.NoError(t, err)
+		require.True(t, isNewlyFinished)
+		require.NoError(t, rhs.Close())
+
+		rhs, err = OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+		require.Equal(t, []Run{b}, rhs.GetRunningRunList())
+		finishedRunList := rhs.GetFinishedRunList()
+		require.Equal(t, 1, len(finishedRunList))
+		require.Equal(t, 2, *finishedRunList[0].ExitCode)
+		require.Equal(t, time.Minute, finishedRunList[0].Duration())
+		require.True(t, rhs.IsFinished(a))
+		require.False(t, rhs.IsFinished(b))
+
+		// finished run is neither alarmed nor started again
+		isNewlyFinished, err = rhs.FinishRun(finishTestRun(a, now, 2))
+		require.NoError(t, err)
+		require.False(t, isNewlyFinished)
+		require.NoError(t, rhs.StartRun(a))
+		require.Equal(t, []Run{b}, rhs.GetRunningRunList())
+
+		// process which reuses the pid is another run
+		c := newTestRun(100, now)
+		require.False(t, rhs.IsFinished(c))
+	}
+}
+
+func CheckRunHistoryRetention() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, RunHistoryFileName)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 3)
+		require.NoError(t, err)
+		now := time.Now().UTC().Round(0)
+		for pid := 1; pid <= 5; pid++ {
+			run := newTestRun(pid, now.Add(-time.Minute))
+			_, err := rhs.FinishRun(finishTestRun(run, now, 0))
+			require.NoError(t, err)
+		}
+		finishedRunList := rhs.GetFinishedRunList()
+		require.Equal(t, 3, len(finishedRunList))
+		require.Equal(t, 3, finishedRunList[0].Pid)
+		require.Equal(t, 5, countLines(t, path))
+		require.NoError(t, rhs.Close())
+
+		// old runs are dropped from the file when it is opened
+		rhs, err = OpenRunHistoryStore(path, 0, 3)
+		require.NoError(t, err)
+		require.Equal(t, 3, countLines(t, path))
+
+		rhs.SetRetention(time.Hour, 0)
+		oldRun := newTestRun(6, now.Add(-3*time.Hour))
+		require.NoError(t, rhs.StartRun(oldRun))
+		_, err = rhs.FinishRun(finishTestRun(oldRun, now.Add(-2*time.Hour), 0))
+		require.NoError(t, err)
+		require.Equal(t, 3, len(rhs.GetFinishedRunList()))
+
+		// run which is started before retention is not waited forever
+		require.NoError(t, rhs.StartRun(newTestRun(7, now.Add(-2*time.Hour))))
+		require.Empty(t, rhs.GetRunningRunList())
+		require.NoError(t, rhs.Close())
+	}
+}
+
+func CheckRunHistoryBrokenLine() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, RunHistoryFileName)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		now := time.Now().UTC().Round(0)
+		require.NoError(t, rhs.StartRun(newTestRun(100, now)))
+		require.NoError(t, rhs.Close())
+
+		// the daemon is killed while it writes a line
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"type":"finish","run":{"monitoringCommand":"make`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		rhs, err = OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+		require.Equal(t, 1, len(rhs.GetRunningRunList()))
+		require.Equal(t, 1, countLines(t, path))
+	}
+}
+
+func CheckRunHistoryCompaction() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, RunHistoryFileName)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+		now := time.Now().UTC().Round(0)
+		for pid := 1; pid <= runHistoryCompactionThreshold; pid++ {
+			run := newTestRun(pid, now)
+			require.NoError(t, rhs.StartRun(run))
+			_, err := rhs.FinishRun(finishTestRun(run, now, 0))
+			require.NoError(t, err)
+		}
+		// started records of finished runs are removed
+		require.Less(t, countLines(t, path), 2*runHistoryCompactionThreshold)
+		require.Equal(t, runHistoryCompactionThreshold, len(rhs.GetFinishedRunList()))
+
+		// records are appended to the compacted file
+		run := newTestRun(runHistoryCompactionThreshold+1, now)
+		require.NoError(t, rhs.StartRun(run))
+		reopened, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer reopened.Close()
+		require.Equal(t, []Run{run}, reopened.GetRunningRunList())
+		require.Equal(t, runHistoryCompactionThreshold, len(reopened.GetFinishedRunList()))
+	}
+}
+
+func CheckDiscardRun() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "history")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, RunHistoryFileName)
+
+		rhs, err := OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		run := newTestRun(100, time.Now().UTC().Round(0))
+		require.NoError(t, rhs.StartRun(run))
+		require.NoError(t, rhs.DiscardRun(run))
+		require.Empty(t, rhs.GetRunningRunList())
+		require.NoError(t, rhs.Close())
+
+		rhs, err = OpenRunHistoryStore(path, 0, 0)
+		require.NoError(t, err)
+		defer rhs.Close()
+		require.Empty(t, rhs.GetRunningRunList())
+		require.Empty(t, rhs.GetFinishedRunList())
+	}
+}
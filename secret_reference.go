@@ -0,0 +1,130 @@
+package alarm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const (
+	EnvSecretReferencePrefix  = "env:"
+	FileSecretReferencePrefix = "file:"
+	CmdSecretReferencePrefix  = "cmd:"
+
+	RedactedSecret = "[REDACTED]"
+)
+
+// Secret is a value of config which should not be exposed.
+// it is printed as its reference, or RedactedSecret if it is written in plaintext
+type Secret struct {
+	reference string
+	value     string
+}
+
+func NewSecret(value string) Secret {
+	return Secret{
+		value: value,
+	}
+}
+
+func (s Secret) Value() string {
+	return s.value
+}
+
+func (s Secret) Reference() string {
+	return s.reference
+}
+
+func (s Secret) String() string {
+	if s.reference != "" {
+		return s.reference
+	}
+	if s.value == "" {
+		return ""
+	}
+	return RedactedSecret
+}
+
+func (s Secret) GoString() string {
+	return fmt.Sprintf("alarm.Secret(%q)", s.String())
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// IsSecretReference reports whether value refers to a secret
+// instead of containing it, e.g. "env:SLACK_WEBHOOK"
+func IsSecretReference(value string) bool {
+	return strings.HasPrefix(value, EnvSecretReferencePrefix) ||
+		strings.HasPrefix(value, FileSecretReferencePrefix) ||
+		strings.HasPrefix(value, CmdSecretReferencePrefix)
+}
+
+// ResolveSecretReference returns the secret which reference points to.
+// returned error never contains the resolved secret
+func ResolveSecretReference(reference string) (string, error) {
+	switch {
+	case strings.HasPrefix(reference, EnvSecretReferencePrefix):
+		return resolveEnvSecretReference(strings.TrimPrefix(reference, EnvSecretReferencePrefix))
+	case strings.HasPrefix(reference, FileSecretReferencePrefix):
+		return resolveFileSecretReference(strings.TrimPrefix(reference, FileSecretReferencePrefix))
+	case strings.HasPrefix(reference, CmdSecretReferencePrefix):
+		return resolveCmdSecretReference(strings.TrimPrefix(reference, CmdSecretReferencePrefix))
+	}
+	return "", fmt.Errorf("%q is not a secret reference", reference)
+}
+
+func resolveEnvSecretReference(name string) (string, error) {
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("environment variable %s is empty", name)
+	}
+	return secret, nil
+}
+
+func resolveFileSecretReference(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// output of command is not included in error
+// because it can contain a part of the secret
+func resolveCmdSecretReference(command string) (string, error) {
+	stdout := bytes.Buffer{}
+	c := exec.Command("sh", "-c", command)
+	c.Stdout = &stdout
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("secret command %q is failed: %v", command, err)
+	}
+	secret := strings.TrimRight(stdout.String(), "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("secret command %q printed nothing", command)
+	}
+	return secret, nil
+}
+
+// RedactSecrets replaces every secret in message with RedactedSecret
+func RedactSecrets(message string, secretList []string) string {
+	for _, secret := range secretList {
+		if secret == "" {
+			continue
+		}
+		message = strings.ReplaceAll(message, secret, RedactedSecret)
+	}
+	return message
+}
@@ -0,0 +1,83 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecret = "https://hooks.slack.com/services/TEST/SECRET"
+)
+
+func TestSecretReference(t *testing.T) {
+	t.Run("IsSecretReference", CheckIsSecretReference())
+	t.Run("EnvSecretReference", CheckEnvSecretReference())
+	t.Run("FileSecretReference", CheckFileSecretReference())
+	t.Run("CmdSecretReference", CheckCmdSecretReference())
+	t.Run("RedactSecrets", CheckRedactSecrets())
+}
+
+func CheckIsSecretReference() func(*testing.T) {
+	return func(t *testing.T) {
+		require.True(t, IsSecretReference("env:SLACK_WEBHOOK"))
+		require.True(t, IsSecretReference("file:/run/secrets/slack"))
+		require.True(t, IsSecretReference("cmd:pass show slack/hook"))
+		require.False(t, IsSecretReference(testSecret))
+		require.False(t, IsSecretReference("slack-webhook"))
+	}
+}
+
+func CheckEnvSecretReference() func(*testing.T) {
+	return func(t *testing.T) {
+		os.Setenv("ALARM_TEST_SECRET", testSecret)
+		defer os.Unsetenv("ALARM_TEST_SECRET")
+
+		secret, err := ResolveSecretReference("env:ALARM_TEST_SECRET")
+		require.NoError(t, err)
+		require.Equal(t, testSecret, secret)
+
+		_, err = ResolveSecretReference("env:THERE_WILL_BE_NO_ENV_LIKE_THIS")
+		require.Error(t, err)
+	}
+}
+
+func CheckFileSecretReference() func(*testing.T) {
+	return func(t *testing.T) {
+		f, err := ioutil.TempFile("", "secret")
+		require.NoError(t, err)
+		defer os.Remove(f.Name())
+		f.WriteString(testSecret + "\n")
+		f.Close()
+
+		secret, err := ResolveSecretReference("file:" + f.Name())
+		require.NoError(t, err)
+		require.Equal(t, testSecret, secret)
+	}
+}
+
+func CheckCmdSecretReference() func(*testing.T) {
+	return func(t *testing.T) {
+		secret, err := ResolveSecretReference("cmd:echo " + testSecret)
+		require.NoError(t, err)
+		require.Equal(t, testSecret, secret)
+
+		os.Setenv("ALARM_TEST_SECRET", testSecret)
+		defer os.Unsetenv("ALARM_TEST_SECRET")
+
+		_, err = ResolveSecretReference("cmd:echo $ALARM_TEST_SECRET; exit 1")
+		require.Error(t, err)
+		require.NotContains(t, err.Error(), testSecret)
+	}
+}
+
+func CheckRedactSecrets() func(*testing.T) {
+	return func(t *testing.T) {
+		require.Equal(t,
+			`Post "[REDACTED]": dial tcp: i/o timeout`,
+			RedactSecrets(`Post "`+testSecret+`": dial tcp: i/o timeout`, []string{testSecret}),
+		)
+	}
+}
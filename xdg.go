@@ -0,0 +1,129 @@
+package alarm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	// ApplicationName is the name of directories under XDG base directories
+	ApplicationName = "alarm-for-programmer"
+)
+
+// configFileNameList is checked in this order in each config directory
+var configFileNameList = []string{
+	"config.json",
+	"config.yaml",
+	"config.yml",
+	"config.toml",
+}
+
+// ConfigDirectory is $XDG_CONFIG_HOME/alarm-for-programmer, $XDG_CONFIG_HOME is ~/.config by default
+func ConfigDirectory() string {
+	return configDirectory(os.Getenv)
+}
+
+func configDirectory(getenv func(string) string) string {
+	configHome := getenv("XDG_CONFIG_HOME")
+	if !filepath.IsAbs(configHome) {
+		// relative path should be ignored by XDG base directory specification
+		configHome = filepath.Join(homeDirectory(getenv), ".config")
+	}
+	return filepath.Join(configHome, ApplicationName)
+}
+
+// FindConfigPath finds config file in config directory and then in $XDG_CONFIG_DIRS.
+// if there is no config file, config.json in config directory is returned
+// so that it can be created by "alarm config init"
+func FindConfigPath() string {
+	return findConfigPath(os.Getenv)
+}
+
+func findConfigPath(getenv func(string) string) string {
+	directoryList := []string{configDirectory(getenv)}
+	configDirs := getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, configDir := range strings.Split(configDirs, ":") {
+		if filepath.IsAbs(configDir) {
+			directoryList = append(directoryList, filepath.Join(configDir, ApplicationName))
+		}
+	}
+
+	for _, directory := range directoryList {
+		for _, configFileName := range configFileNameList {
+			configPath := filepath.Join(directory, configFileName)
+			if _, err := os.Stat(configPath); err == nil {
+				return configPath
+			}
+		}
+	}
+	return filepath.Join(directoryList[0], configFileNameList[0])
+}
+
+func homeDirectory(getenv func(string) string) string {
+	if home := getenv("HOME"); home != "" {
+		return home
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "/"
+	}
+	return home
+}
+
+// StateDirectory is $XDG_STATE_HOME/alarm-for-programmer, $XDG_STATE_HOME is ~/.local/state by default
+func StateDirectory() string {
+	return stateDirectory(os.Getenv)
+}
+
+func stateDirectory(getenv func(string) string) string {
+	stateHome := getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(stateHome) {
+		stateHome = filepath.Join(homeDirectory(getenv), ".local", "state")
+	}
+	return filepath.Join(stateHome, ApplicationName)
+}
+
+// RuntimeDirectory is $XDG_RUNTIME_DIR/alarm-for-programmer.
+// if $XDG_RUNTIME_DIR is not set, a directory of the user in temporary directory is used
+func RuntimeDirectory() string {
+	return runtimeDirectory(os.Getenv)
+}
+
+func runtimeDirectory(getenv func(string) string) string {
+	runtimeDir := getenv("XDG_RUNTIME_DIR")
+	if !filepath.IsAbs(runtimeDir) {
+		return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", ApplicationName, os.Getuid()))
+	}
+	return filepath.Join(runtimeDir, ApplicationName)
+}
+
+// EnsurePrivateDirectory creates directory which only the user can access.
+// existing directory is refused if it is owned by another user,
+// because directories in temporary directory can be created by anyone
+func EnsurePrivateDirectory(directory string) error {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+	// symbolic link is not followed, it could point to a directory of another user
+	fileInfo, err := os.Lstat(directory)
+	if err != nil {
+		return err
+	}
+	if !fileInfo.IsDir() {
+		return fmt.Errorf("%s is not a directory", directory)
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("directory %s is owned by another user", directory)
+	}
+	if fileInfo.Mode().Perm()&0077 != 0 {
+		return os.Chmod(directory, 0700)
+	}
+	return nil
+}
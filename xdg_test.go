@@ -0,0 +1,77 @@
+package alarm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXdg(t *testing.T) {
+	t.Run("ConfigDirectory", CheckConfigDirectory())
+	t.Run("FindConfigPath", CheckFindConfigPath())
+	t.Run("StateDirectory", CheckStateDirectory())
+}
+
+func CheckConfigDirectory() func(*testing.T) {
+	return func(t *testing.T) {
+		env := map[string]string{
+			"HOME": "/home/me",
+		}
+		getenv := func(name string) string {
+			return env[name]
+		}
+		require.Equal(t, "/home/me/.config/alarm-for-programmer", configDirectory(getenv))
+
+		env["XDG_CONFIG_HOME"] = "/xdg"
+		require.Equal(t, "/xdg/alarm-for-programmer", configDirectory(getenv))
+
+		env["XDG_CONFIG_HOME"] = "relative"
+		require.Equal(t, "/home/me/.config/alarm-for-programmer", configDirectory(getenv))
+	}
+}
+
+func CheckStateDirectory() func(*testing.T) {
+	return func(t *testing.T) {
+		env := map[string]string{
+			"HOME": "/home/me",
+		}
+		getenv := func(name string) string {
+			return env[name]
+		}
+		require.Equal(t, "/home/me/.local/state/alarm-for-programmer", stateDirectory(getenv))
+
+		env["XDG_STATE_HOME"] = "/xdg"
+		require.Equal(t, "/xdg/alarm-for-programmer", stateDirectory(getenv))
+	}
+}
+
+func CheckFindConfigPath() func(*testing.T) {
+	return func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "xdg")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		env := map[string]string{
+			"XDG_CONFIG_HOME": filepath.Join(dir, "home"),
+			"XDG_CONFIG_DIRS": filepath.Join(dir, "system"),
+		}
+		getenv := func(name string) string {
+			return env[name]
+		}
+		userConfigPath := filepath.Join(dir, "home", ApplicationName, "config.json")
+		require.Equal(t, userConfigPath, findConfigPath(getenv))
+
+		systemConfigPath := filepath.Join(dir, "system", ApplicationName, "config.yaml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(systemConfigPath), 0755))
+		require.NoError(t, ioutil.WriteFile(systemConfigPath, []byte{}, 0644))
+		require.Equal(t, systemConfigPath, findConfigPath(getenv))
+
+		userConfigPath = filepath.Join(dir, "home", ApplicationName, "config.toml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(userConfigPath), 0755))
+		require.NoError(t, ioutil.WriteFile(userConfigPath, []byte{}, 0644))
+		require.Equal(t, userConfigPath, findConfigPath(getenv))
+	}
+}